@@ -0,0 +1,621 @@
+package objects
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	stewstrings "github.com/stretchr/stew/strings"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// BindTagName is the name of the struct tag that Decode and FromStruct
+	// look at first.  If a field has no such tag, the `json` tag is used.
+	//
+	// For example:
+	//
+	//     type User struct {
+	//         Name  string `stew:"name"`
+	//         Email string `json:"email,omitempty"`
+	//         Token string `stew:"-"`
+	//     }
+	BindTagName string = "stew"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// FieldError describes a single value that could not be bound.
+type FieldError struct {
+	// Keypath is where the value is in the Map.
+	Keypath string
+	// Err is the reason it could not be bound.
+	Err error
+}
+
+// Error gets the error message.
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("'%s': %s", e.Keypath, e.Err)
+}
+
+// Unwrap gets the reason the value could not be bound.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// BindError is returned by Decode and FromStruct, and lists every value
+// that could not be bound.
+type BindError struct {
+	Errors []*FieldError
+}
+
+// Error gets the error message.
+func (e *BindError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("Map: Binding failed for %d value(s): %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// binder collects errors as values are bound.
+type binder struct {
+	errors []*FieldError
+}
+
+// fail records that the value at the keypath could not be bound.
+func (b *binder) fail(keypath string, err error) {
+	b.errors = append(b.errors, &FieldError{Keypath: keypath, Err: err})
+}
+
+// err gets the *BindError if anything failed.
+func (b *binder) err() error {
+	if len(b.errors) == 0 {
+		return nil
+	}
+	return &BindError{Errors: b.errors}
+}
+
+/*
+	Struct fields
+	------------------------------------------------
+*/
+
+// bindField is a struct field that can be bound to a key.
+type bindField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// bindFieldCache holds the []bindField for each struct type.
+var bindFieldCache sync.Map
+
+// bindFields gets the fields of the struct type, including those promoted
+// from embedded structs.  Fields nearer the top win when names clash.
+func bindFields(t reflect.Type) []bindField {
+
+	if cached, ok := bindFieldCache.Load(t); ok {
+		return cached.([]bindField)
+	}
+
+	var fields []bindField
+	seen := make(map[string]bool)
+	visited := map[reflect.Type]bool{t: true}
+
+	current := []bindField{{}}
+	for len(current) > 0 {
+
+		var next []bindField
+		var level []bindField
+
+		for _, parent := range current {
+
+			parentType := t
+			if len(parent.index) > 0 {
+				parentType = t.FieldByIndex(parent.index).Type
+				if parentType.Kind() == reflect.Ptr {
+					parentType = parentType.Elem()
+				}
+			}
+
+			for i := 0; i < parentType.NumField(); i++ {
+
+				sf := parentType.Field(i)
+				index := append(append([]int{}, parent.index...), i)
+
+				tag := sf.Tag.Get(BindTagName)
+				if tag == "" {
+					tag = sf.Tag.Get("json")
+				}
+				if tag == "-" {
+					continue
+				}
+
+				name, options := tag, ""
+				if comma := strings.Index(tag, ","); comma != -1 {
+					name, options = tag[:comma], tag[comma+1:]
+				}
+
+				fieldType := sf.Type
+				if fieldType.Kind() == reflect.Ptr {
+					fieldType = fieldType.Elem()
+				}
+
+				if sf.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+					// unexported embedded pointers cannot be allocated
+					if !visited[fieldType] && (sf.PkgPath == "" || sf.Type.Kind() != reflect.Ptr) {
+						visited[fieldType] = true
+						next = append(next, bindField{index: index})
+					}
+					continue
+				}
+
+				if sf.PkgPath != "" {
+					// unexported
+					continue
+				}
+
+				if name == "" {
+					name = sf.Name
+				}
+
+				level = append(level, bindField{
+					name:      name,
+					index:     index,
+					omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+				})
+
+			}
+
+		}
+
+		// names that appear more than once at the same depth are ambiguous
+		counts := make(map[string]int)
+		for _, field := range level {
+			counts[field.name]++
+		}
+		for _, field := range level {
+			if !seen[field.name] && counts[field.name] == 1 {
+				fields = append(fields, field)
+			}
+		}
+		for name := range counts {
+			seen[name] = true
+		}
+
+		current = next
+	}
+
+	bindFieldCache.Store(t, fields)
+
+	return fields
+}
+
+// fieldByIndex gets the field, allocating embedded pointers along the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// existingFieldByIndex gets the field, or false if an embedded pointer along
+// the way is nil.
+func existingFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+/*
+	Decode
+	------------------------------------------------
+*/
+
+// Decode copies the values in the map into the target, which must be a
+// pointer, usually to a struct.
+//
+// Keys are matched to fields using the `stew` struct tag, then the `json` tag,
+// then the field name (case-insensitively, like encoding/json).  Fields of embedded
+// structs are treated as if they were in the outer struct.
+//
+// Values are converted where it makes sense; numbers between types, numeric and
+// boolean strings (using strings.Parse), RFC 3339 strings to time.Time, and strings
+// to anything implementing encoding.TextUnmarshaler.
+//
+// For example:
+//
+//     var user User
+//     err := m.Decode(&user)
+//
+// Every value that cannot be decoded is reported, along with its keypath, in a
+// *BindError.  Everything else is still decoded.
+func (d Map) Decode(target interface{}) error {
+
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("Map: Decode target must be a non-nil pointer.")
+	}
+
+	b := new(binder)
+	b.decode("", d, v.Elem())
+
+	return b.err()
+}
+
+// decode binds the value into dst, recording any failures against the keypath.
+func (b *binder) decode(keypath string, value interface{}, dst reflect.Value) {
+
+	if value == nil {
+		switch dst.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return
+	}
+
+	src := reflect.ValueOf(value)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(reflect.ValueOf(deepCopyValue(value)))
+		return
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		b.decode(keypath, value, dst.Elem())
+		return
+	}
+
+	if s, isString := value.(string); isString && dst.Type() != timeType && reflect.PtrTo(dst.Type()).Implements(textUnmarshalerType) {
+		if err := dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			b.fail(keypath, err)
+		}
+		return
+	}
+
+	mismatch := func() {
+		b.fail(keypath, typeError(keypath, dst.Type().String(), value))
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if !src.Type().Implements(dst.Type()) {
+			mismatch()
+			return
+		}
+		dst.Set(src)
+
+	case reflect.String:
+		switch value.(type) {
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			dst.SetString(fmt.Sprint(value))
+		default:
+			if src.Kind() != reflect.String {
+				mismatch()
+				return
+			}
+			dst.SetString(src.String())
+		}
+
+	case reflect.Bool:
+		switch value.(type) {
+		case bool:
+			dst.SetBool(value.(bool))
+		case string:
+			parsed, ok := stewstrings.Parse(value.(string)).(bool)
+			if !ok {
+				mismatch()
+				return
+			}
+			dst.SetBool(parsed)
+		default:
+			mismatch()
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s, isString := value.(string); isString && dst.Type() == durationType {
+			if duration, err := time.ParseDuration(s); err == nil {
+				dst.SetInt(int64(duration))
+				return
+			}
+		}
+		i, ok := toInt64(value)
+		if !ok || dst.OverflowInt(i) {
+			mismatch()
+			return
+		}
+		dst.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, isUint64 := value.(uint64)
+		if !isUint64 {
+			i, ok := toInt64(value)
+			if !ok || i < 0 {
+				mismatch()
+				return
+			}
+			u = uint64(i)
+		}
+		if dst.OverflowUint(u) {
+			mismatch()
+			return
+		}
+		dst.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(value)
+		if !ok || dst.OverflowFloat(f) {
+			mismatch()
+			return
+		}
+		dst.SetFloat(f)
+
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			s, isString := value.(string)
+			if !isString {
+				mismatch()
+				return
+			}
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				mismatch()
+				return
+			}
+			dst.Set(reflect.ValueOf(t))
+			return
+		}
+		m, ok := asMap(value)
+		if !ok {
+			mismatch()
+			return
+		}
+		b.decodeStruct(keypath, m, dst)
+
+	case reflect.Map:
+		m, ok := asMap(value)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			mismatch()
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+		}
+		for k, v := range m {
+			item := reflect.New(dst.Type().Elem()).Elem()
+			if existing := dst.MapIndex(reflect.ValueOf(k).Convert(dst.Type().Key())); existing.IsValid() {
+				item.Set(existing)
+			}
+			b.decode(childKeypath(keypath, k), v, item)
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), item)
+		}
+
+	case reflect.Slice:
+		length, ok := sliceLen(value)
+		if !ok {
+			mismatch()
+			return
+		}
+		items := reflect.MakeSlice(dst.Type(), length, length)
+		for i := 0; i < length; i++ {
+			b.decode(indexKeypath(keypath, i), sliceItem(value, i), items.Index(i))
+		}
+		dst.Set(items)
+
+	case reflect.Array:
+		length, ok := sliceLen(value)
+		if !ok || length > dst.Len() {
+			mismatch()
+			return
+		}
+		for i := 0; i < length; i++ {
+			b.decode(indexKeypath(keypath, i), sliceItem(value, i), dst.Index(i))
+		}
+
+	default:
+		mismatch()
+	}
+
+}
+
+// decodeStruct binds the map into the fields of the struct.
+func (b *binder) decodeStruct(keypath string, m Map, dst reflect.Value) {
+
+	for _, field := range bindFields(dst.Type()) {
+
+		key := field.name
+		value, exists := m[key]
+		if !exists {
+			for k, v := range m {
+				if strings.EqualFold(k, field.name) {
+					key, value, exists = k, v, true
+					break
+				}
+			}
+		}
+		if !exists {
+			continue
+		}
+
+		b.decode(childKeypath(keypath, key), value, fieldByIndex(dst, field.index))
+
+	}
+
+}
+
+/*
+	FromStruct
+	------------------------------------------------
+*/
+
+// FromStruct creates a new Map from the exported fields of the struct (or
+// pointer to a struct), following the same struct tag rules as Decode and
+// honouring `omitempty`.
+//
+// Nested structs and maps become Map values and slices become []interface{}.
+// time.Time values are kept as they are, and anything else implementing
+// encoding.TextMarshaler becomes a string.
+//
+// Every value that cannot be represented (channels, functions, maps with
+// keys that are not strings or integers) is reported, along with its keypath,
+// in a *BindError.
+func FromStruct(v interface{}) (Map, error) {
+
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Map: FromStruct needs a struct, not %T.", v)
+	}
+
+	b := new(binder)
+	m := b.encodeStruct("", value)
+
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// encodeStruct makes a Map from the fields of the struct.
+func (b *binder) encodeStruct(keypath string, v reflect.Value) Map {
+
+	m := make(Map)
+
+	for _, field := range bindFields(v.Type()) {
+
+		fieldValue, ok := existingFieldByIndex(v, field.index)
+		if !ok || (field.omitEmpty && isEmptyValue(fieldValue)) {
+			continue
+		}
+
+		m[field.name] = b.encode(childKeypath(keypath, field.name), fieldValue)
+
+	}
+
+	return m
+}
+
+// encode turns the value into something that belongs in a Map.
+func (b *binder) encode(keypath string, v reflect.Value) interface{} {
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	if v.Type() == timeType {
+		return v.Interface()
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			b.fail(keypath, err)
+			return nil
+		}
+		return string(text)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return b.encode(keypath, v.Elem())
+
+	case reflect.Struct:
+		return b.encodeStruct(keypath, v)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(Map, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var key string
+			switch iter.Key().Kind() {
+			case reflect.String:
+				key = iter.Key().String()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				key = strconv.FormatInt(iter.Key().Int(), 10)
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				key = strconv.FormatUint(iter.Key().Uint(), 10)
+			default:
+				b.fail(keypath, fmt.Errorf("Map: %s keys cannot be used in a Map.", iter.Key().Type()))
+				return nil
+			}
+			m[key] = b.encode(childKeypath(keypath, key), iter.Value())
+		}
+		return m
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// keep []byte as it is
+			return v.Interface()
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = b.encode(indexKeypath(keypath, i), v.Index(i))
+		}
+		return items
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		b.fail(keypath, fmt.Errorf("Map: %s values cannot be used in a Map.", v.Type()))
+		return nil
+	}
+
+	return v.Interface()
+}
+
+// isEmptyValue gets whether the value counts as empty for `omitempty`, which
+// matches encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
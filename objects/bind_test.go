@@ -0,0 +1,267 @@
+package objects
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type bindAddress struct {
+	City string `json:"city"`
+	Zip  string `stew:"zip" json:"postcode"`
+}
+
+type bindTimestamps struct {
+	Created time.Time  `json:"created"`
+	Updated *time.Time `json:"updated,omitempty"`
+}
+
+type bindUser struct {
+	bindTimestamps
+	Name     string                 `json:"name"`
+	Age      int                    `json:"age"`
+	Admin    bool                   `json:"admin"`
+	Score    float32                `json:"score,omitempty"`
+	Nickname *string                `json:"nickname"`
+	Address  bindAddress            `json:"address"`
+	Previous []*bindAddress         `json:"previous,omitempty"`
+	Tags     []string               `json:"tags"`
+	Counts   map[string]int         `json:"counts,omitempty"`
+	Extra    map[string]interface{} `json:"extra,omitempty"`
+	IP       net.IP                 `json:"ip,omitempty"`
+	Timeout  time.Duration          `json:"timeout"`
+	Secret   string                 `json:"-"`
+	Default  string
+	private  string
+}
+
+func TestDecode(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{
+		"name": "Mat",
+		"age": 29,
+		"admin": "true",
+		"score": "9.5",
+		"nickname": "matryer",
+		"address": {"city": "Boulder", "zip": "80301"},
+		"previous": [{"city": "London"}, null],
+		"tags": ["a", "b"],
+		"counts": {"a": 1.0, "b": "2"},
+		"extra": {"anything": [1, "two"]},
+		"ip": "127.0.0.1",
+		"timeout": "1m30s",
+		"created": "2013-08-12T10:30:00Z",
+		"Secret": "shh",
+		"default": "case insensitive",
+		"private": "nope"
+	}`)
+
+	var user bindUser
+	if assert.NoError(t, m.Decode(&user)) {
+		assert.Equal(t, "Mat", user.Name)
+		assert.Equal(t, 29, user.Age)
+		assert.True(t, user.Admin)
+		assert.Equal(t, float32(9.5), user.Score)
+		if assert.NotNil(t, user.Nickname) {
+			assert.Equal(t, "matryer", *user.Nickname)
+		}
+		assert.Equal(t, bindAddress{City: "Boulder", Zip: "80301"}, user.Address)
+		if assert.Len(t, user.Previous, 2) {
+			assert.Equal(t, &bindAddress{City: "London"}, user.Previous[0])
+			assert.Nil(t, user.Previous[1])
+		}
+		assert.Equal(t, []string{"a", "b"}, user.Tags)
+		assert.Equal(t, map[string]int{"a": 1, "b": 2}, user.Counts)
+		assert.Equal(t, map[string]interface{}{"anything": []interface{}{float64(1), "two"}}, user.Extra)
+		assert.Equal(t, net.ParseIP("127.0.0.1"), user.IP)
+		assert.Equal(t, 90*time.Second, user.Timeout)
+		assert.Equal(t, time.Date(2013, 8, 12, 10, 30, 0, 0, time.UTC), user.Created)
+		assert.Nil(t, user.Updated)
+		assert.Empty(t, user.Secret)
+		assert.Equal(t, "case insensitive", user.Default)
+		assert.Empty(t, user.private)
+	}
+
+	// decoded values don't share memory with the map
+	user.Extra["anything"].([]interface{})[0] = "changed"
+	assert.Equal(t, float64(1), m.Get("extra.anything[0]"))
+
+}
+
+func TestDecode_NativeValues(t *testing.T) {
+
+	now := time.Now()
+	m := M("created", now, "timeout", time.Second, "age", int64(29), "address", M("city", "Boulder"))
+
+	var user bindUser
+	if assert.NoError(t, m.Decode(&user)) {
+		assert.Equal(t, now, user.Created)
+		assert.Equal(t, time.Second, user.Timeout)
+		assert.Equal(t, 29, user.Age)
+		assert.Equal(t, "Boulder", user.Address.City)
+	}
+
+	var into map[string]int
+	if assert.NoError(t, M("a", 1, "b", 2.0).Decode(&into)) {
+		assert.Equal(t, map[string]int{"a": 1, "b": 2}, into)
+	}
+
+	// numeric strings are parsed exactly
+	var floats map[string]float64
+	if assert.NoError(t, M("a", "0.1", "b", 2).Decode(&floats)) {
+		assert.Equal(t, map[string]float64{"a": 0.1, "b": 2}, floats)
+	}
+	var price struct {
+		Amount float64 `json:"amount"`
+	}
+	if assert.NoError(t, M("amount", "19.99").Decode(&price)) {
+		assert.Equal(t, 19.99, price.Amount)
+	}
+
+}
+
+func TestDecode_Errors(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{
+		"name": ["not", "a", "string"],
+		"age": 29.5,
+		"admin": 1,
+		"address": {"city": 12, "zip": {}},
+		"tags": ["a", {}],
+		"ip": "not an ip",
+		"created": "yesterday"
+	}`)
+
+	var user bindUser
+	err := m.Decode(&user)
+
+	if assert.IsType(t, &BindError{}, err) {
+
+		var keypaths []string
+		for _, fieldErr := range err.(*BindError).Errors {
+			keypaths = append(keypaths, fieldErr.Keypath)
+		}
+
+		assert.ElementsMatch(t, []string{"name", "age", "admin", "address.zip", "tags[1]", "ip", "created"}, keypaths)
+
+		var typeErr *TypeError
+		if assert.True(t, errors.As(err.(*BindError).Errors[0], &typeErr)) {
+			assert.Equal(t, typeErr.Keypath, err.(*BindError).Errors[0].Keypath)
+		}
+
+		assert.Contains(t, err.Error(), "Map: Binding failed for 7 value(s)")
+
+	}
+
+	// everything else is still decoded
+	assert.Equal(t, "12", user.Address.City)
+
+	assert.Error(t, m.Decode(user))
+	assert.Error(t, m.Decode(nil))
+
+}
+
+// BindLocation is exported so that it can be embedded as a pointer.
+type BindLocation struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type bindEmbedded struct {
+	*BindLocation
+	Name string `stew:"name"`
+	City string `stew:"city"`
+}
+
+func TestDecode_EmbeddedPointer(t *testing.T) {
+
+	var e bindEmbedded
+	if assert.NoError(t, M("name", "Mat", "city", "Boulder", "zip", "80301").Decode(&e)) {
+		assert.Equal(t, "Mat", e.Name)
+		assert.Equal(t, "Boulder", e.City, "outer fields win")
+		if assert.NotNil(t, e.BindLocation) {
+			assert.Equal(t, "80301", e.Zip)
+			assert.Empty(t, e.BindLocation.City)
+		}
+	}
+
+}
+
+func TestFromStruct(t *testing.T) {
+
+	nickname := "matryer"
+	created := time.Date(2013, 8, 12, 10, 30, 0, 0, time.UTC)
+
+	user := &bindUser{
+		bindTimestamps: bindTimestamps{Created: created},
+		Name:           "Mat",
+		Age:            29,
+		Nickname:       &nickname,
+		Address:        bindAddress{City: "Boulder", Zip: "80301"},
+		Previous:       []*bindAddress{{City: "London"}, nil},
+		Tags:           []string{"a"},
+		Counts:         map[string]int{"a": 1},
+		IP:             net.ParseIP("127.0.0.1"),
+		Timeout:        time.Second,
+		Secret:         "shh",
+		Default:        "default",
+	}
+
+	m, err := FromStruct(user)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Map{
+			"created":  created,
+			"name":     "Mat",
+			"age":      29,
+			"admin":    false,
+			"nickname": "matryer",
+			"address":  Map{"city": "Boulder", "zip": "80301"},
+			"previous": []interface{}{Map{"city": "London", "zip": ""}, nil},
+			"tags":     []interface{}{"a"},
+			"counts":   Map{"a": 1},
+			"ip":       "127.0.0.1",
+			"timeout":  time.Second,
+			"Default":  "default",
+		}, m)
+	}
+
+	// and back again
+	var decoded bindUser
+	if assert.NoError(t, m.Decode(&decoded)) {
+		user.Secret = ""
+		assert.Equal(t, *user, decoded)
+	}
+
+	m, err = FromStruct(bindEmbedded{Name: "Mat"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, Map{"name": "Mat", "city": ""}, m)
+	}
+
+}
+
+func TestFromStruct_Errors(t *testing.T) {
+
+	_, err := FromStruct("not a struct")
+	assert.Error(t, err)
+
+	_, err = FromStruct(struct {
+		Func    func()               `json:"func"`
+		Nested  map[string]chan bool `json:"nested"`
+		Complex map[float64]string   `json:"complex"`
+	}{
+		Func:    func() {},
+		Nested:  map[string]chan bool{"c": make(chan bool)},
+		Complex: map[float64]string{1.5: "a"},
+	})
+
+	if assert.IsType(t, &BindError{}, err) {
+		var keypaths []string
+		for _, fieldErr := range err.(*BindError).Errors {
+			keypaths = append(keypaths, fieldErr.Keypath)
+		}
+		assert.ElementsMatch(t, []string{"func", "nested.c", "complex"}, keypaths)
+	}
+
+}
@@ -0,0 +1,257 @@
+package objects
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// CanonicalJSON converts the map to a canonical JSON string, following the
+// JSON Canonicalization Scheme (RFC 8785).
+//
+// Keys are sorted, there is no whitespace, strings only escape what they must
+// and all numbers are written the same way whatever their Go type, so maps
+// that hold the same data always give the same string.  For example, Map{"n": 1}
+// and Map{"n": 1.0} are both `{"n":1}`.
+//
+// Values that are not maps, slices, strings, numbers, bools or nil are first
+// converted using encoding/json.  An error is returned for NaN and infinite
+// numbers, which JSON cannot represent.
+func (d Map) CanonicalJSON() (string, error) {
+
+	var buf bytes.Buffer
+
+	if err := writeCanonical(&buf, d); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// CanonicalHash gets the hex encoded hash of the map's CanonicalJSON, using the
+// specified algorithm.
+//
+// For example:
+//
+//     etag, err := m.CanonicalHash(crypto.SHA256)
+//
+// MD5, SHA1, SHA224, SHA256, SHA384, SHA512, SHA512_224 and SHA512_256 are
+// always available.
+func (d Map) CanonicalHash(algorithm crypto.Hash) (string, error) {
+
+	if !algorithm.Available() {
+		return "", errors.New("Map: Hash algorithm is not available.")
+	}
+
+	canonical, err := d.CanonicalJSON()
+	if err != nil {
+		return "", err
+	}
+
+	h := algorithm.New()
+	h.Write([]byte(canonical))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CanonicalHashWithKey gets the hex encoded HMAC of the map's CanonicalJSON,
+// using the specified algorithm and security key.
+func (d Map) CanonicalHashWithKey(algorithm crypto.Hash, key string) (string, error) {
+
+	if !algorithm.Available() {
+		return "", errors.New("Map: Hash algorithm is not available.")
+	}
+
+	canonical, err := d.CanonicalJSON()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(algorithm.New, []byte(key))
+	mac.Write([]byte(canonical))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// writeCanonical writes the canonical JSON for the value.
+func writeCanonical(buf *bytes.Buffer, value interface{}) error {
+
+	if m, ok := asMap(value); ok {
+
+		if m == nil {
+			buf.WriteString("null")
+			return nil
+		}
+
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, m[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+		return nil
+	}
+
+	if length, ok := sliceLen(value); ok {
+
+		buf.WriteByte('[')
+		for i := 0; i < length; i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, sliceItem(value, i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+		return nil
+	}
+
+	switch value.(type) {
+	case nil:
+		buf.WriteString("null")
+		return nil
+	case bool:
+		buf.WriteString(strconv.FormatBool(value.(bool)))
+		return nil
+	case string:
+		writeCanonicalString(buf, value.(string))
+		return nil
+	}
+
+	if f, ok := numberValue(value); ok {
+		return writeCanonicalNumber(buf, f)
+	}
+
+	// anything else is converted to plain JSON values first
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return errors.New("Map: Canonical JSON encode failed with: " + err.Error())
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return errors.New("Map: Canonical JSON encode failed with: " + err.Error())
+	}
+
+	return writeCanonical(buf, decoded)
+}
+
+// writeCanonicalNumber writes the number the way ECMAScript would.
+func writeCanonicalNumber(buf *bytes.Buffer, f float64) error {
+
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("Map: Canonical JSON cannot encode number %v.", f)
+	}
+
+	if f == 0 {
+		// including negative zero
+		buf.WriteByte('0')
+		return nil
+	}
+
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		buf.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+		return nil
+	}
+
+	// ECMAScript writes exponents without leading zeros, like 1e-7 and 1e+21
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	exponent := bytes.IndexByte([]byte(s), 'e')
+	mantissa, sign, digits := s[:exponent], s[exponent+1], s[exponent+2:]
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+
+	buf.WriteString(mantissa)
+	buf.WriteByte('e')
+	buf.WriteByte(sign)
+	buf.WriteString(digits)
+
+	return nil
+}
+
+// writeCanonicalString writes the string, only escaping what JSON requires.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+
+	buf.WriteByte('"')
+
+	for i := 0; i < len(s); {
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == '"':
+			buf.WriteString(`\"`)
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\b':
+			buf.WriteString(`\b`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(buf, `\u%04x`, r)
+		case r == utf8.RuneError && size == 1:
+			// invalid UTF-8 is replaced, as encoding/json does
+			buf.WriteString("�")
+		default:
+			buf.WriteString(s[i : i+size])
+		}
+
+		i += size
+	}
+
+	buf.WriteByte('"')
+}
+
+// lessUTF16 compares strings by their UTF-16 code units, which is the order
+// RFC 8785 sorts keys in.
+func lessUTF16(a, b string) bool {
+
+	aUnits := utf16.Encode([]rune(a))
+	bUnits := utf16.Encode([]rune(b))
+
+	for i := 0; i < len(aUnits) && i < len(bUnits); i++ {
+		if aUnits[i] != bUnits[i] {
+			return aUnits[i] < bUnits[i]
+		}
+	}
+
+	return len(aUnits) < len(bUnits)
+}
@@ -0,0 +1,157 @@
+package objects
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+
+	m := M("b", 2, "a", M("z", []interface{}{true, nil, "x"}, "y", 1.5), "c", "<&>")
+
+	canonical, err := m.CanonicalJSON()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"a":{"y":1.5,"z":[true,null,"x"]},"b":2,"c":"<&>"}`, canonical)
+	}
+
+}
+
+func TestCanonicalJSON_Numbers(t *testing.T) {
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{1, "1"},
+		{int64(1), "1"},
+		{uint8(1), "1"},
+		{1.0, "1"},
+		{float32(0.5), "0.5"},
+		{math.Copysign(0, -1), "0"},
+		{-1.25, "-1.25"},
+		{1e20, "100000000000000000000"},
+		{1e21, "1e+21"},
+		{0.000001, "0.000001"},
+		{0.0000001, "1e-7"},
+		{1.5e-10, "1.5e-10"},
+		{333333333.33333329, "333333333.3333333"},
+		{4.50, "4.5"},
+		{2e-3, "0.002"},
+		{0.000000000000000000000000001, "1e-27"},
+		{math.MaxFloat64, "1.7976931348623157e+308"},
+	}
+
+	for _, test := range tests {
+		canonical, err := M("n", test.value).CanonicalJSON()
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"n":`+test.expected+`}`, canonical, "%v", test.value)
+		}
+	}
+
+	_, err := M("n", math.NaN()).CanonicalJSON()
+	assert.Error(t, err)
+	_, err = M("n", math.Inf(1)).CanonicalJSON()
+	assert.Error(t, err)
+
+}
+
+func TestCanonicalJSON_Strings(t *testing.T) {
+
+	canonical, err := M("s", "\"\\\b\f\n\r\t\x01\x1f é€😀</script>").CanonicalJSON()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"s":"\"\\\b\f\n\r\t\u0001\u001f`+" é€😀</script>"+`"}`, canonical)
+	}
+
+}
+
+func TestCanonicalJSON_KeyOrder(t *testing.T) {
+
+	// keys are sorted by UTF-16 code units, so U+1F600 (surrogates D83D DE00)
+	// comes before U+FB33 even though its UTF-8 bytes sort after
+	m := M("דּ", 1, "\U0001f600", 2, "é", 3, "a", 4, "A", 5, "aa", 6)
+
+	canonical, err := m.CanonicalJSON()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "{\"A\":5,\"a\":4,\"aa\":6,\"é\":3,\"\U0001f600\":2,\"דּ\":1}", canonical)
+	}
+
+}
+
+func TestCanonicalJSON_OtherTypes(t *testing.T) {
+
+	when := time.Date(2013, 8, 1, 12, 0, 0, 0, time.UTC)
+	m := M("when", when,
+		"tags", []string{"b", "a"},
+		"maps", []map[string]interface{}{{"y": 1, "x": 2}},
+		"struct", struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+		}{"stew", 2})
+
+	canonical, err := m.CanonicalJSON()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"maps":[{"x":2,"y":1}],"struct":{"count":2,"name":"stew"},"tags":["b","a"],"when":"2013-08-01T12:00:00Z"}`, canonical)
+	}
+
+}
+
+func TestCanonicalHash(t *testing.T) {
+
+	a := M("id", 1, "price", 2.5, "name", M("first", "Mat"))
+	b, _ := NewMapFromJSON(`{"name": {"first": "Mat"}, "price": 2.50, "id": 1.0}`)
+
+	hashA, err := a.CanonicalHash(crypto.SHA256)
+	if assert.NoError(t, err) {
+
+		hashB, _ := b.CanonicalHash(crypto.SHA256)
+		assert.Equal(t, hashA, hashB)
+
+		sum := sha256.Sum256([]byte(`{"id":1,"name":{"first":"Mat"},"price":2.5}`))
+		assert.Equal(t, hex.EncodeToString(sum[:]), hashA)
+
+	}
+
+	hash512, err := a.CanonicalHash(crypto.SHA512)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 128, len(hash512))
+	}
+
+	different, _ := M("id", 2).CanonicalHash(crypto.SHA256)
+	assert.NotEqual(t, hashA, different)
+
+	_, err = a.CanonicalHash(crypto.RIPEMD160)
+	assert.Error(t, err)
+
+	_, err = M("n", math.NaN()).CanonicalHash(crypto.SHA256)
+	assert.Error(t, err)
+
+}
+
+func TestCanonicalHashWithKey(t *testing.T) {
+
+	a := M("id", 1)
+	b := M("id", 1.0)
+
+	hashA, err := a.CanonicalHashWithKey(crypto.SHA256, "key")
+	if assert.NoError(t, err) {
+
+		hashB, _ := b.CanonicalHashWithKey(crypto.SHA256, "key")
+		assert.Equal(t, hashA, hashB)
+
+		otherKey, _ := a.CanonicalHashWithKey(crypto.SHA256, "other")
+		assert.NotEqual(t, hashA, otherKey)
+
+		noKey, _ := a.CanonicalHash(crypto.SHA256)
+		assert.NotEqual(t, hashA, noKey)
+
+	}
+
+	_, err = a.CanonicalHashWithKey(crypto.Hash(0), "key")
+	assert.Error(t, err)
+
+}
@@ -0,0 +1,305 @@
+package objects
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// normalizeValue converts maps decoded by other formats into Map, so
+// keypaths work the same way whichever format the data came from.
+//
+// Maps with keys that are not strings, like map[interface{}]interface{}
+// from YAML or MessagePack, have their keys formatted as strings.
+func normalizeValue(value interface{}) interface{} {
+
+	switch value.(type) {
+	case Map:
+		return normalizeMap(value.(Map))
+	case map[string]interface{}:
+		return normalizeMap(value.(map[string]interface{}))
+	case map[interface{}]interface{}:
+		m := make(Map, len(value.(map[interface{}]interface{})))
+		for k, v := range value.(map[interface{}]interface{}) {
+			m[fmt.Sprint(k)] = normalizeValue(v)
+		}
+		return m
+	case []interface{}:
+		items := value.([]interface{})
+		for i, item := range items {
+			items[i] = normalizeValue(item)
+		}
+		return items
+	case []map[string]interface{}:
+		items := make([]interface{}, len(value.([]map[string]interface{})))
+		for i, item := range value.([]map[string]interface{}) {
+			items[i] = normalizeMap(item)
+		}
+		return items
+	}
+
+	return value
+}
+
+// normalizeMap converts the map, and every map inside it, into Map.
+func normalizeMap(m map[string]interface{}) Map {
+
+	for k, v := range m {
+		m[k] = normalizeValue(v)
+	}
+
+	return Map(m)
+}
+
+/*
+	YAML
+	------------------------------------------------
+*/
+
+// NewMapFromYAML creates a new map from a YAML string representation.
+//
+// Nested maps are created as Map objects.  An empty document creates an
+// empty map.
+func NewMapFromYAML(data string) (Map, error) {
+	return NewMapFromYAMLWithOptions(data, DecodeOptions{})
+}
+
+// NewMapFromYAMLWithOptions creates a new map from a YAML string representation,
+// checking it against the limits in the options.
+//
+// The limits other than MaxBytes are checked once the whole document has
+// been decoded.  YAML never allows duplicate keys, whatever the options.
+func NewMapFromYAMLWithOptions(data string, options DecodeOptions) (Map, error) {
+
+	if err := options.checkBytes(len(data)); err != nil {
+		return nil, err
+	}
+
+	var unmarshalled map[string]interface{}
+
+	if err := yaml.Unmarshal([]byte(data), &unmarshalled); err != nil {
+		return nil, errors.New("Map: YAML decode failed with: " + err.Error())
+	}
+
+	if unmarshalled == nil {
+		return Map{}, nil
+	}
+
+	m := normalizeMap(unmarshalled)
+	if err := options.checkMap(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+
+}
+
+// YAML converts the map to a YAML string.
+func (d Map) YAML() (yamlString string, err error) {
+
+	// yaml panics on values it cannot encode, like funcs
+	defer func() {
+		if r := recover(); r != nil {
+			yamlString, err = "", fmt.Errorf("Map: YAML encode failed with: %v", r)
+		}
+	}()
+
+	result, err := yaml.Marshal(d)
+
+	if err != nil {
+		return "", errors.New("Map: YAML encode failed with: " + err.Error())
+	}
+
+	return string(result), nil
+
+}
+
+/*
+	TOML
+	------------------------------------------------
+*/
+
+// NewMapFromTOML creates a new map from a TOML string representation.
+//
+// Tables are created as Map objects, and arrays of tables as []interface{}
+// holding Map objects.
+func NewMapFromTOML(data string) (Map, error) {
+	return NewMapFromTOMLWithOptions(data, DecodeOptions{})
+}
+
+// NewMapFromTOMLWithOptions creates a new map from a TOML string representation,
+// checking it against the limits in the options.
+//
+// The limits other than MaxBytes are checked once the whole document has
+// been decoded.  TOML never allows duplicate keys, whatever the options.
+func NewMapFromTOMLWithOptions(data string, options DecodeOptions) (Map, error) {
+
+	if err := options.checkBytes(len(data)); err != nil {
+		return nil, err
+	}
+
+	var unmarshalled map[string]interface{}
+
+	if _, err := toml.Decode(data, &unmarshalled); err != nil {
+		return nil, errors.New("Map: TOML decode failed with: " + err.Error())
+	}
+
+	if unmarshalled == nil {
+		return Map{}, nil
+	}
+
+	m := normalizeMap(unmarshalled)
+	if err := options.checkMap(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+
+}
+
+// TOML converts the map to a TOML string.
+//
+// TOML has no null, so nil values are left out.
+func (d Map) TOML() (string, error) {
+
+	var buf bytes.Buffer
+
+	if err := toml.NewEncoder(&buf).Encode(d); err != nil {
+		return "", errors.New("Map: TOML encode failed with: " + err.Error())
+	}
+
+	return buf.String(), nil
+
+}
+
+/*
+	MessagePack
+	------------------------------------------------
+*/
+
+// NewMapFromMessagePack creates a new map from MessagePack encoded data.
+//
+// Nested maps are created as Map objects.  A nil document creates an empty
+// map.  Numbers keep the smallest type they were encoded with, so use the
+// typed getters, like GetInt, to read them.
+func NewMapFromMessagePack(data []byte) (Map, error) {
+	return NewMapFromMessagePackWithOptions(data, DecodeOptions{})
+}
+
+// NewMapFromMessagePackWithOptions creates a new map from MessagePack encoded
+// data, checking it against the limits in the options.
+//
+// The limits other than MaxBytes and DisallowDuplicateKeys are checked once
+// the whole document has been decoded.
+func NewMapFromMessagePackWithOptions(data []byte, options DecodeOptions) (Map, error) {
+
+	if err := options.checkBytes(len(data)); err != nil {
+		return nil, err
+	}
+
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return decodeMessagePackMap(d, options)
+	})
+
+	unmarshalled, err := decoder.DecodeInterface()
+	if _, isDuplicate := err.(*DuplicateKeyError); isDuplicate {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.New("Map: MessagePack decode failed with: " + err.Error())
+	}
+
+	if unmarshalled == nil {
+		return Map{}, nil
+	}
+
+	m, ok := normalizeValue(unmarshalled).(Map)
+	if !ok {
+		return nil, fmt.Errorf("Map: MessagePack data is %T, not a map.", unmarshalled)
+	}
+
+	if err := options.checkMap(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+
+}
+
+// decodeMessagePackMap decodes a map, with its keys formatted as strings.
+// Keys that are not strings or numbers, like maps, are an error, since the
+// data may not be trusted.
+//
+// If the options disallow duplicate keys, a *DuplicateKeyError is returned
+// when a key appears more than once.  The keypath is only the key itself,
+// since the decoder doesn't know where the map is.
+func decodeMessagePackMap(d *msgpack.Decoder, options DecodeOptions) (interface{}, error) {
+
+	n, err := d.DecodeMapLen()
+	if err != nil || n == -1 {
+		return nil, err
+	}
+
+	// the length comes from the data, so don't trust it for the size
+	size := n
+	if size > 64 {
+		size = 64
+	}
+
+	m := make(map[string]interface{}, size)
+	for i := 0; i < n; i++ {
+
+		k, err := d.DecodeInterface()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := messagePackKey(k)
+		if !ok {
+			return nil, fmt.Errorf("map key is %T, not a string or number", k)
+		}
+
+		v, err := d.DecodeInterface()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := m[key]; exists && options.DisallowDuplicateKeys {
+			return nil, &DuplicateKeyError{Keypath: EscapeKey(key)}
+		}
+		m[key] = v
+
+	}
+
+	return m, nil
+}
+
+// messagePackKey formats a decoded MessagePack map key as a string, if it
+// is a string or a number.
+func messagePackKey(k interface{}) (string, bool) {
+
+	switch k.(type) {
+	case string:
+		return k.(string), true
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(k), true
+	}
+
+	return "", false
+}
+
+// MessagePack converts the map to MessagePack encoded data.
+func (d Map) MessagePack() ([]byte, error) {
+
+	result, err := msgpack.Marshal(d)
+
+	if err != nil {
+		return nil, errors.New("Map: MessagePack encode failed with: " + err.Error())
+	}
+
+	return result, nil
+
+}
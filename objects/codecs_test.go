@@ -0,0 +1,175 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewMapFromYAML(t *testing.T) {
+
+	m, err := NewMapFromYAML(`
+name: stew
+owner:
+  first: Mat
+  last: Ryer
+ports:
+  1: http
+  2: https
+tags:
+  - name: a
+  - name: b
+created: 2013-08-01T12:00:00Z
+empty: ~
+`)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "stew", m.Get("name"))
+		assert.Equal(t, "Mat", m.Get("owner.first"))
+		assert.IsType(t, Map{}, m.Get("owner"))
+		assert.Equal(t, "https", m.Get("ports.2"))
+		assert.IsType(t, Map{}, m.Get("ports"))
+		assert.Equal(t, "b", m.Get("tags[1].name"))
+		assert.IsType(t, Map{}, m.Get("tags[0]"))
+		assert.Equal(t, time.Date(2013, 8, 1, 12, 0, 0, 0, time.UTC), m.MustTime("created"))
+		assert.Contains(t, m, "empty")
+		assert.Nil(t, m.Get("empty"))
+
+		m.Set("owner.email", "mat@example.com")
+		assert.Equal(t, "mat@example.com", m.Get("owner.email"))
+	}
+
+	m, err = NewMapFromYAML("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Map{}, m)
+	}
+
+	_, err = NewMapFromYAML("- a\n- b\n")
+	assert.Error(t, err)
+
+	_, err = NewMapFromYAML("a: [")
+	assert.Error(t, err)
+
+}
+
+func TestMapYAML(t *testing.T) {
+
+	m := M("name", "stew", "owner", M("first", "Mat"), "tags", []interface{}{"a", "b"})
+
+	yamlString, err := m.YAML()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "name: stew\nowner:\n    first: Mat\ntags:\n    - a\n    - b\n", yamlString)
+
+		decoded, err := NewMapFromYAML(yamlString)
+		if assert.NoError(t, err) {
+			assert.Equal(t, m, decoded)
+		}
+	}
+
+	_, err = M("f", func() {}).YAML()
+	assert.Error(t, err)
+
+}
+
+func TestNewMapFromTOML(t *testing.T) {
+
+	m, err := NewMapFromTOML(`
+name = "stew"
+port = 8080
+
+[owner]
+first = "Mat"
+
+[[servers]]
+host = "alpha"
+
+[[servers]]
+host = "beta"
+`)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "stew", m.Get("name"))
+		assert.Equal(t, 8080, m.MustInt("port"))
+		assert.Equal(t, "Mat", m.Get("owner.first"))
+		assert.IsType(t, Map{}, m.Get("owner"))
+		assert.Equal(t, "beta", m.Get("servers[1].host"))
+		assert.IsType(t, Map{}, m.Get("servers[0]"))
+
+		m.Set("servers[0].port", 80)
+		assert.Equal(t, 80, m.Get("servers[0].port"))
+	}
+
+	m, err = NewMapFromTOML("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Map{}, m)
+	}
+
+	_, err = NewMapFromTOML("name = ")
+	assert.Error(t, err)
+
+}
+
+func TestMapTOML(t *testing.T) {
+
+	m := M("name", "stew", "missing", nil, "owner", M("first", "Mat"))
+
+	tomlString, err := m.TOML()
+	if assert.NoError(t, err) {
+		decoded, err := NewMapFromTOML(tomlString)
+		if assert.NoError(t, err) {
+			assert.Equal(t, M("name", "stew", "owner", M("first", "Mat")), decoded)
+		}
+	}
+
+	_, err = M("mixed", []interface{}{1, M("a", 1)}, "f", func() {}).TOML()
+	assert.Error(t, err)
+
+}
+
+func TestMapMessagePack(t *testing.T) {
+
+	m := M("name", "stew", "count", 3, "price", 2.5, "owner", M("first", "Mat"),
+		"tags", []interface{}{"a", M("b", true)}, "none", nil,
+		"ports", map[interface{}]interface{}{1: "http"})
+
+	data, err := m.MessagePack()
+	if assert.NoError(t, err) {
+
+		decoded, err := NewMapFromMessagePack(data)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "stew", decoded.Get("name"))
+			assert.Equal(t, 3, decoded.MustInt("count"))
+			assert.Equal(t, 2.5, decoded.MustFloat64("price"))
+			assert.Equal(t, "Mat", decoded.Get("owner.first"))
+			assert.IsType(t, Map{}, decoded.Get("owner"))
+			assert.Equal(t, true, decoded.Get("tags[1].b"))
+			assert.Equal(t, "http", decoded.Get("ports.1"))
+			assert.Contains(t, decoded, "none")
+		}
+
+	}
+
+	// nil, like an empty YAML or TOML document, is an empty map
+	empty, err := NewMapFromMessagePack([]byte{0xc0})
+	if assert.NoError(t, err) {
+		assert.Equal(t, Map{}, empty)
+	}
+
+	_, err = NewMapFromMessagePack([]byte{0x01})
+	assert.Error(t, err)
+
+	_, err = NewMapFromMessagePack([]byte{0x81})
+	assert.Error(t, err)
+
+	// {{"a": 1}: 2}; keys that can't be map keys are an error, not a panic
+	for _, options := range []DecodeOptions{{}, {DisallowDuplicateKeys: true}} {
+		_, err = NewMapFromMessagePackWithOptions([]byte{0x81, 0x81, 0xa1, 'a', 0x01, 0x02}, options)
+		if assert.Error(t, err) {
+			assert.Equal(t, "Map: MessagePack decode failed with: map key is map[string]interface {}, not a string or number", err.Error())
+		}
+	}
+
+	_, err = M("f", func() {}).MessagePack()
+	assert.Error(t, err)
+
+}
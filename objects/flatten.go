@@ -0,0 +1,180 @@
+package objects
+
+import (
+	"errors"
+	"sort"
+)
+
+// flatKey makes the flat key for a key within the parent flat key.
+func flatKey(parent, key, separator string) string {
+	if parent == "" {
+		return escapeKey(key, separator)
+	}
+	return parent + separator + escapeKey(key, separator)
+}
+
+// Flatten builds a new map with no nesting, where each key is the path to a
+// value in this map, with keys separated by sep.
+//
+// For example:
+//
+//     objects.M("a", objects.M("b", 1), "tags", []interface{}{"x", "y"}).Flatten("_")
+//     // returns Map{"a_b": 1, "tags[0]": "x", "tags[1]": "y"}
+//
+// Slice items are written with indexes in square brackets, and any part of
+// a key that would be mistaken for a separator or index is escaped with
+// PathEscape, so with a sep of PathSeparator every key is a keypath that
+// can be given to Get.  Empty maps and slices are kept as they are, so that
+// Unflatten can rebuild them.
+//
+// If sep is empty, PathSeparator is used.
+func (d Map) Flatten(sep string) Map {
+
+	if sep == "" {
+		sep = PathSeparator
+	}
+
+	flat := make(Map)
+	flattenInto(flat, "", d, sep)
+
+	return flat
+}
+
+// flattenInto adds the values inside the map or slice to the flat map.
+func flattenInto(flat Map, prefix string, value interface{}, sep string) {
+
+	if m, ok := asMap(value); ok {
+		for k, v := range m {
+			flattenValue(flat, flatKey(prefix, k, sep), v, sep)
+		}
+		return
+	}
+
+	length, _ := sliceLen(value)
+	for i := 0; i < length; i++ {
+		flattenValue(flat, indexKeypath(prefix, i), sliceItem(value, i), sep)
+	}
+
+}
+
+// flattenValue adds the value to the flat map, or the values inside it if it
+// is a map or slice that isn't empty.
+func flattenValue(flat Map, key string, value interface{}, sep string) {
+
+	if m, ok := asMap(value); ok && len(m) > 0 {
+		flattenInto(flat, key, m, sep)
+		return
+	}
+
+	if length, ok := sliceLen(value); ok && length > 0 {
+		flattenInto(flat, key, value, sep)
+		return
+	}
+
+	flat[key] = value
+
+}
+
+// Unflatten builds a nested map from a flat one, like the ones made by
+// Flatten, where each key is a path with keys separated by sep.
+//
+// Paths are followed the same way as Set follows keypaths; maps are created as
+// needed, and indexes in square brackets create and grow slices.
+//
+// An error is returned if the keys conflict, for example if one key is a
+// value and another puts values inside it (`a` and `a.b`), or one treats a
+// value as a map and another as a slice (`a.b` and `a[0]`).
+//
+// Indexes may be no larger than DefaultMaxIndex; use UnflattenWithOptions to
+// change the limits.
+//
+// If sep is empty, PathSeparator is used.
+func Unflatten(flat Map, sep string) (Map, error) {
+	return UnflattenWithOptions(flat, sep, DecodeOptions{})
+}
+
+// UnflattenWithOptions builds a nested map from a flat one, as Unflatten
+// does, checking the keys against the limits in the options.  MaxKeys counts
+// the flat keys, MaxDepth the parts of each key and MaxIndex its indexes, so
+// that flat data from outside, like environment variables, can't make huge
+// slices.
+func UnflattenWithOptions(flat Map, sep string, options DecodeOptions) (Map, error) {
+
+	if err := options.checkKeys(len(flat)); err != nil {
+		return nil, err
+	}
+
+	if sep == "" {
+		sep = PathSeparator
+	}
+
+	// apply keys in order, so slices grow the same way each time
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// leaves and parents record which flat key set each path, by its keypath
+	leaves := make(map[string]string)
+	parents := make(map[string]string)
+	parentIsSlice := make(map[string]bool)
+
+	m := make(Map)
+
+	for _, k := range keys {
+
+		segs := parsePath(k, sep)
+		if len(segs) == 0 || segs[0].isIndex {
+			return nil, errors.New("Map: Flat key '" + k + "' must start with a name.")
+		}
+		if err := options.checkDepth(len(segs)); err != nil {
+			return nil, err
+		}
+
+		keypath := ""
+		for i, seg := range segs {
+
+			if seg.isIndex && seg.index < 0 {
+				return nil, errors.New("Map: Flat key '" + k + "' has a negative index.")
+			}
+			if seg.isIndex {
+				if err := options.checkIndex(seg.index); err != nil {
+					return nil, err
+				}
+			}
+
+			if seg.isIndex {
+				keypath = indexKeypath(keypath, seg.index)
+			} else {
+				keypath = childKeypath(keypath, seg.key)
+			}
+
+			if other, exists := leaves[keypath]; exists {
+				return nil, errors.New("Map: Flat key '" + k + "' conflicts with '" + other + "'.")
+			}
+
+			if i == len(segs)-1 {
+				if other, exists := parents[keypath]; exists {
+					return nil, errors.New("Map: Flat key '" + k + "' conflicts with '" + other + "'.")
+				}
+				leaves[keypath] = k
+				break
+			}
+
+			isSlice := segs[i+1].isIndex
+			if other, exists := parents[keypath]; exists && parentIsSlice[keypath] != isSlice {
+				return nil, errors.New("Map: Flat key '" + k + "' conflicts with '" + other + "'.")
+			} else if !exists {
+				parents[keypath] = k
+				parentIsSlice[keypath] = isSlice
+			}
+
+		}
+
+		m[segs[0].key] = setPath(m[segs[0].key], segs[1:], flat[k])
+
+	}
+
+	return m, nil
+}
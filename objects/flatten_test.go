@@ -0,0 +1,147 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMapFlatten(t *testing.T) {
+
+	m := M("name", "stew",
+		"owner", M("first", "Mat", "address", M("city", "Boulder")),
+		"tags", []interface{}{"a", M("b", 1), []interface{}{true}},
+		"files", M("readme.md", 10),
+		"empty", M(),
+		"none", []interface{}{},
+		"strings", []string{"x"})
+
+	flat := m.Flatten(".")
+
+	assert.Equal(t, M("name", "stew",
+		"owner.first", "Mat",
+		"owner.address.city", "Boulder",
+		"tags[0]", "a",
+		"tags[1].b", 1,
+		"tags[2][0]", true,
+		`files.readme\.md`, 10,
+		"empty", M(),
+		"none", []interface{}{},
+		"strings[0]", "x"), flat)
+
+	// every flat key is a keypath into the original
+	for k, v := range flat {
+		assert.Equal(t, v, m.Get(k), k)
+	}
+
+	assert.Equal(t, flat, m.Flatten(""))
+
+	assert.Equal(t, M("owner_first", "Mat", "owner_address_city", "Boulder", "tags[0]", "x", `odd\_key`, 1, `a\[0]`, 2),
+		M("owner", M("first", "Mat", "address", M("city", "Boulder")), "tags", []string{"x"}, "odd_key", 1, "a[0]", 2).Flatten("_"))
+
+}
+
+func TestUnflatten(t *testing.T) {
+
+	m, err := Unflatten(M("name", "stew",
+		"owner.first", "Mat",
+		"owner.address.city", "Boulder",
+		"tags[1].b", 1,
+		"tags[0]", "a",
+		`files.readme\.md`, 10,
+		"empty", M()), ".")
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, M("name", "stew",
+			"owner", M("first", "Mat", "address", M("city", "Boulder")),
+			"tags", []interface{}{"a", M("b", 1)},
+			"files", M("readme.md", 10),
+			"empty", M()), m)
+	}
+
+	// missing indexes are filled with nil, like Set
+	m, err = Unflatten(M("a[2]", 1), "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, M("a", []interface{}{nil, nil, 1}), m)
+	}
+
+	m, err = Unflatten(M("DB__HOST", "localhost", "DB__PORT", 5432, "DB__REPLICAS[0]__HOST", "r1"), "__")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "localhost", m.Get("DB.HOST"))
+		assert.Equal(t, "r1", m.Get("DB.REPLICAS[0].HOST"))
+	}
+
+}
+
+func TestUnflattenWithOptions(t *testing.T) {
+
+	// huge indexes are always rejected
+	_, err := Unflatten(M("a[1000000000]", 1), "")
+	assert.Equal(t, &LimitError{Limit: LimitIndex, Max: DefaultMaxIndex}, err)
+
+	m, err := UnflattenWithOptions(M("a[5000]", 1), "", DecodeOptions{MaxIndex: 5000})
+	if assert.NoError(t, err) {
+		assert.Len(t, m["a"], 5001)
+	}
+
+	_, err = UnflattenWithOptions(M("a[3]", 1), "", DecodeOptions{MaxKeys: 3})
+	assert.Equal(t, &LimitError{Limit: LimitIndex, Max: 2}, err)
+
+	_, err = UnflattenWithOptions(M("a", 1, "b", 2), "", DecodeOptions{MaxKeys: 1})
+	assert.Equal(t, &LimitError{Limit: LimitKeys, Max: 1}, err)
+
+	_, err = UnflattenWithOptions(M("a.b.c", 1), "", DecodeOptions{MaxDepth: 2})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 2}, err)
+
+}
+
+func TestUnflatten_RoundTrip(t *testing.T) {
+
+	m := M("name", "stew",
+		"owner", M("first", "Mat", "odd.key_name", M("x[0]", 1)),
+		"tags", []interface{}{"a", M("b", 1), []interface{}{true, nil}},
+		"empty", M(),
+		"none", []interface{}{})
+
+	for _, sep := range []string{".", "_", "/", "__"} {
+		unflattened, err := Unflatten(m.Flatten(sep), sep)
+		if assert.NoError(t, err, sep) {
+			assert.Equal(t, m, unflattened, sep)
+		}
+	}
+
+}
+
+func TestUnflatten_Conflicts(t *testing.T) {
+
+	_, err := Unflatten(M("a", 1, "a.b", 2), ".")
+	if assert.Error(t, err) {
+		assert.Equal(t, "Map: Flat key 'a.b' conflicts with 'a'.", err.Error())
+	}
+
+	_, err = Unflatten(M("a.b.c", 1, "a.b", 2), ".")
+	if assert.Error(t, err) {
+		assert.Equal(t, "Map: Flat key 'a.b.c' conflicts with 'a.b'.", err.Error())
+	}
+
+	_, err = Unflatten(M("a.b", 1, "a[0]", 2), ".")
+	if assert.Error(t, err) {
+		assert.Equal(t, "Map: Flat key 'a[0]' conflicts with 'a.b'.", err.Error())
+	}
+
+	_, err = Unflatten(M("a[0]", 1, "a[00]", 2), ".")
+	assert.Error(t, err)
+
+	_, err = Unflatten(M("a[0]", nil, "a[0].b", 2), ".")
+	assert.Error(t, err)
+
+	_, err = Unflatten(M("[0]", 1), ".")
+	assert.Error(t, err)
+
+	_, err = Unflatten(M("a[-1]", 1), ".")
+	assert.Error(t, err)
+
+	// siblings are fine
+	_, err = Unflatten(M("a.b", 1, "a.c", 2, "a.d[0]", 3, "a.d[1]", 4), ".")
+	assert.NoError(t, err)
+
+}
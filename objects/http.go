@@ -0,0 +1,378 @@
+package objects
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stewstrings "github.com/stretchr/stew/strings"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RequestSource is a part of a request that FromRequest reads values from.
+type RequestSource int
+
+const (
+	// RequestQuery is the URL query.
+	RequestQuery RequestSource = iota
+	// RequestBody is the body; a JSON object, a URL encoded form or a
+	// multipart form.
+	RequestBody
+	// RequestPath is the values a router took from the path, given in
+	// RequestOptions.PathParams.
+	RequestPath
+)
+
+const (
+	// DefaultRequestMaxBodyBytes is the largest body FromRequest reads if
+	// RequestOptions.MaxBodyBytes is not set.
+	DefaultRequestMaxBodyBytes int = 10 << 20
+	// DefaultRequestMaxMemory is the most of a multipart form FromRequest
+	// keeps in memory if RequestOptions.MaxMemory is not set.  The rest of
+	// any files are stored on disk.
+	DefaultRequestMaxMemory int64 = 32 << 20
+)
+
+// defaultRequestPrecedence is the order sources are merged in if
+// RequestOptions.Precedence is not set.
+var defaultRequestPrecedence = []RequestSource{RequestQuery, RequestBody, RequestPath}
+
+// RequestOptions controls how FromRequest reads a request.  The zero value
+// reads the query and body, applies the default limits and leaves query and
+// form values as strings.
+type RequestOptions struct {
+	// PathParams are the values a router took from the path, like
+	// {"id": "42"} for "/users/{id}".
+	PathParams map[string]string
+	// Precedence lists the sources to merge, from lowest to highest
+	// precedence; where sources have the same key, the later one wins.
+	// Maps from different sources are merged together.  If it is not set,
+	// the query, then the body, then the path are used, so a value in the
+	// path can't be overridden.
+	Precedence []RequestSource
+	// MaxBodyBytes is the largest body read.  Larger bodies cause a
+	// *LimitError.  If it is not set, DefaultRequestMaxBodyBytes is used.
+	MaxBodyBytes int
+	// MaxMemory is the most of a multipart form kept in memory.  If it is not
+	// set, DefaultRequestMaxMemory is used.
+	MaxMemory int64
+	// URLQuery controls how the query, URL encoded forms and the values in
+	// multipart forms are decoded.  With ParseValues set, path parameters
+	// are also turned into native types using strings.Parse.
+	URLQuery URLQueryOptions
+	// JSON has the limits for JSON bodies.
+	JSON DecodeOptions
+}
+
+// maxBodyBytes gets the MaxBodyBytes, or the default.
+func (o RequestOptions) maxBodyBytes() int {
+	if o.MaxBodyBytes > 0 {
+		return o.MaxBodyBytes
+	}
+	return DefaultRequestMaxBodyBytes
+}
+
+// maxMemory gets the MaxMemory, or the default.
+func (o RequestOptions) maxMemory() int64 {
+	if o.MaxMemory > 0 {
+		return o.MaxMemory
+	}
+	return DefaultRequestMaxMemory
+}
+
+// FromRequest creates a new map from the values in a request; its path
+// parameters, URL query and body.
+//
+// For example:
+//
+//     func UpdateUser(w http.ResponseWriter, r *http.Request) {
+//         params, err := objects.FromRequest(r, objects.RequestOptions{
+//             PathParams: map[string]string{"id": r.PathValue("id")},
+//             URLQuery:   objects.URLQueryOptions{ParseValues: true},
+//         })
+//         ...
+//     }
+//
+// The body is read according to its Content-Type:
+//
+//     application/json (or any +json type)   a JSON object
+//     application/x-www-form-urlencoded      a URL encoded form
+//     multipart/form-data                    a multipart form
+//
+// Files in multipart forms are not read; instead the field holds a Map with
+// their "filename", "size" and "content_type", or a slice of them if there
+// is more than one.  Use r.MultipartForm to open them.  Bodies without a
+// Content-Type are ignored, and other types are an error.
+//
+// JSON and URL encoded bodies are put back once they are read, so the
+// handler may read them again.
+func FromRequest(r *http.Request, options RequestOptions) (Map, error) {
+
+	precedence := options.Precedence
+	if len(precedence) == 0 {
+		precedence = defaultRequestPrecedence
+	}
+
+	m := make(Map)
+
+	for _, source := range precedence {
+
+		var values Map
+		var err error
+
+		switch source {
+		case RequestQuery:
+			values, err = NewMapFromURLValuesWithOptions(r.URL.Query(), options.URLQuery)
+		case RequestBody:
+			values, err = requestBody(r, options)
+		case RequestPath:
+			values = make(Map, len(options.PathParams))
+			for k, v := range options.PathParams {
+				if options.URLQuery.ParseValues {
+					values[k] = stewstrings.Parse(v)
+				} else {
+					values[k] = v
+				}
+			}
+		default:
+			err = fmt.Errorf("Map: Unknown request source %d.", source)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if m, err = m.MergeWith(values, MergeOptions{}); err != nil {
+			return nil, err
+		}
+
+	}
+
+	return m, nil
+}
+
+// requestBody reads the values in the body of the request.
+func requestBody(r *http.Request, options RequestOptions) (Map, error) {
+
+	contentType := r.Header.Get("Content-Type")
+	if r.Body == nil || r.Body == http.NoBody || contentType == "" {
+		return nil, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.New("Map: Content-Type '" + contentType + "' is invalid: " + err.Error() + ".")
+	}
+
+	maxBytes := options.maxBodyBytes()
+
+	switch {
+	case mediaType == "multipart/form-data":
+
+		r.Body = ioutil.NopCloser(&limitedReader{reader: r.Body, remaining: maxBytes, max: maxBytes})
+		if err := r.ParseMultipartForm(options.maxMemory()); err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				return nil, limitErr
+			}
+			return nil, errors.New("Map: Multipart form decode failed with: " + err.Error())
+		}
+
+		values, err := NewMapFromURLValuesWithOptions(url.Values(r.MultipartForm.Value), options.URLQuery)
+		if err != nil {
+			return nil, err
+		}
+
+		for field, headers := range r.MultipartForm.File {
+			files := make([]interface{}, len(headers))
+			for i, header := range headers {
+				files[i] = Map{"filename": header.Filename, "size": header.Size, "content_type": header.Header.Get("Content-Type")}
+			}
+			if len(files) == 1 {
+				values[field] = files[0]
+			} else {
+				values[field] = files
+			}
+		}
+
+		return values, nil
+
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+
+		data, err := readRequestBody(r, maxBytes)
+		if err != nil || len(bytes.TrimSpace(data)) == 0 {
+			return nil, err
+		}
+
+		values, err := NewMapFromJSONWithOptions(string(data), options.JSON)
+		if err != nil {
+			return nil, err
+		}
+
+		return values, nil
+
+	case mediaType == "application/x-www-form-urlencoded":
+
+		data, err := readRequestBody(r, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewMapFromURLQueryWithOptions(string(data), options.URLQuery)
+
+	}
+
+	return nil, errors.New("Map: Cannot read a request body of type '" + mediaType + "'.")
+}
+
+// readRequestBody reads the whole body, up to maxBytes, and puts it back
+// so that it can be read again.
+func readRequestBody(r *http.Request, maxBytes int) ([]byte, error) {
+
+	data, err := ioutil.ReadAll(&limitedReader{reader: r.Body, remaining: maxBytes, max: maxBytes})
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	return data, nil
+}
+
+/*
+	Responses
+	------------------------------------------------
+*/
+
+// DefaultJSONPCallbackParam is the query parameter that asks for a JSONP
+// response if ResponseOptions.CallbackParam is not set.
+const DefaultJSONPCallbackParam string = "callback"
+
+// jsonpCallback matches the JavaScript function names allowed as JSONP
+// callbacks, like "handle" or "app.handlers.user".
+var jsonpCallback = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// acceptsJavaScript gets whether the request's Accept header allows a
+// JavaScript response.  Requests without one accept anything.
+func acceptsJavaScript(r *http.Request) bool {
+
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if quality, err := strconv.ParseFloat(q, 64); err != nil || quality <= 0 {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "*/*", "application/*", "text/*", "application/javascript", "text/javascript":
+			return true
+		}
+
+	}
+
+	return false
+}
+
+// ResponseOptions controls how WriteResponseWithOptions writes a map.
+type ResponseOptions struct {
+	// Request is the request being responded to.  If it has the callback
+	// parameter in its URL query, and its Accept header allows JavaScript,
+	// the response is JSONP.  If it is not set, the response is always JSON.
+	Request *http.Request
+	// CallbackParam is the query parameter holding the JSONP callback; if
+	// it is not set, DefaultJSONPCallbackParam is used.
+	CallbackParam string
+	// JSON controls the format of the JSON.
+	JSON JSONOptions
+}
+
+// WriteResponse writes the map to the response as JSON, with the status
+// code.
+//
+// For example:
+//
+//     objects.M("id", 42, "name", "Mat").WriteResponse(w, http.StatusCreated)
+func (d Map) WriteResponse(w http.ResponseWriter, status int) error {
+	return d.WriteResponseWithOptions(w, status, ResponseOptions{})
+}
+
+// WriteResponseWithOptions writes the map to the response with the status
+// code, as JSON, or as JSONP if the request in the options asks for it.
+//
+// For example, to answer "/users/42?callback=showUser":
+//
+//     user.WriteResponseWithOptions(w, http.StatusOK, objects.ResponseOptions{Request: r})
+//     // writes: /**/showUser({"id":42,"name":"Mat"});
+//
+// The request's Accept header is honoured, so a request with a callback that
+// only accepts application/json is answered with JSON.  Requests with no
+// Accept header accept either.
+//
+// Nothing is written, and an error is returned, if the map cannot be encoded
+// or the callback is not a valid JavaScript function name.
+func (d Map) WriteResponseWithOptions(w http.ResponseWriter, status int, options ResponseOptions) error {
+
+	callback := ""
+	if options.Request != nil {
+		param := options.CallbackParam
+		if param == "" {
+			param = DefaultJSONPCallbackParam
+		}
+		callback = options.Request.URL.Query().Get(param)
+		if callback != "" {
+			// the format depends on what the request accepts
+			w.Header().Add("Vary", "Accept")
+			if !acceptsJavaScript(options.Request) {
+				callback = ""
+			}
+		}
+		if callback != "" && !jsonpCallback.MatchString(callback) {
+			return errors.New("Map: JSONP callback '" + callback + "' is not a valid function name.")
+		}
+	}
+
+	var body bytes.Buffer
+	contentType := "application/json; charset=utf-8"
+
+	if callback != "" {
+		// the comment stops the response being taken for anything but script
+		contentType = "application/javascript; charset=utf-8"
+		body.WriteString("/**/" + callback + "(")
+	}
+
+	if err := d.WriteJSON(&body, options.JSON); err != nil {
+		return err
+	}
+
+	if callback != "" {
+		body.Truncate(body.Len() - 1)
+		body.WriteString(");\n")
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.Itoa(body.Len()))
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	_, err := io.Copy(w, &body)
+
+	return err
+}
@@ -0,0 +1,241 @@
+package objects
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFromRequest_Query(t *testing.T) {
+
+	r := httptest.NewRequest("GET", "/users?name=mat&age=30&tags=a&tags=b&address.city=Boulder", nil)
+
+	m, err := FromRequest(r, RequestOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, M("name", "mat", "age", "30", "tags", []interface{}{"a", "b"}, "address", M("city", "Boulder")), m)
+	}
+
+	m, err = FromRequest(r, RequestOptions{URLQuery: URLQueryOptions{ParseValues: true}, PathParams: map[string]string{"id": "42"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 30, m.Get("age"))
+		assert.Equal(t, 42, m.Get("id"))
+	}
+
+	r = httptest.NewRequest("GET", "/users?user[name]=mat", nil)
+	m, err = FromRequest(r, RequestOptions{URLQuery: URLQueryOptions{Style: URLQueryBrackets}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "mat", m.Get("user.name"))
+	}
+
+}
+
+func TestFromRequest_JSON(t *testing.T) {
+
+	r := httptest.NewRequest("POST", "/users/42?name=query&debug=true&address.zip=80301", strings.NewReader(`{"name":"body","id":1,"address":{"city":"Boulder"}}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	m, err := FromRequest(r, RequestOptions{PathParams: map[string]string{"id": "42"}})
+	if assert.NoError(t, err) {
+
+		// the body beats the query, and the path beats both; maps are merged
+		assert.Equal(t, M("name", "body", "id", "42", "debug", "true", "address", M("city", "Boulder", "zip", "80301")), m)
+
+		// the body can be read again
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, `{"name":"body","id":1,"address":{"city":"Boulder"}}`, string(body))
+
+	}
+
+	// precedence can be changed
+	r = httptest.NewRequest("POST", "/users/42?name=query", strings.NewReader(`{"name":"body"}`))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	m, err = FromRequest(r, RequestOptions{Precedence: []RequestSource{RequestBody, RequestQuery}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, M("name", "query"), m)
+	}
+
+	// empty bodies are fine
+	r = httptest.NewRequest("POST", "/", strings.NewReader(" "))
+	r.Header.Set("Content-Type", "application/json")
+	m, err = FromRequest(r, RequestOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, M(), m)
+	}
+
+	for _, body := range []string{`[1,2]`, `{"name":`} {
+		r = httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		_, err = FromRequest(r, RequestOptions{})
+		assert.Error(t, err, body)
+	}
+
+}
+
+func TestFromRequest_Form(t *testing.T) {
+
+	r := httptest.NewRequest("POST", "/?page=2", strings.NewReader("name=mat&tags=a&tags=b&age=30"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	m, err := FromRequest(r, RequestOptions{URLQuery: URLQueryOptions{ParseValues: true}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, M("name", "mat", "tags", []interface{}{"a", "b"}, "age", 30, "page", 2), m)
+	}
+
+}
+
+func TestFromRequest_Multipart(t *testing.T) {
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("name", "mat")
+	writer.WriteField("address.city", "Boulder")
+	part, _ := writer.CreateFormFile("avatar", "me.png")
+	part.Write([]byte("not really a png"))
+	for _, name := range []string{"a.txt", "b.txt"} {
+		part, _ = writer.CreateFormFile("attachments", name)
+		part.Write([]byte(name))
+	}
+	writer.Close()
+
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(body.Bytes()))
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	m, err := FromRequest(r, RequestOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "mat", m.Get("name"))
+		assert.Equal(t, "Boulder", m.Get("address.city"))
+		assert.Equal(t, M("filename", "me.png", "size", int64(16), "content_type", "application/octet-stream"), m.Get("avatar"))
+		assert.Equal(t, "b.txt", m.Get("attachments[1].filename"))
+		assert.Equal(t, int64(5), m.Get("attachments[0].size"))
+
+		// the files can still be opened
+		file, err := r.MultipartForm.File["avatar"][0].Open()
+		if assert.NoError(t, err) {
+			data, _ := ioutil.ReadAll(file)
+			assert.Equal(t, "not really a png", string(data))
+			file.Close()
+		}
+	}
+
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(body.Bytes()))
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	_, err = FromRequest(r, RequestOptions{MaxBodyBytes: 100})
+	if assert.IsType(t, &LimitError{}, err) {
+		assert.Equal(t, LimitBytes, err.(*LimitError).Limit)
+	}
+
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(body.Bytes()))
+	r.Header.Set("Content-Type", "multipart/form-data")
+	_, err = FromRequest(r, RequestOptions{})
+	assert.Error(t, err)
+
+}
+
+func TestFromRequest_Limits(t *testing.T) {
+
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"a long enough value"}`))
+		r.Header.Set("Content-Type", contentType)
+		_, err := FromRequest(r, RequestOptions{MaxBodyBytes: 10})
+		assert.IsType(t, &LimitError{}, err, contentType)
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"a":{"b":{"c":1}}}`))
+	r.Header.Set("Content-Type", "application/json")
+	_, err := FromRequest(r, RequestOptions{JSON: DecodeOptions{MaxDepth: 2}})
+	assert.IsType(t, &LimitError{}, err)
+
+	r = httptest.NewRequest("GET", "/?a=1&a=2", nil)
+	_, err = FromRequest(r, RequestOptions{URLQuery: URLQueryOptions{Limits: DecodeOptions{DisallowDuplicateKeys: true}}})
+	assert.IsType(t, &DuplicateKeyError{}, err)
+
+}
+
+func TestFromRequest_ContentTypes(t *testing.T) {
+
+	// bodies without a content type are ignored
+	r := httptest.NewRequest("POST", "/?a=1", strings.NewReader(`{"b":2}`))
+	m, err := FromRequest(r, RequestOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, M("a", "1"), m)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`<xml/>`))
+	r.Header.Set("Content-Type", "text/xml")
+	_, err = FromRequest(r, RequestOptions{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "Map: Cannot read a request body of type 'text/xml'.", err.Error())
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", "application/json; =")
+	_, err = FromRequest(r, RequestOptions{})
+	assert.Error(t, err)
+
+	_, err = FromRequest(httptest.NewRequest("GET", "/", nil), RequestOptions{Precedence: []RequestSource{RequestSource(99)}})
+	assert.Error(t, err)
+
+}
+
+func TestMapWriteResponse(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	assert.NoError(t, M("name", "Mat", "html", "<b>").WriteResponse(w, http.StatusCreated))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "{\"html\":\"\\u003cb\\u003e\",\"name\":\"Mat\"}\n", w.Body.String())
+	assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+
+	// without a callback, requests get JSON
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	assert.NoError(t, M("id", 42).WriteResponseWithOptions(w, http.StatusOK, ResponseOptions{Request: r, JSON: JSONOptions{Pretty: true}}))
+	assert.Equal(t, "{\n  \"id\": 42\n}\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users/42?callback=app.showUser", nil)
+	assert.NoError(t, M("id", 42).WriteResponseWithOptions(w, http.StatusOK, ResponseOptions{Request: r}))
+	assert.Equal(t, "application/javascript; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "/**/app.showUser({\"id\":42});\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users/42?jsonp=show", nil)
+	assert.NoError(t, M("id", 42).WriteResponseWithOptions(w, http.StatusOK, ResponseOptions{Request: r, CallbackParam: "jsonp"}))
+	assert.Equal(t, "/**/show({\"id\":42});\n", w.Body.String())
+
+	// the Accept header is honoured
+	for accept, jsonp := range map[string]bool{
+		"application/json":                             false,
+		"application/json, application/javascript":     true,
+		"text/javascript;q=0.5":                        true,
+		"application/javascript;q=0, application/json": false,
+		"*/*": true,
+	} {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("GET", "/users/42?callback=show", nil)
+		r.Header.Set("Accept", accept)
+		assert.NoError(t, M("id", 42).WriteResponseWithOptions(w, http.StatusOK, ResponseOptions{Request: r}))
+		assert.Equal(t, jsonp, strings.HasPrefix(w.Body.String(), "/**/show("), accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	}
+
+	// nothing is written if the response can't be made
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users/42?callback=alert(1)//", nil)
+	assert.Error(t, M("id", 42).WriteResponseWithOptions(w, http.StatusOK, ResponseOptions{Request: r}))
+	assert.Equal(t, 0, w.Body.Len())
+	assert.Equal(t, "", w.Header().Get("Content-Type"))
+
+	w = httptest.NewRecorder()
+	assert.Error(t, M("f", func() {}).WriteResponse(w, http.StatusOK))
+	assert.Equal(t, 0, w.Body.Len())
+
+}
@@ -0,0 +1,265 @@
+package objects
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// ImmutableMap is a Map that cannot be changed once it has been made, so it
+// can be cached or shared between goroutines without any locking.
+//
+// With and Without return new versions, leaving the original as it was.
+// Only the maps and slices along the keypath are copied; everything else is
+// shared between the versions, so changing one value in a large map is
+// cheap, and Equal can skip over anything the versions still share.
+//
+// For example:
+//
+//     defaults := objects.NewImmutableMap(objects.M("db", objects.M("host", "localhost", "port", 5432)))
+//     staging := defaults.With("db.host", "staging")
+//
+//     defaults.Get("db.host") // "localhost"
+//     staging.Get("db.host")  // "staging"
+//
+// Values other than maps and slices (including pointers) are stored as they
+// are, so they should not be changed either.  The zero value is an empty
+// ImmutableMap.
+type ImmutableMap struct {
+	data Map
+}
+
+// NewImmutableMap creates an ImmutableMap holding a copy of the map.
+func NewImmutableMap(m Map) ImmutableMap {
+	return ImmutableMap{data: m.DeepCopy()}
+}
+
+// Map gets a copy of the ImmutableMap as a Map, which may be changed freely.
+func (i ImmutableMap) Map() Map {
+	return i.data.DeepCopy()
+}
+
+// Len gets the number of keys at the top level of the map.
+func (i ImmutableMap) Len() int {
+	return len(i.data)
+}
+
+// Keys gets the keys at the top level of the map, in order.
+func (i ImmutableMap) Keys() []string {
+	return sortedKeys(i.data)
+}
+
+// Get gets the value at the keypath, or nil if there isn't one.  See
+// Map.Get for the keypaths supported.
+//
+// Maps and slices are copied, so that they can't be used to change the
+// ImmutableMap; use Sub to get a nested map without copying it.
+func (i ImmutableMap) Get(keypath string) interface{} {
+	return deepCopyValue(i.data.Get(keypath))
+}
+
+// Has gets whether there is a value at the keypath.
+func (i ImmutableMap) Has(keypath string) bool {
+	return i.data.Has(keypath)
+}
+
+// Sub gets the map at the keypath as an ImmutableMap, sharing it rather than
+// copying it, or false if there is no map there.
+func (i ImmutableMap) Sub(keypath string) (ImmutableMap, bool) {
+
+	m, ok := asMap(i.data.Get(keypath))
+	if !ok {
+		return ImmutableMap{}, false
+	}
+
+	return ImmutableMap{data: m}, true
+}
+
+// With gets a new version of the map with the value set at the keypath,
+// creating maps and slices along the way as Map.Set does.  The original is
+// not changed.
+func (i ImmutableMap) With(keypath string, value interface{}) ImmutableMap {
+
+	segs := parseKeypath(keypath)
+	if len(segs) == 0 || segs[0].isIndex || !canSetPath(i.data, segs) {
+		return i
+	}
+
+	return ImmutableMap{data: withPath(i.data, segs, deepCopyValue(value)).(Map)}
+}
+
+// Without gets a new version of the map with the value at the keypath
+// removed, as Map.Delete does.  The original is not changed, and is returned
+// as it is if there was nothing to remove.
+func (i ImmutableMap) Without(keypath string) ImmutableMap {
+
+	segs := parseKeypath(keypath)
+	if len(segs) == 0 || segs[0].isIndex {
+		return i
+	}
+
+	data, ok := withoutPath(i.data, segs)
+	if !ok {
+		return i
+	}
+
+	return ImmutableMap{data: data.(Map)}
+}
+
+// Equal gets whether the two maps hold the same values.  Maps and slices
+// are compared item by item, and numbers by their value, so int 1 is equal
+// to float64 1.  Parts that the two maps share, because one was made from
+// the other, are not looked inside at all.
+func (i ImmutableMap) Equal(other ImmutableMap) bool {
+	return sharedEqual(i.data, other.data)
+}
+
+// MarshalJSON encodes the map as a JSON object.
+func (i ImmutableMap) MarshalJSON() ([]byte, error) {
+	if i.data == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(i.data)
+}
+
+// withPath gets a copy of current with the value set at the end of the
+// segments.  Only the containers along the segments are copied.
+func withPath(current interface{}, segs []pathSegment, value interface{}) interface{} {
+
+	if len(segs) == 0 {
+		return value
+	}
+
+	seg := segs[0]
+
+	if !seg.isIndex {
+		m, _ := asMap(current)
+		copied := make(Map, len(m)+1)
+		for k, v := range m {
+			copied[k] = v
+		}
+		copied[seg.key] = withPath(m[seg.key], segs[1:], value)
+		return copied
+	}
+
+	// anything that isn't a slice is replaced by one
+	length, _ := sliceLen(current)
+
+	index := resolveIndex(seg.index, length)
+	if index < 0 {
+		// cannot grow a slice backwards
+		return current
+	}
+
+	items := toInterfaces(current)
+	if len(items) <= index {
+		grown := make([]interface{}, index+1)
+		copy(grown, items)
+		items = grown
+	}
+	items[index] = withPath(items[index], segs[1:], value)
+
+	return items
+}
+
+// withoutPath gets a copy of current with the value at the end of the
+// segments removed, or false if there was nothing to remove.  Only the
+// containers along the segments are copied.
+func withoutPath(current interface{}, segs []pathSegment) (interface{}, bool) {
+
+	seg := segs[0]
+
+	if !seg.isIndex {
+
+		m, ok := asMap(current)
+		if !ok {
+			return current, false
+		}
+
+		child, exists := m[seg.key]
+		if !exists {
+			return current, false
+		}
+
+		if len(segs) > 1 {
+			if child, ok = withoutPath(child, segs[1:]); !ok {
+				return current, false
+			}
+		}
+
+		copied := make(Map, len(m))
+		for k, v := range m {
+			copied[k] = v
+		}
+		if len(segs) == 1 {
+			delete(copied, seg.key)
+		} else {
+			copied[seg.key] = child
+		}
+
+		return copied, true
+	}
+
+	length, ok := sliceLen(current)
+	if !ok {
+		return current, false
+	}
+
+	index := resolveIndex(seg.index, length)
+	if index < 0 || index >= length {
+		return current, false
+	}
+
+	items := toInterfaces(current)
+
+	if len(segs) == 1 {
+		return append(items[:index], items[index+1:]...), true
+	}
+
+	child, ok := withoutPath(items[index], segs[1:])
+	if !ok {
+		return current, false
+	}
+	items[index] = child
+
+	return items, true
+}
+
+// sharedEqual compares values like valuesEqual, but treats maps and slices
+// that are the same underneath as equal without looking inside them.
+func sharedEqual(a, b interface{}) bool {
+
+	if aMap, ok := asMap(a); ok {
+		bMap, ok := asMap(b)
+		if !ok || len(aMap) != len(bMap) {
+			return false
+		}
+		if reflect.ValueOf(aMap).Pointer() == reflect.ValueOf(bMap).Pointer() {
+			return true
+		}
+		for k, aValue := range aMap {
+			bValue, exists := bMap[k]
+			if !exists || !sharedEqual(aValue, bValue) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if aItems, ok := a.([]interface{}); ok {
+		bItems, ok := b.([]interface{})
+		if !ok || len(aItems) != len(bItems) {
+			return valuesEqual(a, b)
+		}
+		if len(aItems) > 0 && &aItems[0] == &bItems[0] {
+			return true
+		}
+		for i := range aItems {
+			if !sharedEqual(aItems[i], bItems[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return valuesEqual(a, b)
+}
@@ -0,0 +1,211 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestImmutableMap(t *testing.T) {
+
+	original := M("name", "stew", "db", M("host", "localhost", "port", 5432), "tags", []string{"a", "b"})
+	i := NewImmutableMap(original)
+
+	// the original map was copied
+	original.Set("db.host", "changed")
+	assert.Equal(t, "localhost", i.Get("db.host"))
+
+	assert.Equal(t, 3, i.Len())
+	assert.Equal(t, []string{"db", "name", "tags"}, i.Keys())
+	assert.True(t, i.Has("tags[1]"))
+	assert.False(t, i.Has("tags[2]"))
+
+	// values that come out can't be used to change it
+	i.Get("db").(Map).Set("host", "changed")
+	i.Map().Set("db.host", "changed")
+	assert.Equal(t, "localhost", i.Get("db.host"))
+
+	db, ok := i.Sub("db")
+	if assert.True(t, ok) {
+		assert.Equal(t, 5432, db.Get("port"))
+	}
+	_, ok = i.Sub("name")
+	assert.False(t, ok)
+
+	// the zero value is empty
+	var zero ImmutableMap
+	assert.Equal(t, 0, zero.Len())
+	assert.Nil(t, zero.Get("a"))
+	assert.Equal(t, M("a", M("b", 1)), zero.With("a.b", 1).Map())
+	assert.Equal(t, 0, zero.Len())
+
+	j, err := NewImmutableMap(M("a", 1)).MarshalJSON()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"a":1}`, string(j))
+	}
+	j, _ = zero.MarshalJSON()
+	assert.Equal(t, `{}`, string(j))
+
+}
+
+func TestImmutableMap_With(t *testing.T) {
+
+	v1 := NewImmutableMap(M("db", M("host", "localhost", "replicas", []interface{}{M("host", "r1")}), "cache", M("ttl", 60)))
+
+	v2 := v1.With("db.host", "db2")
+	v3 := v2.With("db.replicas[1].host", "r2").With("db.replicas[0].port", 1)
+	v4 := v3.With("tags[1]", "b")
+
+	assert.Equal(t, "localhost", v1.Get("db.host"))
+	assert.Equal(t, "db2", v2.Get("db.host"))
+	assert.Nil(t, v2.Get("db.replicas[1]"))
+	assert.Equal(t, M("host", "r1", "port", 1), v3.Get("db.replicas[0]"))
+	assert.Equal(t, "r2", v3.Get("db.replicas[1].host"))
+	assert.Equal(t, M("host", "r1"), v1.Get("db.replicas[0]"))
+	assert.Equal(t, []interface{}{nil, "b"}, v4.Get("tags"))
+	assert.False(t, v3.Has("tags"))
+
+	// values that were not on the keypath are shared
+	c1, _ := v1.Sub("cache")
+	c4, _ := v4.Sub("cache")
+	c1.data["shared"] = true
+	assert.Equal(t, true, c4.Get("shared"))
+
+	// keypaths that can't be set leave the map alone
+	assert.Equal(t, v1, v1.With("[0]", 1))
+	assert.Equal(t, v1.Map(), v1.With("db.replicas[-5]", 1).Map())
+	assert.Equal(t, v1.Map(), v1.With("db.host[-1]", 1).Map())
+	assert.Equal(t, v1.Map(), v1.With("nope[-1]", 1).Map())
+	assert.Equal(t, v1.Map(), v1.With("nope[9223372036854775806]", 1).Map())
+
+	// values that go in are copied
+	value := M("a", 1)
+	v5 := v1.With("value", value)
+	value.Set("a", 2)
+	assert.Equal(t, 1, v5.Get("value.a"))
+
+}
+
+func TestImmutableMap_Without(t *testing.T) {
+
+	v1 := NewImmutableMap(M("db", M("host", "localhost", "port", 5432), "tags", []string{"a", "b", "c"}, "items", []Map{M("a", 1)}))
+
+	v2 := v1.Without("db.port").Without("tags[1]").Without("items[0].a")
+
+	assert.Equal(t, M("db", M("host", "localhost"), "tags", []interface{}{"a", "c"}, "items", []interface{}{M()}), v2.Map())
+	assert.Equal(t, M("db", M("host", "localhost", "port", 5432), "tags", []string{"a", "b", "c"}, "items", []Map{M("a", 1)}), v1.Map())
+
+	// nothing to remove
+	assert.Equal(t, v1, v1.Without("db.missing"))
+	assert.Equal(t, v1, v1.Without("tags[5]"))
+	assert.Equal(t, v1, v1.Without("db.host.x"))
+	assert.Equal(t, v1, v1.Without("[0]"))
+
+}
+
+func TestImmutableMap_Equal(t *testing.T) {
+
+	v1 := NewImmutableMap(M("a", M("b", 1, "c", []interface{}{1, M("d", 2)})))
+
+	assert.True(t, v1.Equal(v1))
+	assert.True(t, v1.Equal(NewImmutableMap(M("a", M("b", 1.0, "c", []interface{}{int64(1), M("d", 2)})))))
+	assert.True(t, v1.Equal(v1.With("a.b", 1)))
+	assert.True(t, v1.Equal(v1.With("x", 1).Without("x")))
+	assert.False(t, v1.Equal(v1.With("a.c[1].d", 3)))
+	assert.False(t, v1.Equal(v1.Without("a.c[0]")))
+	assert.False(t, v1.Equal(ImmutableMap{}))
+	assert.True(t, ImmutableMap{}.Equal(NewImmutableMap(nil)))
+
+}
+
+func TestImmutableMap_Concurrent(t *testing.T) {
+
+	base := NewImmutableMap(M("counts", M()))
+
+	var wg sync.WaitGroup
+	results := make([]ImmutableMap, 8)
+	for w := range results {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			m := base
+			for i := 0; i < 100; i++ {
+				m = m.With(fmt.Sprintf("counts.w%d", w), i)
+				base.Get("counts")
+			}
+			results[w] = m
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, M("counts", M()), base.Map())
+	for w, m := range results {
+		assert.Equal(t, M(fmt.Sprintf("w%d", w), 99), m.Get("counts"))
+	}
+
+}
+
+/*
+	Benchmarks. Run them with "go test -bench=ImmutableMap"
+*/
+
+// benchmarkMap makes a map with 100 keys, each holding a map with 100 keys.
+func benchmarkMap() Map {
+	m := make(Map)
+	for i := 0; i < 100; i++ {
+		inner := make(Map)
+		for j := 0; j < 100; j++ {
+			inner[fmt.Sprintf("key%d", j)] = j
+		}
+		m[fmt.Sprintf("key%d", i)] = inner
+	}
+	return m
+}
+
+func BenchmarkImmutableMap_With(b *testing.B) {
+
+	b.StopTimer()
+	i := NewImmutableMap(benchmarkMap())
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		i.With("key50.key50", n)
+	}
+
+}
+
+func BenchmarkImmutableMap_CopySet(b *testing.B) {
+
+	// a shallow Copy is not enough to leave nested maps alone, but is here
+	// for comparison
+	b.StopTimer()
+	m := benchmarkMap()
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		m.Copy().Set("key50.key50", n)
+	}
+
+}
+
+func BenchmarkImmutableMap_DeepCopySet(b *testing.B) {
+
+	b.StopTimer()
+	m := benchmarkMap()
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		m.DeepCopy().Set("key50.key50", n)
+	}
+
+}
+
+func BenchmarkImmutableMap_Equal(b *testing.B) {
+
+	b.StopTimer()
+	i := NewImmutableMap(benchmarkMap())
+	changed := i.With("key50.key50", -1)
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		i.Equal(changed)
+	}
+
+}
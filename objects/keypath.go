@@ -0,0 +1,654 @@
+package objects
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	// PathEscape is the character used to escape a PathSeparator, an
+	// opening index bracket or itself when it should be treated as part
+	// of a key.
+	//
+	// For example, `files.readme\.md` addresses the `readme.md` key inside
+	// the `files` object.
+	PathEscape string = `\`
+)
+
+const (
+	pathIndexOpen  string = "["
+	pathIndexClose string = "]"
+)
+
+// pathSegment is a single step in a parsed keypath; either a key
+// into a map, or an index into a slice.
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseKeypath breaks the keypath into segments.
+//
+// Keys are separated by PathSeparator, and slice indexes are
+// written in square brackets, for example `users[2].name` or `users[-1]`.
+// Anything following PathEscape is taken literally.  Brackets that do not
+// contain a valid integer are treated as part of the key.
+func parseKeypath(keypath string) []pathSegment {
+	return parsePath(keypath, PathSeparator)
+}
+
+// parsePath breaks the path into segments like parseKeypath, but with keys
+// separated by the given separator.
+func parsePath(keypath, separator string) []pathSegment {
+
+	var segs []pathSegment
+	var key []byte
+	keyOpen := true
+
+	for i := 0; i < len(keypath); {
+
+		rest := keypath[i:]
+
+		switch {
+		case strings.HasPrefix(rest, PathEscape) && len(rest) > len(PathEscape):
+
+			rest = rest[len(PathEscape):]
+			i += len(PathEscape)
+
+			// escaped separators are taken whole
+			literal := rest[:1]
+			if strings.HasPrefix(rest, separator) {
+				literal = separator
+			}
+			key = append(key, literal...)
+			i += len(literal)
+			keyOpen = true
+
+		case strings.HasPrefix(rest, separator):
+
+			if keyOpen {
+				segs = append(segs, pathSegment{key: string(key)})
+			}
+			key = key[:0]
+			keyOpen = true
+			i += len(separator)
+
+		case strings.HasPrefix(rest, pathIndexOpen):
+
+			end := strings.Index(rest, pathIndexClose)
+			if end == -1 {
+				key = append(key, rest[:1]...)
+				keyOpen = true
+				i++
+				break
+			}
+
+			index, err := strconv.Atoi(rest[len(pathIndexOpen):end])
+			if err != nil {
+				key = append(key, rest[:end+len(pathIndexClose)]...)
+				keyOpen = true
+				i += end + len(pathIndexClose)
+				break
+			}
+
+			if len(key) > 0 {
+				segs = append(segs, pathSegment{key: string(key)})
+			}
+			segs = append(segs, pathSegment{index: index, isIndex: true})
+			key = key[:0]
+			keyOpen = false
+			i += end + len(pathIndexClose)
+
+		default:
+
+			key = append(key, rest[0])
+			keyOpen = true
+			i++
+
+		}
+
+	}
+
+	if keyOpen {
+		segs = append(segs, pathSegment{key: string(key)})
+	}
+
+	return segs
+}
+
+// EscapeKey escapes any characters in the key that would otherwise
+// have special meaning in a keypath, so that it may be safely used as
+// a single segment.
+//
+// For example:
+//
+//     m.Get("files." + objects.EscapeKey("readme.md"))
+func EscapeKey(key string) string {
+	return escapeKey(key, PathSeparator)
+}
+
+// escapeKey escapes the key like EscapeKey, but for paths with keys
+// separated by the given separator.
+func escapeKey(key, separator string) string {
+
+	var escaped []byte
+
+	for i := 0; i < len(key); {
+		rest := key[i:]
+		switch {
+		case strings.HasPrefix(rest, PathEscape):
+			escaped = append(escaped, PathEscape...)
+			escaped = append(escaped, PathEscape...)
+			i += len(PathEscape)
+		case strings.HasPrefix(rest, separator):
+			escaped = append(escaped, PathEscape...)
+			escaped = append(escaped, separator...)
+			i += len(separator)
+		case strings.HasPrefix(rest, pathIndexOpen):
+			escaped = append(escaped, PathEscape...)
+			escaped = append(escaped, pathIndexOpen...)
+			i += len(pathIndexOpen)
+		default:
+			escaped = append(escaped, rest[0])
+			i++
+		}
+	}
+
+	return string(escaped)
+}
+
+// asMap gets the value as a Map if it is a Map or a map[string]interface{}.
+func asMap(value interface{}) (Map, bool) {
+	switch value.(type) {
+	case Map:
+		return value.(Map), true
+	case map[string]interface{}:
+		return Map(value.(map[string]interface{})), true
+	}
+	return nil, false
+}
+
+// sliceLen gets the length of the value if it is a slice that
+// keypaths may index into.
+func sliceLen(value interface{}) (int, bool) {
+	switch value.(type) {
+	case []interface{}:
+		return len(value.([]interface{})), true
+	case []Map:
+		return len(value.([]Map)), true
+	case []map[string]interface{}:
+		return len(value.([]map[string]interface{})), true
+	case []string:
+		return len(value.([]string)), true
+	}
+	return 0, false
+}
+
+// sliceItem gets the item at the index in a slice previously checked
+// by sliceLen.
+func sliceItem(value interface{}, index int) interface{} {
+	switch value.(type) {
+	case []interface{}:
+		return value.([]interface{})[index]
+	case []Map:
+		return value.([]Map)[index]
+	case []map[string]interface{}:
+		return value.([]map[string]interface{})[index]
+	case []string:
+		return value.([]string)[index]
+	}
+	return nil
+}
+
+// resolveIndex turns a possibly negative index into an absolute one.
+func resolveIndex(index, length int) int {
+	if index < 0 {
+		return length + index
+	}
+	return index
+}
+
+// getPath walks the segments from the value, returning the value
+// at the end or false if any step could not be taken.
+func getPath(value interface{}, segs []pathSegment) (interface{}, bool) {
+
+	for _, seg := range segs {
+
+		if seg.isIndex {
+
+			length, ok := sliceLen(value)
+			if !ok {
+				return nil, false
+			}
+
+			index := resolveIndex(seg.index, length)
+			if index < 0 || index >= length {
+				return nil, false
+			}
+
+			value = sliceItem(value, index)
+			continue
+		}
+
+		m, ok := asMap(value)
+		if !ok {
+			return nil, false
+		}
+
+		if value, ok = m[seg.key]; !ok {
+			return nil, false
+		}
+
+	}
+
+	return value, true
+}
+
+// resolvePath gets a copy of the segments with any negative indexes turned
+// into the absolute ones they refer to in value.  The segments must lead to
+// a value, as checked by getPath.
+func resolvePath(value interface{}, segs []pathSegment) []pathSegment {
+
+	resolved := make([]pathSegment, len(segs))
+	for i, seg := range segs {
+
+		if seg.isIndex {
+			length, _ := sliceLen(value)
+			seg.index = resolveIndex(seg.index, length)
+		}
+
+		resolved[i] = seg
+		value, _ = getPath(value, resolved[i:i+1])
+
+	}
+
+	return resolved
+}
+
+// canSetPath gets whether setPath could set a value at the end of the
+// segments, which it can't if a negative index falls before the start of a
+// slice (or of the empty slice that would be created), or an index would
+// grow a slice by more than DefaultMaxIndex items.
+func canSetPath(current interface{}, segs []pathSegment) bool {
+
+	for _, seg := range segs {
+
+		if !seg.isIndex {
+			m, _ := asMap(current)
+			current = m[seg.key]
+			continue
+		}
+
+		length, _ := sliceLen(current)
+		index := resolveIndex(seg.index, length)
+		if index < 0 || index-length >= DefaultMaxIndex {
+			return false
+		}
+
+		if index < length {
+			current = sliceItem(current, index)
+		} else {
+			current = nil
+		}
+
+	}
+
+	return true
+}
+
+// setPath sets the value at the end of the segments, creating Map and
+// []interface{} objects as needed.  It returns the container that should
+// replace current in its parent, since slices may have been grown.
+func setPath(current interface{}, segs []pathSegment, value interface{}) interface{} {
+
+	if len(segs) == 0 {
+		return value
+	}
+
+	seg := segs[0]
+
+	if !seg.isIndex {
+		m, ok := asMap(current)
+		if !ok || m == nil {
+			m = make(Map)
+			current = m
+		}
+		m[seg.key] = setPath(m[seg.key], segs[1:], value)
+		return current
+	}
+
+	original := current
+	length, ok := sliceLen(current)
+	if !ok {
+		current, length = []interface{}{}, 0
+	}
+
+	index := resolveIndex(seg.index, length)
+	if index < 0 {
+		// cannot grow a slice backwards
+		return original
+	}
+
+	switch current.(type) {
+	case []Map:
+		s := current.([]Map)
+		if len(s) <= index {
+			grown := make([]Map, index+1)
+			copy(grown, s)
+			s = grown
+		}
+		item := setPath(s[index], segs[1:], value)
+		if itemMap, ok := asMap(item); ok {
+			s[index] = itemMap
+			return s
+		}
+		converted := mapsToInterfaces(s)
+		converted[index] = item
+		return converted
+	case []map[string]interface{}:
+		s := current.([]map[string]interface{})
+		if len(s) <= index {
+			grown := make([]map[string]interface{}, index+1)
+			copy(grown, s)
+			s = grown
+		}
+		item := setPath(s[index], segs[1:], value)
+		if itemMap, ok := asMap(item); ok {
+			s[index] = itemMap.MSI()
+			return s
+		}
+		converted := msisToInterfaces(s)
+		converted[index] = item
+		return converted
+	case []string:
+		s := current.([]string)
+		if len(s) <= index {
+			grown := make([]string, index+1)
+			copy(grown, s)
+			s = grown
+		}
+		item := setPath(s[index], segs[1:], value)
+		if itemString, ok := item.(string); ok {
+			s[index] = itemString
+			return s
+		}
+		converted := stringsToInterfaces(s)
+		converted[index] = item
+		return converted
+	}
+
+	s := current.([]interface{})
+	if len(s) <= index {
+		grown := make([]interface{}, index+1)
+		copy(grown, s)
+		s = grown
+	}
+	s[index] = setPath(s[index], segs[1:], value)
+
+	return s
+}
+
+// mapsToInterfaces copies a []Map into a []interface{}.
+func mapsToInterfaces(maps []Map) []interface{} {
+	s := make([]interface{}, len(maps))
+	for i, m := range maps {
+		s[i] = m
+	}
+	return s
+}
+
+// msisToInterfaces copies a []map[string]interface{} into a []interface{}.
+func msisToInterfaces(maps []map[string]interface{}) []interface{} {
+	s := make([]interface{}, len(maps))
+	for i, m := range maps {
+		s[i] = m
+	}
+	return s
+}
+
+// stringsToInterfaces copies a []string into a []interface{}.
+func stringsToInterfaces(strs []string) []interface{} {
+	s := make([]interface{}, len(strs))
+	for i, str := range strs {
+		s[i] = str
+	}
+	return s
+}
+
+// deletePath removes the value at the end of the segments, returning the
+// container that should replace current in its parent, the value that was
+// removed and whether anything was removed at all.
+//
+// If prune is true, maps and slices left empty by the removal are removed too.
+func deletePath(current interface{}, segs []pathSegment, prune bool) (interface{}, interface{}, bool) {
+
+	seg := segs[0]
+
+	if !seg.isIndex {
+
+		m, ok := asMap(current)
+		if !ok {
+			return current, nil, false
+		}
+
+		child, exists := m[seg.key]
+		if !exists {
+			return current, nil, false
+		}
+
+		if len(segs) == 1 {
+			delete(m, seg.key)
+			return current, child, true
+		}
+
+		child, removed, ok := deletePath(child, segs[1:], prune)
+		if !ok {
+			return current, nil, false
+		}
+
+		if prune && isEmptyContainer(child) {
+			delete(m, seg.key)
+		} else {
+			m[seg.key] = child
+		}
+
+		return current, removed, true
+	}
+
+	length, ok := sliceLen(current)
+	if !ok {
+		return current, nil, false
+	}
+
+	index := resolveIndex(seg.index, length)
+	if index < 0 || index >= length {
+		return current, nil, false
+	}
+
+	if len(segs) == 1 {
+		return removeSliceItem(current, index), sliceItem(current, index), true
+	}
+
+	child, removed, ok := deletePath(sliceItem(current, index), segs[1:], prune)
+	if !ok {
+		return current, nil, false
+	}
+
+	if prune && isEmptyContainer(child) {
+		return removeSliceItem(current, index), removed, true
+	}
+
+	// items in other slice types are maps, which have been changed in place
+	if items, isInterfaces := current.([]interface{}); isInterfaces {
+		items[index] = child
+	}
+
+	return current, removed, true
+}
+
+// removeSliceItem makes a new slice of the same type without the item at the index.
+func removeSliceItem(value interface{}, index int) interface{} {
+	switch value.(type) {
+	case []Map:
+		s := value.([]Map)
+		return append(append([]Map{}, s[:index]...), s[index+1:]...)
+	case []map[string]interface{}:
+		s := value.([]map[string]interface{})
+		return append(append([]map[string]interface{}{}, s[:index]...), s[index+1:]...)
+	case []string:
+		s := value.([]string)
+		return append(append([]string{}, s[:index]...), s[index+1:]...)
+	}
+	items := toInterfaces(value)
+	return append(items[:index], items[index+1:]...)
+}
+
+// isEmptyContainer gets whether the value is a map or slice with nothing in it.
+func isEmptyContainer(value interface{}) bool {
+	if m, ok := asMap(value); ok {
+		return len(m) == 0
+	}
+	if length, ok := sliceLen(value); ok {
+		return length == 0
+	}
+	return false
+}
+
+const (
+	// globAnyKey is a keypath glob segment matching any one key.
+	globAnyKey string = "*"
+	// globAnyDepth is a keypath glob segment matching any number of keys
+	// and indexes.
+	globAnyDepth string = "**"
+	// globAnyIndex matches any one index in a keypath glob.
+	globAnyIndex string = pathIndexOpen + globAnyKey + pathIndexClose
+)
+
+// globSegment is a single step in a keypath glob.
+type globSegment struct {
+	pathSegment
+	anyIndex bool
+	anyDepth bool
+}
+
+// keypathGlob is a parsed keypath pattern, like `users[*].name` or
+// `**.password`.
+type keypathGlob []globSegment
+
+// parseKeypathGlob parses a keypath glob.  It is written like a keypath,
+// except that `*` within a key matches any run of characters in that key,
+// `[*]` matches any index, and a `**` segment matches any number of keys
+// and indexes, including none.  The empty pattern matches everything.
+func parseKeypathGlob(pattern string) keypathGlob {
+
+	var glob keypathGlob
+	if pattern == "" {
+		return keypathGlob{{anyDepth: true}}
+	}
+
+	for _, seg := range parseKeypath(pattern) {
+
+		if seg.isIndex {
+			glob = append(glob, globSegment{pathSegment: seg})
+			continue
+		}
+
+		// parseKeypath leaves `[*]` as part of the key
+		anyIndexes := 0
+		for strings.HasSuffix(seg.key, globAnyIndex) {
+			seg.key = seg.key[:len(seg.key)-len(globAnyIndex)]
+			anyIndexes++
+		}
+
+		switch {
+		case seg.key == globAnyDepth:
+			glob = append(glob, globSegment{anyDepth: true})
+		case seg.key != "" || anyIndexes == 0:
+			glob = append(glob, globSegment{pathSegment: seg})
+		}
+
+		for ; anyIndexes > 0; anyIndexes-- {
+			glob = append(glob, globSegment{anyIndex: true})
+		}
+
+	}
+
+	return glob
+}
+
+// matchesSegment gets whether the glob segment matches the keypath segment.
+func (g globSegment) matchesSegment(seg pathSegment) bool {
+
+	switch {
+	case g.anyDepth:
+		return true
+	case g.anyIndex:
+		return seg.isIndex
+	case g.isIndex:
+		return seg.isIndex && seg.index == g.index
+	}
+
+	return !seg.isIndex && matchKeyGlob(g.key, seg.key)
+}
+
+// matchKeyGlob gets whether the key matches the pattern, where each `*`
+// in the pattern matches any run of characters.
+func matchKeyGlob(pattern, key string) bool {
+
+	parts := strings.Split(pattern, globAnyKey)
+	if len(parts) == 1 {
+		return pattern == key
+	}
+
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(key, part)
+		if index == -1 {
+			return false
+		}
+		key = key[index+len(part):]
+	}
+
+	return strings.HasSuffix(key, parts[len(parts)-1])
+}
+
+// match gets whether the glob matches the whole keypath.
+func (g keypathGlob) match(keypath string) bool {
+	return g.walk(parseKeypath(keypath), false, false)
+}
+
+// matchWithin gets whether the glob matches the keypath or any part of it,
+// in other words whether the keypath is a match or inside one.
+func (g keypathGlob) matchWithin(keypath string) bool {
+	return g.walk(parseKeypath(keypath), true, false)
+}
+
+// matchBelow gets whether the glob could match something inside the
+// keypath.
+func (g keypathGlob) matchBelow(keypath string) bool {
+	return g.walk(parseKeypath(keypath), false, true)
+}
+
+// walk matches the glob against the segments, allowing segments to be left
+// over if moreSegs is true, and the glob to be left over if moreGlob is true.
+func (g keypathGlob) walk(segs []pathSegment, moreSegs, moreGlob bool) bool {
+
+	if len(g) == 0 {
+		return len(segs) == 0 || moreSegs
+	}
+
+	if g[0].anyDepth {
+		return g[1:].walk(segs, moreSegs, moreGlob) || (len(segs) > 0 && g.walk(segs[1:], moreSegs, moreGlob))
+	}
+
+	if len(segs) == 0 {
+		return moreGlob
+	}
+
+	return g[0].matchesSegment(segs[0]) && g[1:].walk(segs[1:], moreSegs, moreGlob)
+}
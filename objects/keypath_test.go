@@ -0,0 +1,53 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseKeypath(t *testing.T) {
+
+	assert.Equal(t, []pathSegment{{key: "name"}}, parseKeypath("name"))
+	assert.Equal(t, []pathSegment{{key: "name"}, {key: "first"}}, parseKeypath("name.first"))
+	assert.Equal(t, []pathSegment{{key: ""}}, parseKeypath(""))
+
+	assert.Equal(t, []pathSegment{
+		{key: "users"},
+		{index: 2, isIndex: true},
+		{key: "name"},
+	}, parseKeypath("users[2].name"))
+
+	assert.Equal(t, []pathSegment{
+		{key: "grid"},
+		{index: 0, isIndex: true},
+		{index: -1, isIndex: true},
+	}, parseKeypath("grid[0][-1]"))
+
+	assert.Equal(t, []pathSegment{{index: 1, isIndex: true}, {key: "name"}}, parseKeypath("[1].name"))
+
+}
+
+func TestParseKeypath_Escaping(t *testing.T) {
+
+	assert.Equal(t, []pathSegment{{key: "files"}, {key: "readme.md"}}, parseKeypath(`files.readme\.md`))
+	assert.Equal(t, []pathSegment{{key: "tags[0]"}}, parseKeypath(`tags\[0]`))
+	assert.Equal(t, []pathSegment{{key: `back\slash`}}, parseKeypath(`back\\slash`))
+
+	// brackets without an index are just part of the key
+	assert.Equal(t, []pathSegment{{key: "tags[name]"}}, parseKeypath("tags[name]"))
+	assert.Equal(t, []pathSegment{{key: "tags[0"}}, parseKeypath("tags[0"))
+
+}
+
+func TestEscapeKey(t *testing.T) {
+
+	assert.Equal(t, "name", EscapeKey("name"))
+	assert.Equal(t, `readme\.md`, EscapeKey("readme.md"))
+	assert.Equal(t, `tags\[0]`, EscapeKey("tags[0]"))
+	assert.Equal(t, `back\\slash`, EscapeKey(`back\slash`))
+
+	for _, key := range []string{"readme.md", "tags[0]", `back\slash`, `a\.b[1]`} {
+		assert.Equal(t, []pathSegment{{key: key}}, parseKeypath(EscapeKey(key)))
+	}
+
+}
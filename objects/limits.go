@@ -0,0 +1,334 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Names of the limits that may be reported by a *LimitError.
+const (
+	LimitBytes        string = "bytes"
+	LimitDepth        string = "depth"
+	LimitKeys         string = "keys"
+	LimitStringLength string = "string length"
+	LimitIndex        string = "index"
+)
+
+// DefaultMaxIndex is the largest array index allowed in keys like `ids[5]`
+// if DecodeOptions.MaxIndex is not set.
+const DefaultMaxIndex int = 1000
+
+// LimitError is returned when input being decoded into a Map goes beyond
+// one of the configured limits.
+type LimitError struct {
+	// Limit is the name of the limit that was exceeded, for example LimitDepth.
+	Limit string
+	// Max is the configured value of the limit.
+	Max int
+}
+
+// Error gets the error message.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Map: Input exceeds the %s limit of %d.", e.Limit, e.Max)
+}
+
+// DuplicateKeyError is returned when input being decoded into a Map has
+// the same key more than once, and DisallowDuplicateKeys is set.
+type DuplicateKeyError struct {
+	// Keypath is the keypath of the repeated key.
+	Keypath string
+}
+
+// Error gets the error message.
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("Map: Input has the key '%s' more than once.", e.Keypath)
+}
+
+// DecodeOptions limits what will be accepted when decoding a Map, so that
+// untrusted input cannot use up too much memory or time.
+//
+// Limits that are not set (zero) are not checked, apart from MaxIndex.  A
+// *LimitError or *DuplicateKeyError is returned when the input breaks them.
+//
+// JSON, URL queries and flat maps are checked as they are read.  YAML, TOML
+// and MessagePack are only checked once they have been decoded, so for them
+// MaxDepth, MaxKeys and MaxStringLength do not bound the memory or time
+// used; only MaxBytes does, so always set it for untrusted input.
+//
+// For example:
+//
+//     m, err := objects.NewMapFromJSONWithOptions(body, objects.DecodeOptions{
+//         MaxBytes: 1 << 20,
+//         MaxDepth: 16,
+//     })
+type DecodeOptions struct {
+	// MaxBytes is the largest input allowed.
+	MaxBytes int
+	// MaxDepth is the deepest nesting of objects and arrays allowed.  The
+	// map itself has a depth of one.
+	MaxDepth int
+	// MaxKeys is the most object keys and array items allowed in total.
+	MaxKeys int
+	// MaxStringLength is the longest key or string value allowed, in bytes.
+	MaxStringLength int
+	// DisallowDuplicateKeys rejects objects that have the same key more
+	// than once, instead of keeping the last value.
+	DisallowDuplicateKeys bool
+	// MaxIndex is the largest array index allowed in keys that name one,
+	// like `ids[5]` in URL queries and flat maps, since the array is made big
+	// enough to hold it.  Unlike the other limits, there is always one;
+	// DefaultMaxIndex is used if it is not set, or MaxKeys less one if that
+	// is smaller.
+	MaxIndex int
+}
+
+// checkBytes checks the size of the input.
+func (o DecodeOptions) checkBytes(size int) error {
+	if o.MaxBytes > 0 && size > o.MaxBytes {
+		return &LimitError{Limit: LimitBytes, Max: o.MaxBytes}
+	}
+	return nil
+}
+
+// checkDepth checks the depth of a new object or array.
+func (o DecodeOptions) checkDepth(depth int) error {
+	if o.MaxDepth > 0 && depth > o.MaxDepth {
+		return &LimitError{Limit: LimitDepth, Max: o.MaxDepth}
+	}
+	return nil
+}
+
+// checkKeys checks the number of keys and items seen so far.
+func (o DecodeOptions) checkKeys(count int) error {
+	if o.MaxKeys > 0 && count > o.MaxKeys {
+		return &LimitError{Limit: LimitKeys, Max: o.MaxKeys}
+	}
+	return nil
+}
+
+// checkIndex checks an array index in a key.
+func (o DecodeOptions) checkIndex(index int) error {
+
+	max := o.MaxIndex
+	if max <= 0 {
+		max = DefaultMaxIndex
+	}
+	if o.MaxKeys > 0 && o.MaxKeys-1 < max {
+		max = o.MaxKeys - 1
+	}
+
+	if index > max {
+		return &LimitError{Limit: LimitIndex, Max: max}
+	}
+
+	return nil
+}
+
+// checkString checks the length of a key or string value.
+func (o DecodeOptions) checkString(s string) error {
+	if o.MaxStringLength > 0 && len(s) > o.MaxStringLength {
+		return &LimitError{Limit: LimitStringLength, Max: o.MaxStringLength}
+	}
+	return nil
+}
+
+// checksStructure gets whether any limits apply to the contents of the input,
+// rather than just its size.
+func (o DecodeOptions) checksStructure() bool {
+	return o.MaxDepth > 0 || o.MaxKeys > 0 || o.MaxStringLength > 0 || o.DisallowDuplicateKeys
+}
+
+// jsonFrame is an object or array being read by checkJSON.
+type jsonFrame struct {
+	object  bool
+	keypath string
+	// key is the last key read in an object.
+	key string
+	// index is the index of the next item in an array.
+	index     int
+	expectKey bool
+	keys      map[string]bool
+}
+
+// childKeypath gets the keypath of the value being read in the frame.
+func (f *jsonFrame) childKeypath() string {
+	if f.object {
+		return childKeypath(f.keypath, f.key)
+	}
+	return indexKeypath(f.keypath, f.index)
+}
+
+// valueDone moves the frame on after one of its values has been read.
+func (f *jsonFrame) valueDone() {
+	if f.object {
+		f.expectKey = true
+	} else {
+		f.index++
+	}
+}
+
+// checkJSON checks the JSON data against the options, before anything is
+// built from it.  Syntax errors are left for the real decoding to report.
+func (o DecodeOptions) checkJSON(data []byte) error {
+
+	if err := o.checkBytes(len(data)); err != nil {
+		return err
+	}
+
+	if !o.checksStructure() {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var stack []*jsonFrame
+	count := 0
+
+	for {
+
+		token, err := decoder.Token()
+		if err != nil {
+			// io.EOF, or a syntax error
+			return nil
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if token == json.Delim('}') || token == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].valueDone()
+			}
+			continue
+		}
+
+		if top != nil && top.object && top.expectKey {
+
+			key, _ := token.(string)
+			if err := o.checkString(key); err != nil {
+				return err
+			}
+			count++
+			if err := o.checkKeys(count); err != nil {
+				return err
+			}
+			if o.DisallowDuplicateKeys {
+				if top.keys[key] {
+					return &DuplicateKeyError{Keypath: childKeypath(top.keypath, key)}
+				}
+				top.keys[key] = true
+			}
+
+			top.key = key
+			top.expectKey = false
+			continue
+		}
+
+		if top != nil && !top.object {
+			count++
+			if err := o.checkKeys(count); err != nil {
+				return err
+			}
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+
+			if err := o.checkDepth(len(stack) + 1); err != nil {
+				return err
+			}
+
+			frame := &jsonFrame{object: token == json.Delim('{'), expectKey: true}
+			if top != nil {
+				frame.keypath = top.childKeypath()
+			}
+			if frame.object && o.DisallowDuplicateKeys {
+				frame.keys = make(map[string]bool)
+			}
+			stack = append(stack, frame)
+			continue
+
+		}
+
+		if s, ok := token.(string); ok {
+			if err := o.checkString(s); err != nil {
+				return err
+			}
+		}
+
+		if top != nil {
+			top.valueDone()
+		}
+
+	}
+
+}
+
+// checkValue checks a value that has already been decoded against the
+// options.  Formats that can have duplicate keys must check for them while
+// decoding.
+func (o DecodeOptions) checkValue(value interface{}, depth int, count *int) error {
+
+	if m, ok := asMap(value); ok {
+
+		if err := o.checkDepth(depth + 1); err != nil {
+			return err
+		}
+
+		for k, v := range m {
+			*count++
+			if err := o.checkKeys(*count); err != nil {
+				return err
+			}
+			if err := o.checkString(k); err != nil {
+				return err
+			}
+			if err := o.checkValue(v, depth+1, count); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if length, ok := sliceLen(value); ok {
+
+		if err := o.checkDepth(depth + 1); err != nil {
+			return err
+		}
+
+		for i := 0; i < length; i++ {
+			*count++
+			if err := o.checkKeys(*count); err != nil {
+				return err
+			}
+			if err := o.checkValue(sliceItem(value, i), depth+1, count); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if s, ok := value.(string); ok {
+		return o.checkString(s)
+	}
+
+	return nil
+}
+
+// checkMap checks a decoded map against the options.
+func (o DecodeOptions) checkMap(m Map) error {
+
+	if !o.checksStructure() {
+		return nil
+	}
+
+	count := 0
+	return o.checkValue(m, 0, &count)
+}
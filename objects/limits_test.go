@@ -0,0 +1,189 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNewMapFromJSONWithOptions(t *testing.T) {
+
+	data := `{"name":"stew","owner":{"first":"Mat","tags":["a","b"]}}`
+
+	m, err := NewMapFromJSONWithOptions(data, DecodeOptions{MaxBytes: len(data), MaxDepth: 3, MaxKeys: 6, MaxStringLength: 5, DisallowDuplicateKeys: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Mat", m.Get("owner.first"))
+	}
+
+	_, err = NewMapFromJSONWithOptions(data, DecodeOptions{MaxBytes: len(data) - 1})
+	assert.Equal(t, &LimitError{Limit: LimitBytes, Max: len(data) - 1}, err)
+
+	_, err = NewMapFromJSONWithOptions(data, DecodeOptions{MaxDepth: 2})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 2}, err)
+
+	_, err = NewMapFromJSONWithOptions(data, DecodeOptions{MaxKeys: 5})
+	assert.Equal(t, &LimitError{Limit: LimitKeys, Max: 5}, err)
+
+	_, err = NewMapFromJSONWithOptions(data, DecodeOptions{MaxStringLength: 4})
+	assert.Equal(t, &LimitError{Limit: LimitStringLength, Max: 4}, err)
+
+	_, err = NewMapFromJSONWithOptions(`{"long_key":1}`, DecodeOptions{MaxStringLength: 4})
+	assert.Equal(t, &LimitError{Limit: LimitStringLength, Max: 4}, err)
+
+	// without the option, the last value wins
+	m, err = NewMapFromJSONWithOptions(`{"a":{"b":1,"b":2}}`, DecodeOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(2), m.Get("a.b"))
+	}
+
+	_, err = NewMapFromJSONWithOptions(`{"a":[{"x":1},{"b":1,"c":{},"b":2}]}`, DecodeOptions{DisallowDuplicateKeys: true})
+	assert.Equal(t, &DuplicateKeyError{Keypath: "a[1].b"}, err)
+
+	// the same key in different objects is fine
+	_, err = NewMapFromJSONWithOptions(`{"a":{"x":1},"b":{"x":1}}`, DecodeOptions{DisallowDuplicateKeys: true})
+	assert.NoError(t, err)
+
+	// syntax errors are still reported
+	_, err = NewMapFromJSONWithOptions(`{"a":`, DecodeOptions{MaxDepth: 3})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "JSON decode failed")
+	}
+
+	assert.Equal(t, "Map: Input has the key 'a.b' more than once.", (&DuplicateKeyError{Keypath: "a.b"}).Error())
+
+}
+
+func TestNewMapFromBase64StringWithOptions(t *testing.T) {
+
+	encoded, _ := M("name", "stew", "owner", M("first", "Mat")).Base64()
+
+	m, err := NewMapFromBase64StringWithOptions(encoded, DecodeOptions{MaxBytes: len(encoded), MaxDepth: 2})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Mat", m.Get("owner.first"))
+	}
+
+	_, err = NewMapFromBase64StringWithOptions(encoded, DecodeOptions{MaxBytes: len(encoded) - 1})
+	assert.Equal(t, &LimitError{Limit: LimitBytes, Max: len(encoded) - 1}, err)
+
+	_, err = NewMapFromBase64StringWithOptions(encoded, DecodeOptions{MaxDepth: 1})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 1}, err)
+
+	signed, _ := M("owner", M("first", "Mat")).SignedBase64("key")
+
+	m, err = NewMapFromSignedBase64StringWithOptions(signed, "key", DecodeOptions{MaxBytes: len(signed)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Mat", m.Get("owner.first"))
+	}
+
+	_, err = NewMapFromSignedBase64StringWithOptions(signed, "key", DecodeOptions{MaxBytes: 10})
+	assert.Equal(t, &LimitError{Limit: LimitBytes, Max: 10}, err)
+
+	_, err = NewMapFromSignedBase64StringWithOptions(signed, "key", DecodeOptions{MaxDepth: 1})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 1}, err)
+
+}
+
+func TestNewMapFromURLQueryWithOptions_DecodeLimits(t *testing.T) {
+
+	query := "name=stew&ids[]=1&ids[]=2"
+
+	_, err := NewMapFromURLQueryWithOptions(query, URLQueryOptions{Style: URLQueryBrackets, Limits: DecodeOptions{MaxBytes: len(query), MaxStringLength: 5, DisallowDuplicateKeys: true}})
+	assert.NoError(t, err)
+
+	_, err = NewMapFromURLQueryWithOptions(query, URLQueryOptions{Limits: DecodeOptions{MaxBytes: 10}})
+	assert.Equal(t, &LimitError{Limit: LimitBytes, Max: 10}, err)
+
+	_, err = NewMapFromURLQueryWithOptions(query, URLQueryOptions{Limits: DecodeOptions{MaxStringLength: 3}})
+	assert.Equal(t, &LimitError{Limit: LimitStringLength, Max: 3}, err)
+
+	_, err = NewMapFromURLQueryWithOptions("name=stew&name=objects", URLQueryOptions{Limits: DecodeOptions{DisallowDuplicateKeys: true}})
+	assert.Equal(t, &DuplicateKeyError{Keypath: "name"}, err)
+
+}
+
+func TestNewMapFromFormatsWithOptions(t *testing.T) {
+
+	yamlData := "owner:\n  first: Mat\n  tags: [a, b]\n"
+
+	_, err := NewMapFromYAMLWithOptions(yamlData, DecodeOptions{MaxDepth: 3, MaxKeys: 5})
+	assert.NoError(t, err)
+
+	_, err = NewMapFromYAMLWithOptions(yamlData, DecodeOptions{MaxBytes: 10})
+	assert.Equal(t, &LimitError{Limit: LimitBytes, Max: 10}, err)
+
+	_, err = NewMapFromYAMLWithOptions(yamlData, DecodeOptions{MaxDepth: 2})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 2}, err)
+
+	_, err = NewMapFromYAMLWithOptions(yamlData, DecodeOptions{MaxKeys: 4})
+	assert.Equal(t, &LimitError{Limit: LimitKeys, Max: 4}, err)
+
+	_, err = NewMapFromTOMLWithOptions("name = \"stew\"\n", DecodeOptions{MaxStringLength: 3})
+	assert.Equal(t, &LimitError{Limit: LimitStringLength, Max: 3}, err)
+
+	_, err = NewMapFromTOMLWithOptions("[a]\n[a.b]\nc = 1\n", DecodeOptions{MaxDepth: 2})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 2}, err)
+
+	data, _ := M("owner", M("first", "Mat")).MessagePack()
+
+	_, err = NewMapFromMessagePackWithOptions(data, DecodeOptions{MaxDepth: 2, DisallowDuplicateKeys: true})
+	assert.NoError(t, err)
+
+	_, err = NewMapFromMessagePackWithOptions(data, DecodeOptions{MaxDepth: 1})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 1}, err)
+
+	_, err = NewMapFromMessagePackWithOptions(data, DecodeOptions{MaxBytes: 5})
+	assert.Equal(t, &LimitError{Limit: LimitBytes, Max: 5}, err)
+
+	// {"a": 1, "a": 2}
+	duplicated := []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'a', 0x02}
+
+	m, err := NewMapFromMessagePackWithOptions(duplicated, DecodeOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, m.MustInt("a"))
+	}
+
+	_, err = NewMapFromMessagePackWithOptions(duplicated, DecodeOptions{DisallowDuplicateKeys: true})
+	assert.Equal(t, &DuplicateKeyError{Keypath: "a"}, err)
+
+}
+
+func TestNewDecoderWithOptions(t *testing.T) {
+
+	input := "{\"a\":1}\n{\"a\":{\"b\":1}}\n"
+
+	decoder := NewDecoderWithOptions(strings.NewReader(input), DecodeOptions{MaxDepth: 1})
+
+	m, err := decoder.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(1), m.Get("a"))
+	}
+
+	_, err = decoder.Decode()
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 1}, err)
+
+	decoder = NewDecoderWithOptions(strings.NewReader(input), DecodeOptions{MaxBytes: 10})
+
+	_, err = decoder.Decode()
+	assert.NoError(t, err)
+
+	_, err = decoder.Decode()
+	assert.Equal(t, &LimitError{Limit: LimitBytes, Max: 10}, err)
+
+	decoder = NewDecoderWithOptions(strings.NewReader(`[{"a":1,"a":2}]`), DecodeOptions{DisallowDuplicateKeys: true})
+
+	_, err = decoder.Decode()
+	assert.Equal(t, &DuplicateKeyError{Keypath: "a"}, err)
+
+	// the options don't get in the way of UseNumber
+	decoder = NewDecoderWithOptions(strings.NewReader(`{"id":9007199254740993}`), DecodeOptions{MaxDepth: 1}).UseNumber()
+
+	m, err = decoder.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(9007199254740993), m.MustInt64("id"))
+	}
+
+	_, err = decoder.Decode()
+	assert.Equal(t, io.EOF, err)
+
+}
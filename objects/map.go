@@ -2,6 +2,7 @@ package objects
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -84,6 +85,16 @@ func M(keyAndValuePairs ...interface{}) Map {
 
 // NewMapFromJSON creates a new map from a JSON string representation
 func NewMapFromJSON(data string) (Map, error) {
+	return NewMapFromJSONWithOptions(data, DecodeOptions{})
+}
+
+// NewMapFromJSONWithOptions creates a new map from a JSON string representation,
+// checking it against the limits in the options before it is decoded.
+func NewMapFromJSONWithOptions(data string, options DecodeOptions) (Map, error) {
+
+	if err := options.checkJSON([]byte(data)); err != nil {
+		return nil, err
+	}
 
 	var unmarshalled map[string]interface{}
 
@@ -99,6 +110,17 @@ func NewMapFromJSON(data string) (Map, error) {
 
 // NewMapFromBase64String creates a new map from a Base64 string representation
 func NewMapFromBase64String(data string) (Map, error) {
+	return NewMapFromBase64StringWithOptions(data, DecodeOptions{})
+}
+
+// NewMapFromBase64StringWithOptions creates a new map from a Base64 string
+// representation, checking it against the limits in the options.  MaxBytes
+// applies to the Base64 string.
+func NewMapFromBase64StringWithOptions(data string, options DecodeOptions) (Map, error) {
+
+	if err := options.checkBytes(len(data)); err != nil {
+		return nil, err
+	}
 
 	decoder := base64.NewDecoder(base64.StdEncoding, strings.NewReader(data))
 
@@ -107,12 +129,23 @@ func NewMapFromBase64String(data string) (Map, error) {
 		return nil, err
 	}
 
-	return NewMapFromJSON(string(decoded))
+	return NewMapFromJSONWithOptions(string(decoded), options)
 
 }
 
 // NewMapFromSignedBase64String creates a new map from a signed Base64 string representation
 func NewMapFromSignedBase64String(data, key string) (Map, error) {
+	return NewMapFromSignedBase64StringWithOptions(data, key, DecodeOptions{})
+}
+
+// NewMapFromSignedBase64StringWithOptions creates a new map from a signed Base64
+// string representation, checking it against the limits in the options.  MaxBytes
+// applies to the whole signed string.
+func NewMapFromSignedBase64StringWithOptions(data, key string, options DecodeOptions) (Map, error) {
+
+	if err := options.checkBytes(len(data)); err != nil {
+		return nil, err
+	}
 
 	parts := strings.Split(data, SignatureSeparator)
 	if len(parts) != 2 {
@@ -120,11 +153,11 @@ func NewMapFromSignedBase64String(data, key string) (Map, error) {
 	}
 
 	sig := signature.HashWithKey([]byte(parts[0]), []byte(key))
-	if parts[1] != sig {
+	if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(sig)) != 1 {
 		return nil, errors.New("Map: Signature for Base64 data does not match.")
 	}
 
-	return NewMapFromBase64String(parts[0])
+	return NewMapFromBase64StringWithOptions(parts[0], options)
 
 }
 
@@ -135,40 +168,42 @@ func NewMapFromSignedBase64String(data, key string) (Map, error) {
 //
 //     m.Get("name.Last")
 //     // returns "Ryer"
+//
+// Slices of objects can be indexed with square brackets, where negative
+// indexes count back from the end:
+//
+//     m.Get("users[0].name")
+//     m.Get("users[-1].name")
+//
+// Keys containing a PathSeparator can be addressed by escaping it with PathEscape,
+// for example `files.readme\.md`.
 func (d Map) Get(keypath string) interface{} {
 
-	var segs []string = strings.Split(keypath, PathSeparator)
+	value, _ := getPath(d, parseKeypath(keypath))
 
-	obj := d
-
-	for fieldIndex, field := range segs {
-
-		if fieldIndex == len(segs)-1 {
-			return obj[field]
-		}
-
-		switch obj[field].(type) {
-		case Map:
-			obj = obj[field].(Map)
-		case map[string]interface{}:
-			obj = Map(obj[field].(map[string]interface{}))
-		}
-
-	}
-
-	return obj
+	return value
 
 }
 
-// GetMap gets another Map from this one, or panics if the object is missing or not a Map.
+// GetMap gets another Map from this one, or panics with a *TypeError if the object
+// is missing or not a Map.  Use GetMapOrError to get the error instead.
 func (d Map) GetMap(keypath string) Map {
-	return d.Get(keypath).(Map)
+	m, err := d.GetMapOrError(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return m
 }
 
-// GetString gets a string value from the map at the given keypath, or panics if one
-// is not available, or is of the wrong type.
+// GetString gets a string value from the map at the given keypath, or panics with a
+// *TypeError if one is not available, or is of the wrong type.  Use GetStringOrError
+// to get the error instead.
 func (d Map) GetString(keypath string) string {
-	return d.Get(keypath).(string)
+	s, err := d.GetStringOrError(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return s
 }
 
 // GetWithDefault gets the value at the specified keypath, or returns the defaultValue if
@@ -208,37 +243,95 @@ func (d Map) GetStringOrEmpty(keypath string) string {
 // The above code sets the 'first' field on the 'name' object in the m Map.
 //
 // If objects are nil along the way, Set creates new Map objects as needed.
+//
+// Indexes may also be used, in which case slices are created or grown (padded
+// with nil) to fit:
+//
+//     m.Set("users[2].name", "Tyler")
+//
+// Negative indexes that fall before the start of a slice, or that are used
+// on something that isn't a slice, are ignored and nothing is set, as are
+// indexes that would grow a slice by more than DefaultMaxIndex items.
 func (d Map) Set(keypath string, value interface{}) Map {
 
-	var segs []string
-	segs = strings.Split(keypath, PathSeparator)
-
-	obj := d
+	segs := parseKeypath(keypath)
 
-	for fieldIndex, field := range segs {
+	// the Map itself cannot be indexed
+	if len(segs) == 0 || segs[0].isIndex || !canSetPath(d, segs) {
+		return d
+	}
 
-		if fieldIndex == len(segs)-1 {
-			obj[field] = value
-		}
+	d[segs[0].key] = setPath(d[segs[0].key], segs[1:], value)
 
-		if _, exists := obj[field]; !exists {
-			obj[field] = make(Map)
-			obj = obj[field].(Map)
-		} else {
-			switch obj[field].(type) {
-			case Map:
-				obj = obj[field].(Map)
-			case map[string]interface{}:
-				obj = Map(obj[field].(map[string]interface{}))
-			}
-		}
+	// chain
+	return d
+}
 
-	}
+// Delete removes the value at the keypath from the map, and returns the
+// current map.  Supports the same keypaths as Set; items deleted from slices
+// shift the later items down.
+//
+// For example,
+//
+//     m.Delete("name.first")
+//     m.Delete("users[0]")
+//
+// Nothing happens if there is no value at the keypath.
+func (d Map) Delete(keypath string) Map {
+	d.Pop(keypath)
 
 	// chain
 	return d
 }
 
+// Pop removes the value at the keypath from the map and returns it, along with
+// whether there was a value to remove.
+func (d Map) Pop(keypath string) (interface{}, bool) {
+	return d.pop(keypath, false)
+}
+
+// pop removes the value at the keypath, optionally removing any maps and
+// slices left empty along the way.
+func (d Map) pop(keypath string, prune bool) (interface{}, bool) {
+
+	segs := parseKeypath(keypath)
+	if len(segs) == 0 || segs[0].isIndex {
+		return nil, false
+	}
+
+	_, removed, ok := deletePath(d, segs, prune)
+
+	return removed, ok
+}
+
+// Move moves the value at the from keypath to the to keypath, creating objects
+// along the way as Set does.  It returns false (and does nothing) if there
+// was no value at the from keypath, or if Set could not set a value at the to
+// keypath once it was removed.
+//
+// For example, to rename a key:
+//
+//     m.Move("name.first", "name.given")
+func (d Map) Move(from, to string) bool {
+
+	fromSegs, toSegs := parseKeypath(from), parseKeypath(to)
+	if len(fromSegs) == 0 || fromSegs[0].isIndex || len(toSegs) == 0 || toSegs[0].isIndex {
+		return false
+	}
+
+	// check the to keypath against the map as it will be after the value
+	// is removed, since removing it may shift items in slices
+	without, ok := withoutPath(d, fromSegs)
+	if !ok || !canSetPath(without, toSegs) {
+		return false
+	}
+
+	value, _ := d.Pop(from)
+	d.Set(to, value)
+
+	return true
+}
+
 // Exclude returns a new Map with the keys in the specified []string
 // excluded.
 func (d Map) Exclude(exclude []string) Map {
@@ -260,6 +353,49 @@ func (d Map) Exclude(exclude []string) Map {
 	return excluded
 }
 
+// ExcludePaths returns a deep copy of the Map with the values at the specified
+// keypaths removed.
+//
+// If prune is true, any maps and slices that are left empty once the values are
+// removed are removed too.
+//
+// For example:
+//
+//     m.ExcludePaths([]string{"user.password", "user.tokens[0]"}, true)
+func (d Map) ExcludePaths(keypaths []string, prune bool) Map {
+
+	excluded := d.DeepCopy()
+	for _, keypath := range keypaths {
+		excluded.pop(keypath, prune)
+	}
+
+	return excluded
+}
+
+// Only returns a new Map containing deep copies of only the values at the
+// specified keypaths, nested as they were in this Map.
+//
+// For example:
+//
+//     m.Only("user.name", "user.email")
+//     // returns Map{"user": Map{"name": ..., "email": ...}}
+//
+// Keypaths with no value are skipped.  Items in slices keep their index, even
+// if it was given as a negative one.
+func (d Map) Only(keypaths ...string) Map {
+
+	only := make(Map)
+	for _, keypath := range keypaths {
+		segs := parseKeypath(keypath)
+		if value, ok := getPath(d, segs); ok {
+			segs = resolvePath(d, segs)
+			only[segs[0].key] = setPath(only[segs[0].key], segs[1:], deepCopyValue(value))
+		}
+	}
+
+	return only
+}
+
 // Copy creates a shallow copy of the Map.
 func (d Map) Copy() Map {
 	copied := make(Map)
@@ -269,6 +405,18 @@ func (d Map) Copy() Map {
 	return copied
 }
 
+// DeepCopy creates a copy of the Map, also copying any nested maps and slices
+// so that changes to the copy never affect the original.
+//
+// Values other than maps and slices (including pointers) are copied as-is.
+func (d Map) DeepCopy() Map {
+	copied := make(Map)
+	for k, v := range d {
+		copied[k] = deepCopyValue(v)
+	}
+	return copied
+}
+
 // Merge blends the specified map with a copy of this map and returns the result.
 //
 // Keys that appear in both will be selected from the specified map.
@@ -356,6 +504,10 @@ func (d Map) SignedBase64(key string) (string, error) {
 
 // Hash gets the hash of the map with no security key.
 //
+// The hash depends on how encoding/json writes the map, so maps holding the same
+// values as different types may hash differently.  Use CanonicalHash for hashes
+// that are shared with other services, like cache keys and ETags.
+//
 // Will return an error if Base64ing the map fails.
 func (d Map) Hash() (string, error) {
 	return d.HashWithKey("")
@@ -386,36 +538,23 @@ func (d Map) HashWithKey(key string) (string, error) {
 // NewMapFromURLQuery generates a new map by parsing the specified
 // query.
 //
-// For queries with multiple values, the first value is selected.
+// Keys are treated as keypaths, so `name.first=Mat` sets a nested value, and
+// keys with multiple values become arrays.  See NewMapFromURLQueryWithOptions
+// for more control.
 func NewMapFromURLQuery(query string) (Map, error) {
-
-	vals, err := url.ParseQuery(query)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return NewMapFromURLValues(vals)
+	return NewMapFromURLQueryWithOptions(query, URLQueryOptions{})
 }
 
+// URLValues gets the url.Values representing the given map.  Nested objects
+// and arrays are written as keypaths, like `name.first=Mat` and `ids[0]=1`.
 func (d Map) URLValues() url.Values {
-
-	vals := make(url.Values)
-
-	for k, v := range d {
-		vals.Set(k, fmt.Sprintf("%v", v))
-	}
-
-	return vals
+	return d.URLValuesWithOptions(URLQueryOptions{})
 }
 
+// NewMapFromURLValues generates a new map from the url.Values, treating keys
+// as keypaths in the same way as NewMapFromURLQuery.
 func NewMapFromURLValues(vals url.Values) (Map, error) {
-	m := make(Map)
-	for k, vals := range vals {
-		m.Set(k, vals[0])
-	}
-
-	return m, nil
+	return NewMapFromURLValuesWithOptions(vals, URLQueryOptions{})
 }
 
 // URLQuery gets an encoded URL query representing the given
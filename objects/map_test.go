@@ -56,6 +56,35 @@ func TestCopy(t *testing.T) {
 
 }
 
+func TestDeepCopy(t *testing.T) {
+
+	d1 := Map{
+		"name":    "Tyler",
+		"address": Map{"city": "Boulder"},
+		"native":  map[string]interface{}{"active": true},
+		"tags":    []interface{}{"a", Map{"b": 1}},
+		"maps":    []Map{{"name": "one"}},
+		"strings": []string{"x"},
+	}
+
+	d2 := d1.DeepCopy()
+	assert.Equal(t, d1, d2)
+
+	d2.Set("address.city", "Salt Lake City")
+	d2.Set("native.active", false)
+	d2.Set("tags[1].b", 2)
+	d2.Set("maps[0].name", "changed")
+	d2["strings"].([]string)[0] = "y"
+
+	assert.Equal(t, "Boulder", d1.Get("address.city"))
+	assert.Equal(t, true, d1.Get("native.active"))
+	assert.Equal(t, 1, d1.Get("tags[1].b"))
+	assert.Equal(t, "one", d1.Get("maps[0].name"))
+	assert.Equal(t, []string{"x"}, d1["strings"])
+	assert.IsType(t, map[string]interface{}{}, d2["native"])
+
+}
+
 func TestMerge(t *testing.T) {
 
 	d := make(Map)
@@ -160,6 +189,127 @@ func TestExclude(t *testing.T) {
 
 }
 
+func TestDelete(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"name":{"first":"Mat","last":"Ryer"},"users":[{"name":"Mat"},{"name":"Tyler"}],"a.b":1}`)
+
+	assert.Equal(t, m, m.Delete("name.first"))
+	assert.False(t, m.Has("name.first"))
+	assert.Equal(t, "Ryer", m.Get("name.last"))
+
+	m.Delete("users[0]")
+	assert.Equal(t, "Tyler", m.Get("users[0].name"))
+	assert.Len(t, m["users"], 1)
+
+	m.Delete("users[-1].name")
+	assert.Equal(t, map[string]interface{}{}, m.Get("users[0]"))
+
+	m.Delete(`a\.b`)
+	assert.False(t, m.Has(`a\.b`))
+
+	// nothing there
+	m.Delete("nope.nothing").Delete("users[5]").Delete("name.last.deeper")
+	assert.Equal(t, "Ryer", m.Get("name.last"))
+
+}
+
+func TestDelete_TypedSlices(t *testing.T) {
+
+	m := Map{"maps": []Map{{"name": "one"}, {"name": "two"}}, "strings": []string{"a", "b"}}
+	original := m["maps"].([]Map)
+
+	m.Delete("maps[0]").Delete("strings[-1]")
+	assert.Equal(t, []Map{{"name": "two"}}, m["maps"])
+	assert.Equal(t, []string{"a"}, m["strings"])
+	assert.Equal(t, "one", original[0]["name"], "the original slice is untouched")
+
+}
+
+func TestPop(t *testing.T) {
+
+	m := Map{"name": Map{"first": "Mat"}, "tags": []interface{}{"a", "b"}}
+
+	value, ok := m.Pop("name.first")
+	assert.True(t, ok)
+	assert.Equal(t, "Mat", value)
+	assert.Equal(t, Map{}, m["name"])
+
+	value, ok = m.Pop("tags[0]")
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+	assert.Equal(t, []interface{}{"b"}, m["tags"])
+
+	value, ok = m.Pop("name.first")
+	assert.False(t, ok)
+	assert.Nil(t, value)
+
+	_, ok = m.Pop("[0]")
+	assert.False(t, ok)
+
+}
+
+func TestMove(t *testing.T) {
+
+	m := Map{"name": Map{"first": "Mat"}}
+
+	assert.True(t, m.Move("name.first", "name.given"))
+	assert.Equal(t, Map{"name": Map{"given": "Mat"}}, m)
+
+	assert.True(t, m.Move("name", "people[0].name"))
+	assert.Equal(t, "Mat", m.Get("people[0].name.given"))
+	assert.False(t, m.Has("name"))
+
+	assert.False(t, m.Move("nope", "somewhere"))
+	assert.False(t, m.Has("somewhere"))
+
+	// values aren't removed if they can't be set
+	m = Map{"a": 1, "b": []interface{}{"x"}}
+	assert.False(t, m.Move("a", "[0]"))
+	assert.False(t, m.Move("a", "missing[-1]"))
+	assert.False(t, m.Move("b[0]", "b[-1]"), "b is empty once x is removed")
+	assert.Equal(t, Map{"a": 1, "b": []interface{}{"x"}}, m)
+
+}
+
+func TestExcludePaths(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"user":{"name":"Mat","password":"secret","tokens":["abc"]},"meta":{"secret":1}}`)
+
+	excluded := m.ExcludePaths([]string{"user.password", "user.tokens[0]", "meta.secret"}, false)
+	assert.Equal(t, Map{
+		"user": map[string]interface{}{"name": "Mat", "tokens": []interface{}{}},
+		"meta": map[string]interface{}{},
+	}, excluded)
+
+	pruned := m.ExcludePaths([]string{"user.password", "user.tokens[0]", "meta.secret"}, true)
+	assert.Equal(t, Map{"user": map[string]interface{}{"name": "Mat"}}, pruned)
+
+	// the original is untouched
+	assert.Equal(t, "secret", m.Get("user.password"))
+	assert.Equal(t, "abc", m.Get("user.tokens[0]"))
+
+}
+
+func TestOnly(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"user":{"name":"Mat","email":"mat@example.com","password":"secret"},"users":[{"name":"Tyler","age":30}]}`)
+
+	only := m.Only("user.name", "user.email", "users[0].name", "nope")
+	assert.Equal(t, Map{
+		"user":  Map{"name": "Mat", "email": "mat@example.com"},
+		"users": []interface{}{Map{"name": "Tyler"}},
+	}, only)
+
+	only.Set("user.name", "changed")
+	assert.Equal(t, "Mat", m.Get("user.name"))
+
+	// negative indexes keep the item where it was
+	m = Map{"users": []interface{}{"x", Map{"name": "y", "age": 1}}}
+	assert.Equal(t, Map{"users": []interface{}{nil, Map{"name": "y"}}}, m.Only("users[-1].name"))
+	assert.Equal(t, Map{"users": []interface{}{"x"}}, m.Only("users[-2]"))
+
+}
+
 func TestHas(t *testing.T) {
 
 	d := make(Map)
@@ -262,6 +412,111 @@ func TestSet_Deep(t *testing.T) {
 
 }
 
+func TestGet_WithIndexes(t *testing.T) {
+
+	var m Map = Map{
+		"users": []interface{}{
+			map[string]interface{}{"name": "Mat"},
+			Map{"name": "Tyler", "tags": []interface{}{"a", "b"}},
+		},
+		"maps":  []Map{{"name": "one"}, {"name": "two"}},
+		"msis":  []map[string]interface{}{{"name": "first"}},
+		"files": Map{"readme.md": "hello"},
+	}
+
+	assert.Equal(t, "Mat", m.Get("users[0].name"))
+	assert.Equal(t, "Tyler", m.Get("users[1].name"))
+	assert.Equal(t, "Tyler", m.Get("users[-1].name"))
+	assert.Equal(t, "b", m.Get("users[1].tags[1]"))
+	assert.Equal(t, "two", m.Get("maps[-1].name"))
+	assert.Equal(t, "first", m.Get("msis[0].name"))
+	assert.Equal(t, "hello", m.Get(`files.readme\.md`))
+
+	assert.Nil(t, m.Get("users[2].name"))
+	assert.Nil(t, m.Get("users[-3].name"))
+	assert.Nil(t, m.Get("users.name"))
+	assert.Nil(t, m.Get("files[0]"))
+	assert.Nil(t, m.Get("files.readme.md"))
+
+}
+
+func TestSet_WithIndexes(t *testing.T) {
+
+	m := make(Map)
+
+	m.Set("users[1].name", "Tyler")
+	if assert.Equal(t, []interface{}{nil, Map{"name": "Tyler"}}, m["users"]) {
+		assert.Equal(t, "Tyler", m.Get("users[1].name"))
+	}
+
+	m.Set("users[0].name", "Mat")
+	assert.Equal(t, "Mat", m.Get("users[0].name"))
+
+	m.Set("users[-1].age", 29)
+	assert.Equal(t, 29, m.Get("users[1].age"))
+
+	// negative indexes can't grow a slice
+	m.Set("users[-3].name", "Nobody")
+	assert.Len(t, m["users"], 2)
+
+	// nor make one where there isn't one
+	m.Set("name", "x").Set("name[-1]", 1).Set("nope[-1]", 1).Set("deep.nope[-1].a", 1)
+	assert.Equal(t, "x", m["name"])
+	assert.NotContains(t, m, "nope")
+	assert.NotContains(t, m, "deep")
+
+	// nor grow one by more than DefaultMaxIndex items
+	m.Set("ids[9223372036854775806]", 1).Set("users[1002]", 1)
+	assert.NotContains(t, m, "ids")
+	assert.Len(t, m["users"], 2)
+	m.Set("users[1001]", 1)
+	assert.Len(t, m["users"], 1002)
+	m["users"] = m["users"].([]interface{})[:2]
+
+	m.Set("grid[1][1]", "x")
+	assert.Equal(t, []interface{}{nil, []interface{}{nil, "x"}}, m["grid"])
+
+	m.Set(`files.readme\.md`, "hello")
+	assert.Equal(t, "hello", m.GetMap("files")["readme.md"])
+
+	m.Set("[0]", "ignored")
+	assert.Nil(t, m["[0]"])
+	assert.Nil(t, m[""])
+
+}
+
+func TestSet_WithTypedSlices(t *testing.T) {
+
+	m := Map{
+		"maps": []Map{{"name": "one"}},
+		"msis": []map[string]interface{}{{"name": "first"}},
+	}
+
+	m.Set("maps[1].name", "two")
+	if assert.IsType(t, []Map{}, m["maps"]) {
+		assert.Equal(t, "two", m.Get("maps[1].name"))
+	}
+
+	m.Set("msis[0].name", "changed")
+	if assert.IsType(t, []map[string]interface{}{}, m["msis"]) {
+		assert.Equal(t, "changed", m.Get("msis[0].name"))
+	}
+
+	m.Set("strings", []string{"a"})
+	m.Set("strings[1]", "b")
+	if assert.IsType(t, []string{}, m["strings"]) {
+		assert.Equal(t, "b", m.Get("strings[-1]"))
+	}
+
+	// values that don't fit the slice type convert it
+	m.Set("maps[2]", "three")
+	if assert.IsType(t, []interface{}{}, m["maps"]) {
+		assert.Equal(t, "one", m.Get("maps[0].name"))
+		assert.Equal(t, "three", m.Get("maps[2]"))
+	}
+
+}
+
 func Test_GetMap(t *testing.T) {
 
 	var parent Map = make(Map)
@@ -0,0 +1,320 @@
+package objects
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// SliceMergeStrategy decides what MergeWith does when both maps have
+// a slice at the same keypath.
+type SliceMergeStrategy int
+
+const (
+	// SliceReplace uses the slice from the other map.
+	SliceReplace SliceMergeStrategy = iota
+	// SliceAppend appends the items from the other map's slice.
+	SliceAppend
+	// SliceUnion appends only the items from the other map's slice that
+	// are not already present.  If MergeOptions.UnionKey is set, objects with
+	// the same value at that keypath are considered the same item and
+	// are merged together.
+	SliceUnion
+)
+
+// ConflictStrategy decides what MergeWith does when both maps have
+// different values at the same keypath that cannot be merged.
+type ConflictStrategy int
+
+const (
+	// ConflictRightWins uses the value from the other map, which is how
+	// Merge behaves.
+	ConflictRightWins ConflictStrategy = iota
+	// ConflictLeftWins keeps the value from this map.
+	ConflictLeftWins
+	// ConflictError stops the merge and returns a *MergeConflictError.
+	ConflictError
+)
+
+// MergeOptions controls how MergeWith combines two maps.  The zero value
+// merges nested maps, replaces slices and lets the other map win conflicts.
+type MergeOptions struct {
+	// Slices is the strategy for slices present in both maps.
+	Slices SliceMergeStrategy
+	// UnionKey is the keypath used to identify objects in slices when
+	// Slices is SliceUnion.
+	UnionKey string
+	// Conflicts is the strategy for values that cannot be merged.
+	Conflicts ConflictStrategy
+	// Resolver, if set, is called for every conflict instead of applying
+	// the Conflicts strategy.  It is given the keypath and both values,
+	// and returns the value to use.
+	Resolver func(keypath string, left, right interface{}) (interface{}, error)
+}
+
+// MergeConflictError is returned by MergeWith when the ConflictError strategy
+// is used and both maps have different values at the same keypath.
+type MergeConflictError struct {
+	Keypath string
+	Left    interface{}
+	Right   interface{}
+}
+
+// Error gets the error message.
+func (e *MergeConflictError) Error() string {
+	return fmt.Sprintf("Map: Merge conflict at '%s' between %v and %v.", e.Keypath, e.Left, e.Right)
+}
+
+// MergeWith deeply blends the specified map with a copy of this map and returns
+// the result.  Neither map is modified.
+//
+// Unlike Merge, maps nested at the same keypath are merged together rather than
+// replaced, and the options decide what happens to slices and conflicting values.
+//
+// For example, to layer configuration:
+//
+//     config, err := defaults.MergeWith(overrides, objects.MergeOptions{Slices: objects.SliceAppend})
+func (d Map) MergeWith(merge Map, options MergeOptions) (Map, error) {
+
+	return mergeMaps("", d.DeepCopy(), merge, &options)
+}
+
+// childKeypath makes the keypath for a key within the parent keypath.
+func childKeypath(parent, key string) string {
+	if parent == "" {
+		return EscapeKey(key)
+	}
+	return parent + PathSeparator + EscapeKey(key)
+}
+
+// indexKeypath makes the keypath for an index within the parent keypath.
+func indexKeypath(parent string, index int) string {
+	return fmt.Sprintf("%s%s%d%s", parent, pathIndexOpen, index, pathIndexClose)
+}
+
+// mergeMaps merges the right map into the left one, which must already be a
+// copy that is safe to modify.
+func mergeMaps(keypath string, left, right Map, options *MergeOptions) (Map, error) {
+
+	if left == nil && len(right) > 0 {
+		left = make(Map)
+	}
+
+	for k, rightValue := range right {
+
+		leftValue, exists := left[k]
+		if !exists {
+			left[k] = deepCopyValue(rightValue)
+			continue
+		}
+
+		merged, err := mergeValues(childKeypath(keypath, k), leftValue, rightValue, options)
+		if err != nil {
+			return nil, err
+		}
+		left[k] = merged
+
+	}
+
+	return left, nil
+}
+
+// mergeValues merges two values found at the same keypath.
+func mergeValues(keypath string, left, right interface{}, options *MergeOptions) (interface{}, error) {
+
+	if leftMap, ok := asMap(left); ok {
+		if rightMap, ok := asMap(right); ok {
+			merged, err := mergeMaps(keypath, leftMap, rightMap, options)
+			if err != nil {
+				return nil, err
+			}
+			if _, native := left.(map[string]interface{}); native {
+				return merged.MSI(), nil
+			}
+			return merged, nil
+		}
+	}
+
+	if _, ok := sliceLen(left); ok {
+		if _, ok := sliceLen(right); ok {
+			return mergeSlices(keypath, left, right, options)
+		}
+	}
+
+	if reflect.DeepEqual(left, right) {
+		return left, nil
+	}
+
+	return resolveConflict(keypath, left, right, options)
+}
+
+// resolveConflict picks between two values that could not be merged.
+func resolveConflict(keypath string, left, right interface{}, options *MergeOptions) (interface{}, error) {
+
+	if options.Resolver != nil {
+		return options.Resolver(keypath, left, right)
+	}
+
+	switch options.Conflicts {
+	case ConflictLeftWins:
+		return left, nil
+	case ConflictError:
+		return nil, &MergeConflictError{Keypath: keypath, Left: left, Right: right}
+	}
+
+	return deepCopyValue(right), nil
+}
+
+// mergeSlices merges two slices found at the same keypath according to the
+// slice strategy.
+func mergeSlices(keypath string, left, right interface{}, options *MergeOptions) (interface{}, error) {
+
+	switch options.Slices {
+	case SliceAppend:
+		return append(toInterfaces(left), toInterfaces(deepCopyValue(right))...), nil
+	case SliceUnion:
+		return unionSlices(keypath, toInterfaces(left), toInterfaces(right), options)
+	}
+
+	return deepCopyValue(right), nil
+}
+
+// unionSlices adds the items from right that are not in left, merging objects
+// that share the same UnionKey value.  Items that aren't both objects with
+// the UnionKey are compared whole.
+func unionSlices(keypath string, left, right []interface{}, options *MergeOptions) ([]interface{}, error) {
+
+	for _, rightItem := range right {
+
+		found := false
+
+		for leftIndex, leftItem := range left {
+
+			if sameID, keyed := unionKeyMatch(leftItem, rightItem, options.UnionKey); keyed {
+
+				if !sameID {
+					continue
+				}
+
+				merged, err := mergeValues(indexKeypath(keypath, leftIndex), leftItem, rightItem, options)
+				if err != nil {
+					return nil, err
+				}
+				left[leftIndex] = merged
+				found = true
+				break
+
+			}
+
+			if reflect.DeepEqual(leftItem, rightItem) {
+				found = true
+				break
+			}
+
+		}
+
+		if !found {
+			left = append(left, deepCopyValue(rightItem))
+		}
+
+	}
+
+	return left, nil
+}
+
+// unionKeyMatch gets whether two items have the same value at the union key,
+// and whether they could be compared that way at all; that is, whether they
+// are both maps with a value at the key.
+func unionKeyMatch(left, right interface{}, unionKey string) (sameID, keyed bool) {
+
+	if unionKey == "" {
+		return false, false
+	}
+
+	leftMap, leftOK := asMap(left)
+	rightMap, rightOK := asMap(right)
+	if !leftOK || !rightOK {
+		return false, false
+	}
+
+	segs := parseKeypath(unionKey)
+	leftID, leftHas := getPath(leftMap, segs)
+	rightID, rightHas := getPath(rightMap, segs)
+	if !leftHas || !rightHas {
+		return false, false
+	}
+
+	return reflect.DeepEqual(leftID, rightID), true
+}
+
+// toInterfaces gets any slice that keypaths can index into as a new []interface{}.
+func toInterfaces(value interface{}) []interface{} {
+
+	length, _ := sliceLen(value)
+	items := make([]interface{}, length)
+	for i := range items {
+		items[i] = sliceItem(value, i)
+	}
+
+	return items
+}
+
+// deepCopyValue copies maps and slices all the way down, keeping their types.
+func deepCopyValue(value interface{}) interface{} {
+
+	switch value.(type) {
+	case Map:
+		original := value.(Map)
+		if original == nil {
+			return original
+		}
+		copied := make(Map, len(original))
+		for k, v := range original {
+			copied[k] = deepCopyValue(v)
+		}
+		return copied
+	case map[string]interface{}:
+		original := value.(map[string]interface{})
+		if original == nil {
+			return original
+		}
+		return deepCopyValue(Map(original)).(Map).MSI()
+	case []interface{}:
+		original := value.([]interface{})
+		if original == nil {
+			return original
+		}
+		copied := make([]interface{}, len(original))
+		for i, v := range original {
+			copied[i] = deepCopyValue(v)
+		}
+		return copied
+	case []Map:
+		original := value.([]Map)
+		if original == nil {
+			return original
+		}
+		copied := make([]Map, len(original))
+		for i, v := range original {
+			copied[i], _ = deepCopyValue(v).(Map)
+		}
+		return copied
+	case []map[string]interface{}:
+		original := value.([]map[string]interface{})
+		if original == nil {
+			return original
+		}
+		copied := make([]map[string]interface{}, len(original))
+		for i, v := range original {
+			copied[i], _ = deepCopyValue(v).(map[string]interface{})
+		}
+		return copied
+	case []string:
+		original := value.([]string)
+		if original == nil {
+			return original
+		}
+		return append([]string{}, original...)
+	}
+
+	return value
+}
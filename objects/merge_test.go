@@ -0,0 +1,143 @@
+package objects
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMergeWith_Nested(t *testing.T) {
+
+	defaults := Map{
+		"server": Map{"host": "localhost", "port": 8080},
+		"debug":  false,
+	}
+	overrides := Map{
+		"server": map[string]interface{}{"port": 9090},
+		"name":   "stew",
+	}
+
+	merged, err := defaults.MergeWith(overrides, MergeOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "localhost", merged.Get("server.host"))
+		assert.Equal(t, 9090, merged.Get("server.port"))
+		assert.Equal(t, false, merged.Get("debug"))
+		assert.Equal(t, "stew", merged.Get("name"))
+	}
+
+	// neither map is modified
+	assert.Equal(t, 8080, defaults.Get("server.port"))
+	assert.Nil(t, defaults.Get("name"))
+	assert.Nil(t, overrides.Get("server.host"))
+
+}
+
+func TestMergeWith_Slices(t *testing.T) {
+
+	left := Map{"tags": []interface{}{"a", "b"}}
+	right := Map{"tags": []string{"b", "c"}}
+
+	merged, err := left.MergeWith(right, MergeOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"b", "c"}, merged["tags"])
+	}
+
+	merged, err = left.MergeWith(right, MergeOptions{Slices: SliceAppend})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{"a", "b", "b", "c"}, merged["tags"])
+	}
+
+	merged, err = left.MergeWith(right, MergeOptions{Slices: SliceUnion})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{"a", "b", "c"}, merged["tags"])
+	}
+
+	assert.Equal(t, []interface{}{"a", "b"}, left["tags"])
+
+}
+
+func TestMergeWith_SliceUnionByKey(t *testing.T) {
+
+	left := Map{"users": []interface{}{
+		Map{"id": 1, "name": "Mat"},
+		Map{"id": 2, "name": "Tyler"},
+	}}
+	right := Map{"users": []interface{}{
+		Map{"id": 2, "admin": true},
+		Map{"id": 3, "name": "Ryan"},
+	}}
+
+	merged, err := left.MergeWith(right, MergeOptions{Slices: SliceUnion, UnionKey: "id"})
+	if assert.NoError(t, err) {
+		assert.Len(t, merged["users"], 3)
+		assert.Equal(t, "Mat", merged.Get("users[0].name"))
+		assert.Equal(t, "Tyler", merged.Get("users[1].name"))
+		assert.Equal(t, true, merged.Get("users[1].admin"))
+		assert.Equal(t, "Ryan", merged.Get("users[2].name"))
+	}
+
+	assert.Nil(t, left.Get("users[1].admin"))
+
+	// items that aren't keyed objects are compared whole
+	left = Map{"items": []interface{}{"x", Map{"id": 1}, Map{"name": "a"}}}
+	right = Map{"items": []interface{}{"x", Map{"id": 1, "n": 2}, Map{"name": "a"}, "y"}}
+	merged, err = left.MergeWith(right, MergeOptions{Slices: SliceUnion, UnionKey: "id"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{"x", Map{"id": 1, "n": 2}, Map{"name": "a"}, "y"}, merged["items"])
+	}
+
+}
+
+func TestMergeWith_Conflicts(t *testing.T) {
+
+	left := Map{"server": Map{"port": 8080, "host": "localhost"}}
+	right := Map{"server": Map{"port": 9090, "host": "localhost"}}
+
+	merged, err := left.MergeWith(right, MergeOptions{Conflicts: ConflictLeftWins})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 8080, merged.Get("server.port"))
+	}
+
+	merged, err = left.MergeWith(right, MergeOptions{Conflicts: ConflictRightWins})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 9090, merged.Get("server.port"))
+	}
+
+	merged, err = left.MergeWith(right, MergeOptions{Conflicts: ConflictError})
+	assert.Nil(t, merged)
+	assert.Equal(t, &MergeConflictError{Keypath: "server.port", Left: 8080, Right: 9090}, err)
+	assert.Equal(t, "Map: Merge conflict at 'server.port' between 8080 and 9090.", err.Error())
+
+	// the same value in both isn't a conflict
+	_, err = Map{"a": 1}.MergeWith(Map{"a": 1}, MergeOptions{Conflicts: ConflictError})
+	assert.NoError(t, err)
+
+}
+
+func TestMergeWith_Resolver(t *testing.T) {
+
+	left := Map{"counts": Map{"a.b": 1}, "name": "Mat"}
+	right := Map{"counts": Map{"a.b": 2}}
+
+	var keypaths []string
+	merged, err := left.MergeWith(right, MergeOptions{
+		Conflicts: ConflictError,
+		Resolver: func(keypath string, l, r interface{}) (interface{}, error) {
+			keypaths = append(keypaths, keypath)
+			return l.(int) + r.(int), nil
+		},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, merged.Get(`counts.a\.b`))
+		assert.Equal(t, []string{`counts.a\.b`}, keypaths)
+	}
+
+	resolverErr := errors.New("no")
+	_, err = left.MergeWith(right, MergeOptions{
+		Resolver: func(keypath string, l, r interface{}) (interface{}, error) {
+			return nil, resolverErr
+		},
+	})
+	assert.Equal(t, resolverErr, err)
+
+}
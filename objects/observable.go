@@ -0,0 +1,349 @@
+package objects
+
+import (
+	"sync"
+)
+
+// ChangeOp is the kind of change made to an ObservableMap.
+type ChangeOp int
+
+const (
+	// ChangeSet is a value being set, whether or not there was one before.
+	ChangeSet ChangeOp = iota
+	// ChangeDelete is a value being deleted.
+	ChangeDelete
+)
+
+// String gets the name of the operation.
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeSet:
+		return "set"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Change describes a single change to an ObservableMap.
+type Change struct {
+	// Keypath is where the change happened, as given to Set or Delete.
+	Keypath string
+	// Op is the kind of change.
+	Op ChangeOp
+	// Old is the value before the change, or nil if there wasn't one.
+	Old interface{}
+	// New is the value after the change, or nil if it was deleted.
+	New interface{}
+}
+
+// ChangeHandler is called with the changes made to an ObservableMap.  A
+// single change is delivered on its own, while the changes made in a
+// Transaction are delivered together.
+type ChangeHandler func(changes []*Change)
+
+// Subscription is a handler that is called when values matching a keypath
+// pattern change.
+type Subscription struct {
+	observable *ObservableMap
+	pattern    string
+	glob       keypathGlob
+	handler    ChangeHandler
+}
+
+// Pattern gets the keypath pattern the subscription was made with.
+func (s *Subscription) Pattern() string {
+	return s.pattern
+}
+
+// matches gets whether the change is to a value matching the pattern, or to
+// a map or slice that contains (or contained) one.
+func (s *Subscription) matches(change *Change) bool {
+
+	if s.glob.matchWithin(change.Keypath) {
+		return true
+	}
+
+	isContainer := func(value interface{}) bool {
+		_, isMap := asMap(value)
+		_, isSlice := sliceLen(value)
+		return isMap || isSlice
+	}
+
+	return (isContainer(change.Old) || isContainer(change.New)) && s.glob.matchBelow(change.Keypath)
+}
+
+// Unsubscribe stops the handler from being called for any later changes.
+func (s *Subscription) Unsubscribe() {
+
+	s.observable.mutex.Lock()
+	defer s.observable.mutex.Unlock()
+
+	for i, subscription := range s.observable.subscriptions {
+		if subscription == s {
+			s.observable.subscriptions = append(s.observable.subscriptions[:i:i], s.observable.subscriptions[i+1:]...)
+			break
+		}
+	}
+
+}
+
+// ObservableMap is a Map that tells subscribers when values in it change, for
+// example so that components holding live configuration can react to it
+// being reloaded.  It is safe to use from many goroutines at once, and like
+// SyncMap copies maps and slices on the way in and out.
+//
+// Handlers are called in the goroutine that made the change, after it has
+// been made, and may use the ObservableMap themselves.  Changes made from
+// different goroutines at once may be delivered in any order.
+//
+// Setting a value equal to the one already there is not a change, so
+// reloading the same configuration notifies nobody.
+type ObservableMap struct {
+	mutex         sync.RWMutex
+	data          Map
+	subscriptions []*Subscription
+}
+
+// NewObservableMap creates an ObservableMap holding a copy of the map.
+func NewObservableMap(m Map) *ObservableMap {
+	return &ObservableMap{data: m.DeepCopy()}
+}
+
+// Subscribe calls the handler whenever a value matching the keypath pattern
+// changes.  The pattern is a keypath that may contain wildcards:
+//
+//     "db"            the db value, or anything inside it
+//     "db.*.host"     the host of any value in db
+//     "servers[*]"    any item in servers
+//     "**.password"   any password, however deep
+//     ""              everything
+//
+// Handlers are also called when a map or slice that could contain a match
+// changes, so a subscription to "db.host" hears about Set("db", ...) and
+// Delete("db").  The changes are given to the handler as they were made; use
+// Get to find the value at a more specific keypath.
+func (o *ObservableMap) Subscribe(pattern string, handler ChangeHandler) *Subscription {
+
+	subscription := &Subscription{observable: o, pattern: pattern, glob: parseKeypathGlob(pattern), handler: handler}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.subscriptions = append(o.subscriptions, subscription)
+
+	return subscription
+}
+
+// notify calls the handlers subscribed to any of the changes.
+func (o *ObservableMap) notify(changes []*Change) {
+
+	if len(changes) == 0 {
+		return
+	}
+
+	o.mutex.RLock()
+	subscriptions := o.subscriptions
+	o.mutex.RUnlock()
+
+	for _, subscription := range subscriptions {
+
+		var matched []*Change
+		for _, change := range changes {
+			if subscription.matches(change) {
+				matched = append(matched, change)
+			}
+		}
+
+		if len(matched) > 0 {
+			subscription.handler(matched)
+		}
+
+	}
+
+}
+
+// Get gets a copy of the value at the keypath, or nil if there isn't one.
+func (o *ObservableMap) Get(keypath string) interface{} {
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return deepCopyValue(o.data.Get(keypath))
+}
+
+// Has gets whether there is a value at the keypath.
+func (o *ObservableMap) Has(keypath string) bool {
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return o.data.Has(keypath)
+}
+
+// Snapshot gets a copy of the whole map as it is at one moment.
+func (o *ObservableMap) Snapshot() Map {
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return o.data.DeepCopy()
+}
+
+// write runs the function with the write lock held, making sure there is a
+// map to write to.  The lock is released even if the function panics.
+func (o *ObservableMap) write(f func()) {
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.data == nil {
+		o.data = make(Map)
+	}
+
+	f()
+
+}
+
+// change makes changes with the write lock held, then notifies subscribers.
+func (o *ObservableMap) change(f func(tx *Transaction)) {
+
+	var tx *Transaction
+	o.write(func() {
+		tx = &Transaction{data: o.data}
+		f(tx)
+	})
+
+	o.notify(tx.changes)
+
+}
+
+// Set sets a copy of the value at the keypath, creating maps and slices
+// along the way as Map.Set does, and returns the ObservableMap.
+func (o *ObservableMap) Set(keypath string, value interface{}) *ObservableMap {
+
+	o.change(func(tx *Transaction) {
+		tx.Set(keypath, value)
+	})
+
+	// chain
+	return o
+}
+
+// Delete removes the value at the keypath, and returns the ObservableMap.
+func (o *ObservableMap) Delete(keypath string) *ObservableMap {
+
+	o.change(func(tx *Transaction) {
+		tx.Delete(keypath)
+	})
+
+	// chain
+	return o
+}
+
+// MergeHere blends a copy of the specified map into this one, as
+// Map.MergeHere does, and returns the ObservableMap.  Each key that
+// changes is a separate Change, but they are delivered together.
+func (o *ObservableMap) MergeHere(merge Map) *ObservableMap {
+
+	o.change(func(tx *Transaction) {
+		tx.MergeHere(merge)
+	})
+
+	// chain
+	return o
+}
+
+// Transaction makes several changes to the map at once.  Subscribers hear
+// about all of the changes together, once the function has returned.
+//
+// For example:
+//
+//     err := config.Transaction(func(tx *objects.Transaction) error {
+//         tx.Set("db.host", "db2").Set("db.port", 5433)
+//         return nil
+//     })
+//
+// If the function returns an error, none of its changes are kept, nobody is
+// notified and the error is returned.  No other changes can be made while
+// the function runs, so it must use tx rather than the ObservableMap.
+func (o *ObservableMap) Transaction(f func(tx *Transaction) error) error {
+
+	var tx *Transaction
+	var err error
+	o.write(func() {
+		// work on a copy, so nothing needs undoing if f fails or panics
+		tx = &Transaction{data: o.data.DeepCopy()}
+		if err = f(tx); err == nil {
+			o.data = tx.data
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	o.notify(tx.changes)
+
+	return nil
+}
+
+// Transaction changes an ObservableMap, recording the changes made.
+type Transaction struct {
+	data    Map
+	changes []*Change
+}
+
+// Get gets a copy of the value at the keypath, including changes made in
+// the transaction so far.
+func (tx *Transaction) Get(keypath string) interface{} {
+	return deepCopyValue(tx.data.Get(keypath))
+}
+
+// Has gets whether there is a value at the keypath.
+func (tx *Transaction) Has(keypath string) bool {
+	return tx.data.Has(keypath)
+}
+
+// Set sets a copy of the value at the keypath, and returns the Transaction.
+func (tx *Transaction) Set(keypath string, value interface{}) *Transaction {
+
+	segs := parseKeypath(keypath)
+	if len(segs) == 0 || segs[0].isIndex {
+		return tx
+	}
+
+	old, existed := getPath(tx.data, segs)
+	if existed && valuesEqual(old, value) {
+		return tx
+	}
+
+	tx.data.Set(keypath, deepCopyValue(value))
+	tx.changes = append(tx.changes, &Change{Keypath: keypath, Op: ChangeSet, Old: old, New: deepCopyValue(value)})
+
+	// chain
+	return tx
+}
+
+// Delete removes the value at the keypath, and returns the Transaction.
+func (tx *Transaction) Delete(keypath string) *Transaction {
+
+	if old, ok := tx.data.Pop(keypath); ok {
+		tx.changes = append(tx.changes, &Change{Keypath: keypath, Op: ChangeDelete, Old: old})
+	}
+
+	// chain
+	return tx
+}
+
+// MergeHere blends a copy of the specified map into this one, and returns
+// the Transaction.
+func (tx *Transaction) MergeHere(merge Map) *Transaction {
+
+	for _, k := range sortedKeys(merge) {
+		tx.Set(EscapeKey(k), merge[k])
+	}
+
+	// chain
+	return tx
+}
@@ -0,0 +1,270 @@
+package objects
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+// changeRecorder collects the batches of changes given to a handler.
+type changeRecorder struct {
+	mutex   sync.Mutex
+	batches [][]*Change
+}
+
+func (r *changeRecorder) handle(changes []*Change) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.batches = append(r.batches, changes)
+}
+
+// keypaths gets the keypaths of the changes in each batch.
+func (r *changeRecorder) keypaths() [][]string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var keypaths [][]string
+	for _, batch := range r.batches {
+		var paths []string
+		for _, change := range batch {
+			paths = append(paths, change.Op.String()+" "+change.Keypath)
+		}
+		keypaths = append(keypaths, paths)
+	}
+	return keypaths
+}
+
+func TestObservableMap(t *testing.T) {
+
+	o := NewObservableMap(M("db", M("host", "localhost", "port", 5432)))
+
+	all := new(changeRecorder)
+	o.Subscribe("", all.handle)
+
+	var changes []*Change
+	o.Subscribe("db.host", func(c []*Change) {
+		changes = append(changes, c...)
+	})
+
+	o.Set("db.host", "db1")
+	if assert.Equal(t, 1, len(changes)) {
+		assert.Equal(t, &Change{Keypath: "db.host", Op: ChangeSet, Old: "localhost", New: "db1"}, changes[0])
+	}
+
+	// the same value again is not a change
+	o.Set("db.host", "db1")
+	assert.Equal(t, 1, len(changes))
+
+	o.Delete("db.port")
+	o.Delete("db.missing")
+	assert.Equal(t, 1, len(changes))
+
+	// changes above a subscription are delivered too
+	o.Set("db", M("host", "db2"))
+	if assert.Equal(t, 2, len(changes)) {
+		assert.Equal(t, "db", changes[1].Keypath)
+		assert.Equal(t, M("host", "db1"), changes[1].Old)
+		assert.Equal(t, M("host", "db2"), changes[1].New)
+	}
+
+	o.Delete("db")
+	if assert.Equal(t, 3, len(changes)) {
+		assert.Equal(t, ChangeDelete, changes[2].Op)
+		assert.Nil(t, changes[2].New)
+	}
+
+	assert.Equal(t, [][]string{{"set db.host"}, {"delete db.port"}, {"set db"}, {"delete db"}}, all.keypaths())
+	assert.Equal(t, M(), o.Snapshot())
+
+}
+
+func TestObservableMap_MergeHere(t *testing.T) {
+
+	o := NewObservableMap(M("name", "stew", "version", 1))
+
+	r := new(changeRecorder)
+	o.Subscribe("", r.handle)
+
+	o.MergeHere(M("version", 2, "name", "stew", "odd.key", true))
+
+	assert.Equal(t, [][]string{{`set odd\.key`, "set version"}}, r.keypaths())
+	assert.Equal(t, M("name", "stew", "version", 2, "odd.key", true), o.Snapshot())
+
+}
+
+func TestObservableMap_Patterns(t *testing.T) {
+
+	o := NewObservableMap(nil)
+
+	recorders := make(map[string]*changeRecorder)
+	for _, pattern := range []string{"db", "db.*.host", "servers[*]", "servers[1].name", "**.password", "*name", "a.**.z"} {
+		recorders[pattern] = new(changeRecorder)
+		o.Subscribe(pattern, recorders[pattern].handle)
+	}
+
+	o.Set("db.primary.host", "a")
+	o.Set("db.primary.port", 1)
+	o.Set("dbx", true)
+	o.Set("servers[0].name", "one")
+	o.Set("servers[1].password", "secret")
+	o.Set("users.admin.password", "secret")
+	o.Set("password", "secret")
+	o.Set("username", "mat")
+	o.Set("a.b.c.z", 1)
+	o.Set("a.z", 1)
+	o.Set("a.b.y", 1)
+
+	assert.Equal(t, [][]string{{"set db.primary.host"}, {"set db.primary.port"}}, recorders["db"].keypaths())
+	assert.Equal(t, [][]string{{"set db.primary.host"}}, recorders["db.*.host"].keypaths())
+	assert.Equal(t, [][]string{{"set servers[0].name"}, {"set servers[1].password"}}, recorders["servers[*]"].keypaths())
+	assert.Nil(t, recorders["servers[1].name"].keypaths())
+	assert.Equal(t, [][]string{{"set servers[1].password"}, {"set users.admin.password"}, {"set password"}}, recorders["**.password"].keypaths())
+	assert.Equal(t, [][]string{{"set username"}}, recorders["*name"].keypaths())
+	assert.Equal(t, [][]string{{"set a.b.c.z"}, {"set a.z"}}, recorders["a.**.z"].keypaths())
+
+}
+
+func TestObservableMap_Transaction(t *testing.T) {
+
+	o := NewObservableMap(M("db", M("host", "localhost")))
+
+	all := new(changeRecorder)
+	db := new(changeRecorder)
+	o.Subscribe("", all.handle)
+	o.Subscribe("db", db.handle)
+
+	err := o.Transaction(func(tx *Transaction) error {
+		tx.Set("db.host", "db1").Set("db.port", 5433).Set("debug", true)
+		assert.Equal(t, 5433, tx.Get("db.port"))
+		assert.True(t, tx.Has("debug"))
+		tx.Delete("debug")
+		return nil
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, [][]string{{"set db.host", "set db.port", "set debug", "delete debug"}}, all.keypaths())
+		assert.Equal(t, [][]string{{"set db.host", "set db.port"}}, db.keypaths())
+		assert.Equal(t, M("db", M("host", "db1", "port", 5433)), o.Snapshot())
+	}
+
+	// failed transactions change nothing
+	failure := errors.New("failed")
+	err = o.Transaction(func(tx *Transaction) error {
+		tx.Set("db.host", "db2").Delete("db.port")
+		return failure
+	})
+
+	assert.Equal(t, failure, err)
+	assert.Equal(t, M("db", M("host", "db1", "port", 5433)), o.Snapshot())
+	assert.Equal(t, 1, len(all.keypaths()))
+
+	// transactions with no changes notify nobody
+	o.Transaction(func(tx *Transaction) error {
+		tx.Set("db.host", "db1")
+		return nil
+	})
+	assert.Equal(t, 1, len(all.keypaths()))
+
+	// a panicking transaction changes nothing and doesn't leave the map locked
+	assert.Panics(t, func() {
+		o.Transaction(func(tx *Transaction) error {
+			tx.Set("db.host", "db3")
+			panic("failed")
+		})
+	})
+	assert.Equal(t, "db1", o.Get("db.host"))
+	o.Set("db.host", "db4")
+	assert.Equal(t, "db4", o.Get("db.host"))
+
+}
+
+func TestObservableMap_Unsubscribe(t *testing.T) {
+
+	o := NewObservableMap(nil)
+
+	r := new(changeRecorder)
+	subscription := o.Subscribe("a", r.handle)
+	assert.Equal(t, "a", subscription.Pattern())
+
+	// handlers may use the map
+	o.Subscribe("a", func(changes []*Change) {
+		o.Set("b", o.Get("a"))
+	})
+
+	o.Set("a", 1)
+	subscription.Unsubscribe()
+	o.Set("a", 2)
+
+	assert.Equal(t, [][]string{{"set a"}}, r.keypaths())
+	assert.Equal(t, 2, o.Get("b"))
+
+}
+
+func TestObservableMap_Concurrent(t *testing.T) {
+
+	o := NewObservableMap(nil)
+
+	r := new(changeRecorder)
+	o.Subscribe("counts.*", r.handle)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				o.Transaction(func(tx *Transaction) error {
+					n, _ := tx.Get("counts.n").(int)
+					tx.Set("counts.n", n+1)
+					return nil
+				})
+				o.Get("counts")
+				o.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 800, o.Get("counts.n"))
+	assert.Equal(t, 800, len(r.keypaths()))
+
+}
+
+func TestKeypathGlob(t *testing.T) {
+
+	matches := map[string][]string{
+		"":                 {"a", "a.b", "a[0]"},
+		"a":                {"a"},
+		"a.*":              {"a.b", "a.c"},
+		"a[*]":             {"a[0]", "a[5]"},
+		"a[*][*]":          {"a[0][1]"},
+		"a[1].b":           {"a[1].b"},
+		"**":               {"a", "a.b.c"},
+		"**.password":      {"password", "a.password", "a[0].b.password"},
+		"a.**":             {"a", "a.b", "a[0].c"},
+		"*_token":          {"api_token"},
+		"pass*word":        {"password", "pass_the_word", "pass_word"},
+		`files.readme\.md`: {`files.readme\.md`},
+	}
+	misses := map[string][]string{
+		"a":           {"a.b", "b", "ab"},
+		"a.*":         {"a", "a[0]", "a.b.c"},
+		"a[*]":        {"a.b", "a"},
+		"a[1].b":      {"a[0].b"},
+		"**.password": {"password.x", "passwords"},
+		"*_token":     {"token", "a.api_token"},
+		"pass*word":   {"passwor"},
+	}
+
+	for pattern, keypaths := range matches {
+		for _, keypath := range keypaths {
+			assert.True(t, parseKeypathGlob(pattern).match(keypath), pattern+" "+keypath)
+		}
+	}
+	for pattern, keypaths := range misses {
+		for _, keypath := range keypaths {
+			assert.False(t, parseKeypathGlob(pattern).match(keypath), pattern+" "+keypath)
+		}
+	}
+
+}
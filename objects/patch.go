@@ -0,0 +1,416 @@
+package objects
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// JSON Patch operations, as defined by RFC 6902.
+const (
+	PatchAdd     string = "add"
+	PatchRemove  string = "remove"
+	PatchReplace string = "replace"
+	PatchMove    string = "move"
+	PatchCopy    string = "copy"
+	PatchTest    string = "test"
+)
+
+// ErrPatchTestFailed is the error inside a *PatchError when a "test" operation
+// finds a different value.
+var ErrPatchTestFailed = errors.New("Map: Patch test failed.")
+
+// PatchOperation is a single operation in a JSON Patch (RFC 6902).  Path and
+// From are JSON Pointers (RFC 6901).
+type PatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// Patch is a JSON Patch document (RFC 6902); a list of operations applied in order.
+type Patch []PatchOperation
+
+// PatchError is returned when a Patch cannot be applied.
+type PatchError struct {
+	// Index is the position of the operation that failed.
+	Index int
+	// Operation is the operation that failed.
+	Operation PatchOperation
+	// Err is the reason it failed.
+	Err error
+}
+
+// Error gets the error message.
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("Map: Patch operation %d (%s %s) failed: %s", e.Index, e.Operation.Op, e.Operation.Path, e.Err)
+}
+
+// Unwrap gets the reason the operation failed.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON encodes the operation, including only the members its Op uses.
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+
+	encoded := map[string]interface{}{"op": o.Op, "path": o.Path}
+
+	switch o.Op {
+	case PatchAdd, PatchReplace, PatchTest:
+		encoded["value"] = o.Value
+	case PatchMove, PatchCopy:
+		encoded["from"] = o.From
+	}
+
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes the operation, checking that the members its Op
+// needs are present.
+func (o *PatchOperation) UnmarshalJSON(data []byte) error {
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	op, _ := decoded["op"].(string)
+	path, hasPath := decoded["path"].(string)
+	if !hasPath {
+		return errors.New("Map: Patch operation is missing 'path'.")
+	}
+
+	switch op {
+	case PatchAdd, PatchReplace, PatchTest:
+		value, hasValue := decoded["value"]
+		if !hasValue {
+			return errors.New("Map: Patch operation '" + op + "' is missing 'value'.")
+		}
+		*o = PatchOperation{Op: op, Path: path, Value: normalizeJSON(value)}
+	case PatchMove, PatchCopy:
+		from, hasFrom := decoded["from"].(string)
+		if !hasFrom {
+			return errors.New("Map: Patch operation '" + op + "' is missing 'from'.")
+		}
+		*o = PatchOperation{Op: op, Path: path, From: from}
+	case PatchRemove:
+		*o = PatchOperation{Op: op, Path: path}
+	default:
+		return errors.New("Map: Unknown patch operation '" + op + "'.")
+	}
+
+	return nil
+}
+
+// NewPatchFromJSON creates a Patch from a JSON Patch document.
+func NewPatchFromJSON(data string) (Patch, error) {
+
+	var patch Patch
+
+	if err := json.Unmarshal([]byte(data), &patch); err != nil {
+		return nil, errors.New("Map: JSON Patch decode failed with: " + err.Error())
+	}
+
+	return patch, nil
+}
+
+// JSON converts the patch to a JSON Patch document.
+func (p Patch) JSON() (string, error) {
+
+	if p == nil {
+		p = Patch{}
+	}
+
+	result, err := json.Marshal(p)
+	if err != nil {
+		err = errors.New("Map: JSON encode failed with: " + err.Error())
+	}
+
+	return string(result), err
+}
+
+// ApplyPatch applies the JSON Patch (RFC 6902) to the map.
+//
+// The patch is applied atomically; if any operation fails (including a "test"),
+// a *PatchError is returned and the map is left untouched.
+func (d Map) ApplyPatch(patch Patch) error {
+
+	var doc interface{} = d.DeepCopy()
+
+	for i, operation := range patch {
+
+		var err error
+		if doc, err = applyPatchOperation(doc, operation); err != nil {
+			return &PatchError{Index: i, Operation: operation, Err: err}
+		}
+
+	}
+
+	patched, ok := asMap(doc)
+	if !ok {
+		return &PatchError{Index: len(patch) - 1, Operation: patch[len(patch)-1], Err: errors.New("Map: The patched document is not an object.")}
+	}
+
+	for k := range d {
+		delete(d, k)
+	}
+	for k, v := range patched {
+		d[k] = v
+	}
+
+	return nil
+}
+
+// applyPatchOperation applies a single operation to the document, returning
+// the new document.
+func applyPatchOperation(doc interface{}, operation PatchOperation) (interface{}, error) {
+
+	tokens, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case PatchAdd:
+		return addPointer(doc, tokens, deepCopyValue(operation.Value))
+	case PatchRemove:
+		doc, _, err = removePointer(doc, tokens)
+		return doc, err
+	case PatchReplace:
+		return replacePointer(doc, tokens, deepCopyValue(operation.Value))
+	case PatchTest:
+		value, err := getPointer(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !valuesEqual(value, operation.Value) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+	case PatchMove, PatchCopy:
+		fromTokens, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if operation.Op == PatchMove {
+			if operation.Path == operation.From {
+				_, err = getPointer(doc, fromTokens)
+				return doc, err
+			}
+			if strings.HasPrefix(operation.Path, operation.From+pointerSeparator) {
+				return nil, errors.New("Map: Cannot move a value into one of its own children.")
+			}
+			if doc, value, err = removePointer(doc, fromTokens); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = getPointer(doc, fromTokens); err != nil {
+				return nil, err
+			}
+			value = deepCopyValue(value)
+		}
+		return addPointer(doc, tokens, value)
+	}
+
+	return nil, errors.New("Map: Unknown patch operation '" + operation.Op + "'.")
+}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7396) to the map and returns
+// the current map.
+//
+// Objects in the patch are merged recursively, null values remove keys and
+// everything else (including arrays) replaces the existing value.
+func (d Map) ApplyMergePatch(patch Map) Map {
+
+	for k, v := range patch {
+
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+
+		patchMap, ok := asMap(v)
+		if !ok {
+			d[k] = deepCopyValue(v)
+			continue
+		}
+
+		target, ok := asMap(d[k])
+		if !ok {
+			target = make(Map)
+		}
+		d[k] = target.ApplyMergePatch(patchMap)
+
+	}
+
+	return d
+}
+
+// Diff compares two maps and generates both a JSON Patch (RFC 6902) and a
+// JSON Merge Patch (RFC 7396) that turn a into b.
+//
+// Nested objects are compared key by key, but arrays that differ are replaced
+// whole.  Since merge patches use null to remove keys, keys in b whose value is
+// nil cannot be expressed by the merge patch.
+func Diff(a, b Map) (Patch, Map) {
+	patch := Patch{}
+	return diffMaps(nil, a, b, patch), mergeDiffMaps(a, b)
+}
+
+// sortedKeys gets the keys of the map in order.
+func sortedKeys(m Map) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffMaps adds the operations that turn a into b to the patch.
+func diffMaps(tokens []string, a, b Map, patch Patch) Patch {
+
+	for _, k := range sortedKeys(a) {
+		if _, exists := b[k]; !exists {
+			patch = append(patch, PatchOperation{Op: PatchRemove, Path: formatPointer(append(tokens, k))})
+		}
+	}
+
+	for _, k := range sortedKeys(b) {
+
+		childTokens := append(tokens[:len(tokens):len(tokens)], k)
+
+		aValue, exists := a[k]
+		if !exists {
+			patch = append(patch, PatchOperation{Op: PatchAdd, Path: formatPointer(childTokens), Value: deepCopyValue(b[k])})
+			continue
+		}
+
+		aMap, aIsMap := asMap(aValue)
+		bMap, bIsMap := asMap(b[k])
+		if aIsMap && bIsMap {
+			patch = diffMaps(childTokens, aMap, bMap, patch)
+			continue
+		}
+
+		if !valuesEqual(aValue, b[k]) {
+			patch = append(patch, PatchOperation{Op: PatchReplace, Path: formatPointer(childTokens), Value: deepCopyValue(b[k])})
+		}
+
+	}
+
+	return patch
+}
+
+// mergeDiffMaps makes the merge patch that turns a into b.
+func mergeDiffMaps(a, b Map) Map {
+
+	patch := make(Map)
+
+	for k := range a {
+		if _, exists := b[k]; !exists {
+			patch[k] = nil
+		}
+	}
+
+	for k, bValue := range b {
+
+		aValue, exists := a[k]
+		if !exists {
+			patch[k] = deepCopyValue(bValue)
+			continue
+		}
+
+		aMap, aIsMap := asMap(aValue)
+		bMap, bIsMap := asMap(bValue)
+		if aIsMap && bIsMap {
+			if child := mergeDiffMaps(aMap, bMap); len(child) > 0 {
+				patch[k] = child
+			}
+			continue
+		}
+
+		if !valuesEqual(aValue, bValue) {
+			patch[k] = deepCopyValue(bValue)
+		}
+
+	}
+
+	return patch
+}
+
+// normalizeJSON turns any map[string]interface{} values decoded from JSON
+// into Map values, all the way down.
+func normalizeJSON(value interface{}) interface{} {
+
+	switch value.(type) {
+	case map[string]interface{}:
+		m := Map(value.(map[string]interface{}))
+		for k, v := range m {
+			m[k] = normalizeJSON(v)
+		}
+		return m
+	case []interface{}:
+		items := value.([]interface{})
+		for i, item := range items {
+			items[i] = normalizeJSON(item)
+		}
+		return items
+	}
+
+	return value
+}
+
+// valuesEqual compares two values the way JSON would; maps and slices of any
+// supported type are compared item by item, and numbers of different types
+// are equal if they have the same value.
+func valuesEqual(a, b interface{}) bool {
+
+	if aMap, ok := asMap(a); ok {
+		bMap, ok := asMap(b)
+		if !ok || len(aMap) != len(bMap) {
+			return false
+		}
+		for k, aValue := range aMap {
+			bValue, exists := bMap[k]
+			if !exists || !valuesEqual(aValue, bValue) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if aLen, ok := sliceLen(a); ok {
+		bLen, ok := sliceLen(b)
+		if !ok || aLen != bLen {
+			return false
+		}
+		for i := 0; i < aLen; i++ {
+			if !valuesEqual(sliceItem(a, i), sliceItem(b, i)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if aNumber, ok := numberValue(a); ok {
+		bNumber, ok := numberValue(b)
+		return ok && aNumber == bNumber
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// numberValue gets the value of any numeric type as a float64.
+func numberValue(value interface{}) (float64, bool) {
+	if _, isString := value.(string); isString {
+		return 0, false
+	}
+	return toFloat64(value)
+}
@@ -0,0 +1,200 @@
+package objects
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewPatchFromJSON(t *testing.T) {
+
+	patch, err := NewPatchFromJSON(`[
+		{"op": "add", "path": "/a", "value": {"b": null}},
+		{"op": "remove", "path": "/c"},
+		{"op": "move", "from": "/d", "path": "/e"}
+	]`)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, Patch{
+			{Op: PatchAdd, Path: "/a", Value: Map{"b": nil}},
+			{Op: PatchRemove, Path: "/c"},
+			{Op: PatchMove, Path: "/e", From: "/d"},
+		}, patch)
+	}
+
+	_, err = NewPatchFromJSON(`[{"op": "add", "path": "/a"}]`)
+	assert.Error(t, err, "add needs a value")
+
+	_, err = NewPatchFromJSON(`[{"op": "copy", "path": "/a"}]`)
+	assert.Error(t, err, "copy needs from")
+
+	_, err = NewPatchFromJSON(`[{"op": "explode", "path": "/a"}]`)
+	assert.Error(t, err)
+
+}
+
+func TestPatchJSON(t *testing.T) {
+
+	patch := Patch{
+		{Op: PatchAdd, Path: "/a", Value: nil},
+		{Op: PatchCopy, Path: "/b", From: "/a"},
+		{Op: PatchRemove, Path: "/a"},
+	}
+
+	json, err := patch.JSON()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `[{"op":"add","path":"/a","value":null},{"from":"/a","op":"copy","path":"/b"},{"op":"remove","path":"/a"}]`, json)
+	}
+
+	json, err = Patch(nil).JSON()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `[]`, json)
+	}
+
+}
+
+func TestApplyPatch(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"name":"Mat","tags":["a","c"],"address":{"city":"Boulder"},"a/b":{"m~n":1}}`)
+
+	patch, _ := NewPatchFromJSON(`[
+		{"op": "test", "path": "/name", "value": "Mat"},
+		{"op": "add", "path": "/tags/1", "value": "b"},
+		{"op": "add", "path": "/tags/-", "value": "d"},
+		{"op": "replace", "path": "/address/city", "value": "Salt Lake City"},
+		{"op": "remove", "path": "/name"},
+		{"op": "copy", "from": "/address", "path": "/work"},
+		{"op": "move", "from": "/a~1b/m~0n", "path": "/count"},
+		{"op": "test", "path": "/count", "value": 1}
+	]`)
+
+	if assert.NoError(t, m.ApplyPatch(patch)) {
+		assert.Nil(t, m["name"])
+		assert.Equal(t, []interface{}{"a", "b", "c", "d"}, m["tags"])
+		assert.Equal(t, "Salt Lake City", m.Get("address.city"))
+		assert.Equal(t, "Salt Lake City", m.Get("work.city"))
+		assert.Equal(t, float64(1), m["count"])
+		assert.Equal(t, map[string]interface{}{}, m["a/b"])
+	}
+
+	// copies are not shared
+	m.Set("work.city", "Denver")
+	assert.Equal(t, "Salt Lake City", m.Get("address.city"))
+
+}
+
+func TestApplyPatch_Atomic(t *testing.T) {
+
+	m := Map{"name": "Mat", "tags": []interface{}{"a"}}
+
+	err := m.ApplyPatch(Patch{
+		{Op: PatchReplace, Path: "/name", Value: "Tyler"},
+		{Op: PatchAdd, Path: "/tags/0", Value: "z"},
+		{Op: PatchTest, Path: "/name", Value: "Mat"},
+	})
+
+	if assert.Error(t, err) {
+		patchErr, ok := err.(*PatchError)
+		if assert.True(t, ok) {
+			assert.Equal(t, 2, patchErr.Index)
+			assert.Equal(t, PatchTest, patchErr.Operation.Op)
+			assert.True(t, errors.Is(err, ErrPatchTestFailed))
+		}
+	}
+
+	assert.Equal(t, Map{"name": "Mat", "tags": []interface{}{"a"}}, m)
+
+}
+
+func TestApplyPatch_Errors(t *testing.T) {
+
+	m := Map{"name": "Mat", "tags": []interface{}{"a"}, "address": Map{"city": "Boulder"}}
+
+	for _, operation := range []PatchOperation{
+		{Op: PatchRemove, Path: "/nope"},
+		{Op: PatchReplace, Path: "/nope", Value: 1},
+		{Op: PatchAdd, Path: "/nope/child", Value: 1},
+		{Op: PatchAdd, Path: "/tags/2", Value: 1},
+		{Op: PatchAdd, Path: "/tags/01", Value: 1},
+		{Op: PatchRemove, Path: "/tags/-"},
+		{Op: PatchAdd, Path: "/name/child", Value: 1},
+		{Op: PatchAdd, Path: "name", Value: 1},
+		{Op: PatchRemove, Path: ""},
+		{Op: PatchMove, From: "/address", Path: "/address/city"},
+		{Op: PatchTest, Path: "/nope", Value: nil},
+		{Op: PatchAdd, Path: "", Value: "not an object"},
+		{Op: "explode", Path: "/name"},
+	} {
+		assert.Error(t, m.ApplyPatch(Patch{operation}), operation.Op+" "+operation.Path)
+	}
+
+	assert.Equal(t, Map{"name": "Mat", "tags": []interface{}{"a"}, "address": Map{"city": "Boulder"}}, m)
+
+}
+
+func TestApplyPatch_Test(t *testing.T) {
+
+	m := Map{"count": 1, "list": []interface{}{1, "a"}, "obj": map[string]interface{}{"a": int64(2)}}
+
+	assert.NoError(t, m.ApplyPatch(Patch{
+		{Op: PatchTest, Path: "/count", Value: float64(1)},
+		{Op: PatchTest, Path: "/list", Value: []interface{}{1.0, "a"}},
+		{Op: PatchTest, Path: "/obj", Value: Map{"a": 2}},
+		{Op: PatchTest, Path: "", Value: m.DeepCopy()},
+	}))
+
+	assert.Error(t, m.ApplyPatch(Patch{{Op: PatchTest, Path: "/count", Value: "1"}}))
+	assert.Error(t, m.ApplyPatch(Patch{{Op: PatchTest, Path: "/list", Value: []interface{}{1}}}))
+
+}
+
+func TestApplyMergePatch(t *testing.T) {
+
+	// example from RFC 7396
+	m, _ := NewMapFromJSON(`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`)
+	patch, _ := NewMapFromJSON(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"],"new":{"a":null,"b":1}}`)
+
+	assert.Equal(t, m, m.ApplyMergePatch(patch))
+
+	expected, _ := NewMapFromJSON(`{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890","new":{"b":1}}`)
+	assert.True(t, valuesEqual(expected, m), "%v", m)
+
+}
+
+func TestDiff(t *testing.T) {
+
+	a, _ := NewMapFromJSON(`{"name":"Mat","age":29,"tags":["a"],"address":{"city":"Boulder","state":"CO"},"old":true}`)
+	b, _ := NewMapFromJSON(`{"name":"Mat","age":30,"tags":["a","b"],"address":{"city":"Boulder","zip":"80301"},"new/key":1}`)
+
+	patch, mergePatch := Diff(a, b)
+
+	assert.Equal(t, Patch{
+		{Op: PatchRemove, Path: "/old"},
+		{Op: PatchRemove, Path: "/address/state"},
+		{Op: PatchAdd, Path: "/address/zip", Value: "80301"},
+		{Op: PatchReplace, Path: "/age", Value: float64(30)},
+		{Op: PatchAdd, Path: "/new~1key", Value: float64(1)},
+		{Op: PatchReplace, Path: "/tags", Value: []interface{}{"a", "b"}},
+	}, patch)
+
+	assert.Equal(t, Map{
+		"old":     nil,
+		"address": Map{"state": nil, "zip": "80301"},
+		"age":     float64(30),
+		"new/key": float64(1),
+		"tags":    []interface{}{"a", "b"},
+	}, mergePatch)
+
+	// both patches turn a into b
+	patched := a.DeepCopy()
+	if assert.NoError(t, patched.ApplyPatch(patch)) {
+		assert.True(t, valuesEqual(b, patched))
+	}
+	assert.True(t, valuesEqual(b, a.DeepCopy().ApplyMergePatch(mergePatch)))
+
+	// numbers of different types are the same
+	patch, mergePatch = Diff(Map{"n": 1}, Map{"n": 1.0})
+	assert.Empty(t, patch)
+	assert.Empty(t, mergePatch)
+
+}
@@ -0,0 +1,390 @@
+package objects
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	pointerSeparator string = "/"
+	pointerAppend    string = "-"
+
+	// maxPointerIndex is the length used when any index is acceptable.
+	maxPointerIndex int = int(^uint(0) >> 1)
+)
+
+var pointerTokenEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+var pointerTokenUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer breaks an RFC 6901 JSON Pointer into its unescaped
+// reference tokens.  The empty pointer refers to the whole document
+// and has no tokens.
+func parsePointer(pointer string) ([]string, error) {
+
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, pointerSeparator) {
+		return nil, errors.New("Map: JSON Pointer '" + pointer + "' must start with '/'.")
+	}
+
+	tokens := strings.Split(pointer[len(pointerSeparator):], pointerSeparator)
+	for i, token := range tokens {
+		tokens[i] = pointerTokenUnescaper.Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// formatPointer builds an RFC 6901 JSON Pointer from unescaped reference tokens.
+func formatPointer(tokens []string) string {
+
+	var pointer []byte
+	for _, token := range tokens {
+		pointer = append(pointer, pointerSeparator...)
+		pointer = append(pointer, pointerTokenEscaper.Replace(token)...)
+	}
+
+	return string(pointer)
+}
+
+// pointerIndex parses a reference token as an index into a slice of the
+// given length.  RFC 6901 indexes have no sign or leading zeros.
+func pointerIndex(token string, length int) (int, error) {
+
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.IndexFunc(token, func(r rune) bool { return r < '0' || r > '9' }) != -1 {
+		return 0, errors.New("Map: '" + token + "' is not a valid array index.")
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index >= length {
+		return 0, errors.New("Map: Array index " + token + " is out of range.")
+	}
+
+	return index, nil
+}
+
+// getPointer gets the value referred to by the tokens.
+func getPointer(doc interface{}, tokens []string) (interface{}, error) {
+
+	for _, token := range tokens {
+
+		if m, ok := asMap(doc); ok {
+			value, exists := m[token]
+			if !exists {
+				return nil, errors.New("Map: Key '" + token + "' does not exist.")
+			}
+			doc = value
+			continue
+		}
+
+		if length, ok := sliceLen(doc); ok {
+			index, err := pointerIndex(token, length)
+			if err != nil {
+				return nil, err
+			}
+			doc = sliceItem(doc, index)
+			continue
+		}
+
+		return nil, errors.New("Map: Cannot find '" + token + "' inside a value that is not an object or array.")
+	}
+
+	return doc, nil
+}
+
+// updatePointer walks to the parent of the value referred to by the tokens, and
+// calls update with the parent and the last token.  The container returned by
+// update replaces the parent, so slices may be grown or shrunk.
+//
+// The doc returned is the (possibly new) document.
+func updatePointer(doc interface{}, tokens []string, update func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+
+	if len(tokens) == 1 {
+		return update(doc, tokens[0])
+	}
+
+	token := tokens[0]
+
+	if m, ok := asMap(doc); ok {
+		child, exists := m[token]
+		if !exists {
+			return nil, errors.New("Map: Key '" + token + "' does not exist.")
+		}
+		updated, err := updatePointer(child, tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		m[token] = updated
+		return doc, nil
+	}
+
+	if length, ok := sliceLen(doc); ok {
+		index, err := pointerIndex(token, length)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := updatePointer(sliceItem(doc, index), tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		items := toInterfaces(doc)
+		items[index] = updated
+		return items, nil
+	}
+
+	return nil, errors.New("Map: Cannot find '" + token + "' inside a value that is not an object or array.")
+}
+
+// addPointer adds the value at the tokens, as described by the JSON Patch
+// "add" operation; object members are set, array items are inserted.
+func addPointer(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return updatePointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+
+		if m, ok := asMap(parent); ok {
+			m[token] = value
+			return parent, nil
+		}
+
+		if length, ok := sliceLen(parent); ok {
+			index := length
+			if token != pointerAppend {
+				var err error
+				if index, err = pointerIndex(token, length+1); err != nil {
+					return nil, err
+				}
+			}
+			items := toInterfaces(parent)
+			items = append(items, nil)
+			copy(items[index+1:], items[index:])
+			items[index] = value
+			return items, nil
+		}
+
+		return nil, errors.New("Map: Cannot add '" + token + "' to a value that is not an object or array.")
+	})
+}
+
+// removePointer removes the value at the tokens, returning the new document
+// and the value that was removed.
+func removePointer(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("Map: Cannot remove the whole document.")
+	}
+
+	var removed interface{}
+
+	doc, err := updatePointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+
+		if m, ok := asMap(parent); ok {
+			value, exists := m[token]
+			if !exists {
+				return nil, errors.New("Map: Key '" + token + "' does not exist.")
+			}
+			removed = value
+			delete(m, token)
+			return parent, nil
+		}
+
+		if length, ok := sliceLen(parent); ok {
+			index, err := pointerIndex(token, length)
+			if err != nil {
+				return nil, err
+			}
+			items := toInterfaces(parent)
+			removed = items[index]
+			return append(items[:index], items[index+1:]...), nil
+		}
+
+		return nil, errors.New("Map: Cannot remove '" + token + "' from a value that is not an object or array.")
+	})
+
+	return doc, removed, err
+}
+
+// replacePointer replaces the existing value at the tokens.
+func replacePointer(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return updatePointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+
+		if m, ok := asMap(parent); ok {
+			if _, exists := m[token]; !exists {
+				return nil, errors.New("Map: Key '" + token + "' does not exist.")
+			}
+			m[token] = value
+			return parent, nil
+		}
+
+		if length, ok := sliceLen(parent); ok {
+			index, err := pointerIndex(token, length)
+			if err != nil {
+				return nil, err
+			}
+			items := toInterfaces(parent)
+			items[index] = value
+			return items, nil
+		}
+
+		return nil, errors.New("Map: Cannot replace '" + token + "' in a value that is not an object or array.")
+	})
+}
+
+// isPointerIndex gets whether the reference token is written like an array index.
+func isPointerIndex(token string) bool {
+	_, err := pointerIndex(token, maxPointerIndex)
+	return err == nil
+}
+
+// GetPointer gets the value referred to by the JSON Pointer (RFC 6901).
+//
+// For example:
+//
+//     m.GetPointer("/users/0/name")
+//
+// An error is returned if the pointer is malformed or nothing exists there.
+func (d Map) GetPointer(pointer string) (interface{}, error) {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	return getPointer(d, tokens)
+}
+
+// SetPointer sets the value referred to by the JSON Pointer (RFC 6901).
+//
+// Like Set, new Map objects are created as needed along the way.  As in RFC
+// 6902, an index into an existing slice may be at most its length, which adds
+// an item at the end, as does the token "-".
+//
+// An error is returned if the pointer is malformed, or if it tries to index
+// an existing slice with something other than a number or past its end.
+func (d Map) SetPointer(pointer string, value interface{}) error {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		return errors.New("Map: Cannot set the whole document.")
+	}
+
+	// work out which tokens are indexes from what is already there
+	segs := make([]pathSegment, len(tokens))
+	var current interface{} = d
+	for i, token := range tokens {
+
+		if length, ok := sliceLen(current); ok {
+			index := length
+			if token != pointerAppend {
+				if index, err = pointerIndex(token, length+1); err != nil {
+					return err
+				}
+			}
+			segs[i] = pathSegment{index: index, isIndex: true}
+		} else {
+			segs[i] = pathSegment{key: token}
+		}
+
+		current, _ = getPath(current, segs[i:i+1])
+	}
+
+	d[segs[0].key] = setPath(d[segs[0].key], segs[1:], value)
+
+	return nil
+}
+
+// DeletePointer removes the value referred to by the JSON Pointer (RFC 6901).
+// Items removed from slices shift the later items down.
+//
+// An error is returned if the pointer is malformed or nothing exists there.
+func (d Map) DeletePointer(pointer string) error {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = removePointer(d, tokens)
+
+	return err
+}
+
+// KeypathToPointer converts a keypath into the equivalent JSON Pointer (RFC 6901).
+//
+// For example:
+//
+//     objects.KeypathToPointer(`users[0].files.readme\.md`)
+//     // returns "/users/0/files/readme.md"
+//
+// Negative indexes cannot be expressed as a pointer, and cause an error.
+func KeypathToPointer(keypath string) (string, error) {
+
+	segs := parseKeypath(keypath)
+	tokens := make([]string, len(segs))
+
+	for i, seg := range segs {
+		if !seg.isIndex {
+			tokens[i] = seg.key
+			continue
+		}
+		if seg.index < 0 {
+			return "", errors.New("Map: Keypath '" + keypath + "' has a negative index, which a JSON Pointer cannot express.")
+		}
+		tokens[i] = strconv.Itoa(seg.index)
+	}
+
+	return formatPointer(tokens), nil
+}
+
+// PointerToKeypath converts a JSON Pointer (RFC 6901) into the equivalent keypath.
+//
+// For example:
+//
+//     objects.PointerToKeypath("/users/0/files/readme.md")
+//     // returns `users[0].files.readme\.md`
+//
+// Since a pointer doesn't say whether it refers to an object or an array,
+// tokens that look like array indexes become indexes in the keypath.  The
+// first token is always a key, since a Map can't be indexed.
+func PointerToKeypath(pointer string) (string, error) {
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+
+	var keypath string
+	for i, token := range tokens {
+		switch {
+		case i == 0:
+			keypath = EscapeKey(token)
+		case isPointerIndex(token):
+			keypath = indexKeypath(keypath, mustAtoi(token))
+		default:
+			keypath = keypath + PathSeparator + EscapeKey(token)
+		}
+	}
+
+	return keypath, nil
+}
+
+// mustAtoi parses a token already checked by isPointerIndex.
+func mustAtoi(token string) int {
+	i, _ := strconv.Atoi(token)
+	return i
+}
@@ -0,0 +1,178 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+
+	tokens, err := parsePointer("")
+	if assert.NoError(t, err) {
+		assert.Empty(t, tokens)
+	}
+
+	tokens, err = parsePointer("/a/b~1c/~01/0/")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b/c", "~1", "0", ""}, tokens)
+	}
+
+	_, err = parsePointer("a/b")
+	assert.Error(t, err)
+
+}
+
+func TestFormatPointer(t *testing.T) {
+
+	assert.Equal(t, "", formatPointer(nil))
+	assert.Equal(t, "/a/b~1c/~01/0/", formatPointer([]string{"a", "b/c", "~1", "0", ""}))
+
+}
+
+func TestPointerIndex(t *testing.T) {
+
+	index, err := pointerIndex("2", 3)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, index)
+	}
+
+	for _, token := range []string{"", "3", "01", "-1", "-", "a", "1e2"} {
+		_, err = pointerIndex(token, 3)
+		assert.Error(t, err, token)
+	}
+
+}
+
+func TestGetPointer(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"users":[{"name":"Mat"},{"name":"Tyler"}],"a/b":{"m~n":1},"files":{"readme.md":"hello"}}`)
+
+	value, err := m.GetPointer("/users/1/name")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Tyler", value)
+	}
+
+	value, err = m.GetPointer("/a~1b/m~0n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(1), value)
+	}
+
+	value, err = m.GetPointer("/files/readme.md")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello", value)
+	}
+
+	value, err = m.GetPointer("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, m, value)
+	}
+
+	for _, pointer := range []string{"users", "/nope", "/users/2", "/users/-", "/users/01", "/files/readme.md/x"} {
+		_, err = m.GetPointer(pointer)
+		assert.Error(t, err, pointer)
+	}
+
+}
+
+func TestSetPointer(t *testing.T) {
+
+	m := Map{"tags": []interface{}{"a"}}
+
+	if assert.NoError(t, m.SetPointer("/users/0/name", "Mat")) {
+		assert.Equal(t, "Mat", m.Get("users.0.name"), "new objects are always maps")
+	}
+
+	if assert.NoError(t, m.SetPointer("/tags/-", "b")) {
+		assert.Equal(t, []interface{}{"a", "b"}, m["tags"])
+	}
+
+	if assert.NoError(t, m.SetPointer("/tags/0", "z")) {
+		assert.Equal(t, []interface{}{"z", "b"}, m["tags"])
+	}
+
+	if assert.NoError(t, m.SetPointer("/tags/2", "c")) {
+		assert.Equal(t, []interface{}{"z", "b", "c"}, m["tags"], "the length adds an item")
+	}
+
+	if assert.NoError(t, m.SetPointer("/files/readme.md", "hello")) {
+		assert.Equal(t, "hello", m.Get(`files.readme\.md`))
+	}
+
+	assert.Error(t, m.SetPointer("", Map{}))
+	assert.Error(t, m.SetPointer("tags", 1))
+	assert.Error(t, m.SetPointer("/tags/name", 1))
+	assert.Error(t, m.SetPointer("/tags/4", 1))
+	assert.Error(t, m.SetPointer("/tags/9223372036854775807", 1))
+	assert.Equal(t, []interface{}{"z", "b", "c"}, m["tags"])
+
+}
+
+func TestDeletePointer(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"users":[{"name":"Mat"},{"name":"Tyler"}],"a/b":1}`)
+
+	if assert.NoError(t, m.DeletePointer("/users/0")) {
+		assert.Equal(t, "Tyler", m.Get("users[0].name"))
+		assert.Len(t, m["users"], 1)
+	}
+
+	if assert.NoError(t, m.DeletePointer("/a~1b")) {
+		assert.False(t, m.Has(`a/b`))
+	}
+
+	assert.Error(t, m.DeletePointer("/nope"))
+	assert.Error(t, m.DeletePointer("/users/5"))
+	assert.Error(t, m.DeletePointer(""))
+
+}
+
+func TestKeypathToPointer(t *testing.T) {
+
+	pointer, err := KeypathToPointer(`users[0].files.readme\.md`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/users/0/files/readme.md", pointer)
+	}
+
+	pointer, err = KeypathToPointer("a/b.m~n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/a~1b/m~0n", pointer)
+	}
+
+	_, err = KeypathToPointer("users[-1]")
+	assert.Error(t, err)
+
+}
+
+func TestPointerToKeypath(t *testing.T) {
+
+	keypath, err := PointerToKeypath("/users/0/files/readme.md")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `users[0].files.readme\.md`, keypath)
+	}
+
+	keypath, err = PointerToKeypath("/a~1b/m~0n/01")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a/b.m~n.01", keypath)
+	}
+
+	keypath, err = PointerToKeypath("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", keypath)
+	}
+
+	// the first token is always a key
+	keypath, err = PointerToKeypath("/0/a/1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.a[1]", keypath)
+		assert.Equal(t, "b", M("0", M("a", []interface{}{"a", "b"})).Get(keypath))
+	}
+
+	_, err = PointerToKeypath("users")
+	assert.Error(t, err)
+
+	// round trip
+	m, _ := NewMapFromJSON(`{"users":[{"a.b":{"c[0]":"deep"}}]}`)
+	keypath, _ = PointerToKeypath("/users/0/a.b/c[0]")
+	assert.Equal(t, "deep", m.Get(keypath))
+
+}
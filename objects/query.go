@@ -0,0 +1,884 @@
+package objects
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/stew/numbers"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Query is a compiled JSONPath-style expression that finds values inside
+// a Map.  Queries are safe to use from many goroutines at once.
+//
+// Expressions start with `$`, the map itself, followed by any of:
+//
+//     .name  ['name']  ['a','b']    keys
+//     .*  [*]                        every value in a map or slice
+//     [0]  [-1]  [0,2]  [1:3]  [::2] slice items and ranges
+//     ..name  ..*  ..[0]             recursive descent
+//     [?(@.qty > 2 && @.sku)]        filters
+//
+// Filters keep the values for which the expression is true, where `@` is the
+// value being tested and `$` is the map being queried.  Comparisons are
+// ==, !=, <, <=, > and >= against numbers, 'strings', true, false, null or
+// other paths, and can be combined with &&, || and !.  A path on its own is
+// true if it finds anything.  Numbers of different types compare by value.
+//
+// For example:
+//
+//     q := objects.MustCompileQuery("$.orders[*].items[?(@.qty > 2)].sku")
+//     skus := q.Query(m)
+type Query struct {
+	expression string
+	steps      []queryStep
+}
+
+// maxQueryCacheSize is the most compiled queries kept by CompileQuery.
+const maxQueryCacheSize int = 512
+
+var (
+	queryCache     = make(map[string]*Query)
+	queryCacheLock sync.RWMutex
+)
+
+// CompileQuery compiles the expression into a Query.
+//
+// Compiled queries are cached, so calling CompileQuery again with the
+// same expression is cheap.
+func CompileQuery(expression string) (*Query, error) {
+
+	queryCacheLock.RLock()
+	q, ok := queryCache[expression]
+	queryCacheLock.RUnlock()
+	if ok {
+		return q, nil
+	}
+
+	p := &queryParser{expression: expression}
+
+	if !p.consume("$") {
+		return nil, p.errorf("expected '$'")
+	}
+
+	steps, err := p.parseSteps()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(expression) {
+		return nil, p.errorf("unexpected '%c'", expression[p.pos])
+	}
+
+	q = &Query{expression: expression, steps: steps}
+
+	queryCacheLock.Lock()
+	if len(queryCache) >= maxQueryCacheSize {
+		queryCache = make(map[string]*Query)
+	}
+	queryCache[expression] = q
+	queryCacheLock.Unlock()
+
+	return q, nil
+}
+
+// MustCompileQuery compiles the expression like CompileQuery, but panics if
+// it is invalid.
+func MustCompileQuery(expression string) *Query {
+
+	q, err := CompileQuery(expression)
+	if err != nil {
+		panic(err)
+	}
+
+	return q
+}
+
+// String gets the expression the query was compiled from.
+func (q *Query) String() string {
+	return q.expression
+}
+
+// Query gets every value in the map that the query finds, in order.  The
+// values are not copied.
+func (q *Query) Query(m Map) []interface{} {
+	return evaluateQuery(q.steps, m, m)
+}
+
+// QueryFirst gets the first value in the map that the query finds, and
+// whether anything was found.
+func (q *Query) QueryFirst(m Map) (interface{}, bool) {
+
+	results := q.Query(m)
+	if len(results) == 0 {
+		return nil, false
+	}
+
+	return results[0], true
+}
+
+// Query gets every value in the map found by the expression.  See Query for
+// the syntax.
+//
+// For example:
+//
+//     skus, err := m.Query("$.orders[*].items[?(@.qty > 2)].sku")
+func (d Map) Query(expression string) ([]interface{}, error) {
+
+	q, err := CompileQuery(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Query(d), nil
+}
+
+/*
+	Evaluation
+	------------------------------------------------
+*/
+
+// queryStep is one part of a query.  Recursive steps apply the selector to
+// the value and everything inside it.
+type queryStep struct {
+	recursive bool
+	selector  querySelector
+}
+
+// querySelector picks values from inside a value.
+type querySelector interface {
+	// selectFrom adds the values it picks from value to results.
+	selectFrom(value, root interface{}, results []interface{}) []interface{}
+}
+
+// evaluateQuery runs the steps, starting with the value.
+func evaluateQuery(steps []queryStep, value, root interface{}) []interface{} {
+
+	current := []interface{}{value}
+
+	for _, step := range steps {
+
+		var next []interface{}
+		for _, v := range current {
+			if step.recursive {
+				next = selectRecursive(step.selector, v, root, next)
+			} else {
+				next = step.selector.selectFrom(v, root, next)
+			}
+		}
+
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+
+	return current
+}
+
+// selectRecursive applies the selector to the value, then to everything
+// inside it.
+func selectRecursive(selector querySelector, value, root interface{}, results []interface{}) []interface{} {
+
+	results = selector.selectFrom(value, root, results)
+
+	for _, child := range queryChildren(value) {
+		results = selectRecursive(selector, child, root, results)
+	}
+
+	return results
+}
+
+// queryChildren gets the values inside a map, in key order, or a slice.
+func queryChildren(value interface{}) []interface{} {
+
+	if m, ok := asMap(value); ok {
+		children := make([]interface{}, 0, len(m))
+		for _, k := range sortedKeys(m) {
+			children = append(children, m[k])
+		}
+		return children
+	}
+
+	length, _ := sliceLen(value)
+	children := make([]interface{}, length)
+	for i := range children {
+		children[i] = sliceItem(value, i)
+	}
+
+	return children
+}
+
+// queryNameSelector picks a key from a map.
+type queryNameSelector string
+
+func (s queryNameSelector) selectFrom(value, root interface{}, results []interface{}) []interface{} {
+	if m, ok := asMap(value); ok {
+		if v, exists := m[string(s)]; exists {
+			results = append(results, v)
+		}
+	}
+	return results
+}
+
+// queryWildcardSelector picks every value from a map or slice.
+type queryWildcardSelector struct{}
+
+func (s queryWildcardSelector) selectFrom(value, root interface{}, results []interface{}) []interface{} {
+	return append(results, queryChildren(value)...)
+}
+
+// queryIndexSelector picks an item from a slice.  Negative indexes count
+// back from the end.
+type queryIndexSelector int
+
+func (s queryIndexSelector) selectFrom(value, root interface{}, results []interface{}) []interface{} {
+	if length, ok := sliceLen(value); ok {
+		if index := resolveIndex(int(s), length); index >= 0 && index < length {
+			results = append(results, sliceItem(value, index))
+		}
+	}
+	return results
+}
+
+// querySliceSelector picks a range of items from a slice, like a Python
+// slice.
+type querySliceSelector struct {
+	start, end, step          int
+	hasStart, hasEnd, hasStep bool
+}
+
+func (s querySliceSelector) selectFrom(value, root interface{}, results []interface{}) []interface{} {
+
+	length, ok := sliceLen(value)
+	if !ok {
+		return results
+	}
+
+	step := 1
+	if s.hasStep {
+		step = s.step
+	}
+	if step == 0 {
+		return results
+	}
+
+	bound := func(i, min, max int) int {
+		if i < 0 {
+			i += length
+		}
+		if i < min {
+			return min
+		}
+		if i > max {
+			return max
+		}
+		return i
+	}
+
+	if step > 0 {
+		start, end := 0, length
+		if s.hasStart {
+			start = bound(s.start, 0, length)
+		}
+		if s.hasEnd {
+			end = bound(s.end, 0, length)
+		}
+		for i := start; i < end; i += step {
+			results = append(results, sliceItem(value, i))
+		}
+		return results
+	}
+
+	start, end := length-1, -1
+	if s.hasStart {
+		start = bound(s.start, -1, length-1)
+	}
+	if s.hasEnd {
+		end = bound(s.end, -1, length-1)
+	}
+	for i := start; i > end; i += step {
+		results = append(results, sliceItem(value, i))
+	}
+
+	return results
+}
+
+// queryUnionSelector picks values with each of its selectors in turn.
+type queryUnionSelector []querySelector
+
+func (s queryUnionSelector) selectFrom(value, root interface{}, results []interface{}) []interface{} {
+	for _, selector := range s {
+		results = selector.selectFrom(value, root, results)
+	}
+	return results
+}
+
+// queryFilterSelector picks the values from a map or slice for which the
+// expression is true.
+type queryFilterSelector struct {
+	expr queryExpr
+}
+
+func (s queryFilterSelector) selectFrom(value, root interface{}, results []interface{}) []interface{} {
+	for _, child := range queryChildren(value) {
+		if s.expr.test(child, root) {
+			results = append(results, child)
+		}
+	}
+	return results
+}
+
+/*
+	Filter expressions
+	------------------------------------------------
+*/
+
+// queryExpr is a filter expression.
+type queryExpr interface {
+	test(current, root interface{}) bool
+}
+
+type queryOr struct{ left, right queryExpr }
+
+func (e queryOr) test(current, root interface{}) bool {
+	return e.left.test(current, root) || e.right.test(current, root)
+}
+
+type queryAnd struct{ left, right queryExpr }
+
+func (e queryAnd) test(current, root interface{}) bool {
+	return e.left.test(current, root) && e.right.test(current, root)
+}
+
+type queryNot struct{ expr queryExpr }
+
+func (e queryNot) test(current, root interface{}) bool {
+	return !e.expr.test(current, root)
+}
+
+// queryExists is true if the path finds anything.
+type queryExists struct{ path *queryPath }
+
+func (e queryExists) test(current, root interface{}) bool {
+	return len(e.path.results(current, root)) > 0
+}
+
+// queryCompare compares two operands.
+type queryCompare struct {
+	left  queryOperand
+	op    string
+	right queryOperand
+}
+
+func (e queryCompare) test(current, root interface{}) bool {
+
+	left, leftOK := e.left.value(current, root)
+	right, rightOK := e.right.value(current, root)
+
+	switch e.op {
+	case "==":
+		return queryEqual(left, leftOK, right, rightOK)
+	case "!=":
+		return !queryEqual(left, leftOK, right, rightOK)
+	}
+
+	if !leftOK || !rightOK {
+		return false
+	}
+
+	var order int
+	if l, ok := queryNumber(left); ok {
+		r, ok := queryNumber(right)
+		if !ok {
+			return false
+		}
+		switch {
+		case l < r:
+			order = -1
+		case l > r:
+			order = 1
+		}
+	} else if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		order = strings.Compare(l, r)
+	} else {
+		return false
+	}
+
+	switch e.op {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	}
+	return order >= 0
+}
+
+// queryEqual compares two operands.  Operands that found nothing are only
+// equal to each other.
+func queryEqual(left interface{}, leftOK bool, right interface{}, rightOK bool) bool {
+
+	if !leftOK || !rightOK {
+		return leftOK == rightOK
+	}
+
+	if l, ok := queryNumber(left); ok {
+		r, ok := queryNumber(right)
+		return ok && l == r
+	}
+
+	return valuesEqual(left, right)
+}
+
+// queryNumber gets the value as a float64 if it is a number.  Unlike
+// toFloat64, numeric strings are not numbers.
+func queryNumber(value interface{}) (float64, bool) {
+
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	case json.Number:
+		f, err := value.(json.Number).Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+
+	n, err := numbers.FromInterface(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return n.Float64(), true
+}
+
+// queryOperand is one side of a comparison.
+type queryOperand interface {
+	// value gets the value of the operand, and whether there is one.
+	value(current, root interface{}) (interface{}, bool)
+}
+
+// queryLiteral is a number, string, true, false or null.
+type queryLiteral struct{ v interface{} }
+
+func (o queryLiteral) value(current, root interface{}) (interface{}, bool) {
+	return o.v, true
+}
+
+// queryPath is a path from the value being tested (`@`) or the root (`$`).
+type queryPath struct {
+	fromRoot bool
+	steps    []queryStep
+}
+
+// results gets everything the path finds.
+func (o *queryPath) results(current, root interface{}) []interface{} {
+	if o.fromRoot {
+		return evaluateQuery(o.steps, root, root)
+	}
+	return evaluateQuery(o.steps, current, root)
+}
+
+// value gets the value the path finds, as long as it finds exactly one.
+func (o *queryPath) value(current, root interface{}) (interface{}, bool) {
+	results := o.results(current, root)
+	if len(results) != 1 {
+		return nil, false
+	}
+	return results[0], true
+}
+
+/*
+	Parsing
+	------------------------------------------------
+*/
+
+// queryParser parses a query expression.
+type queryParser struct {
+	expression string
+	pos        int
+}
+
+// errorf makes an error describing what is wrong at the current position.
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Map: Query '%s' is invalid at position %d: %s.", p.expression, p.pos, fmt.Sprintf(format, args...))
+}
+
+// peek gets the next byte, or 0 at the end.
+func (p *queryParser) peek() byte {
+	if p.pos < len(p.expression) {
+		return p.expression[p.pos]
+	}
+	return 0
+}
+
+// consume moves past s if it is next.
+func (p *queryParser) consume(s string) bool {
+	if strings.HasPrefix(p.expression[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// expect moves past s, or returns an error if it isn't next.
+func (p *queryParser) expect(s string) error {
+	p.skipSpace()
+	if !p.consume(s) {
+		return p.errorf("expected '%s'", s)
+	}
+	return nil
+}
+
+// skipSpace moves past any spaces.
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.expression) && strings.IndexByte(" \t\r\n", p.expression[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+// parseSteps parses the steps that follow `$` or `@`.
+func (p *queryParser) parseSteps() ([]queryStep, error) {
+
+	var steps []queryStep
+
+	for {
+
+		var step queryStep
+		var err error
+
+		switch {
+		case p.consume(".."):
+			step.recursive = true
+			if p.peek() == '[' {
+				step.selector, err = p.parseBracket()
+			} else {
+				step.selector, err = p.parseDotSelector()
+			}
+		case p.consume("."):
+			step.selector, err = p.parseDotSelector()
+		case p.peek() == '[':
+			step.selector, err = p.parseBracket()
+		default:
+			return steps, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+
+	}
+
+}
+
+// parseDotSelector parses the name or `*` after a dot.
+func (p *queryParser) parseDotSelector() (querySelector, error) {
+
+	if p.consume("*") {
+		return queryWildcardSelector{}, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.expression) && strings.IndexByte(".[]()<>=!&|,'\" \t\r\n", p.expression[p.pos]) == -1 {
+		p.pos++
+	}
+
+	if p.pos == start {
+		return nil, p.errorf("expected a name")
+	}
+
+	return queryNameSelector(p.expression[start:p.pos]), nil
+}
+
+// parseBracket parses a selector in square brackets.
+func (p *queryParser) parseBracket() (querySelector, error) {
+
+	p.pos++
+	p.skipSpace()
+
+	if p.consume("*") {
+		return queryWildcardSelector{}, p.expect("]")
+	}
+
+	if p.consume("?") {
+
+		p.skipSpace()
+		parenthesised := p.consume("(")
+
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if parenthesised {
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+
+		return queryFilterSelector{expr: expr}, p.expect("]")
+	}
+
+	var union queryUnionSelector
+
+	for {
+
+		p.skipSpace()
+		selector, err := p.parseBracketItem()
+		if err != nil {
+			return nil, err
+		}
+		union = append(union, selector)
+
+		p.skipSpace()
+		if p.consume(",") {
+			continue
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		break
+
+	}
+
+	if len(union) == 1 {
+		return union[0], nil
+	}
+
+	return union, nil
+}
+
+// parseBracketItem parses a quoted name, index or slice inside brackets.
+func (p *queryParser) parseBracketItem() (querySelector, error) {
+
+	if c := p.peek(); c == '\'' || c == '"' {
+		name, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return queryNameSelector(name), nil
+	}
+
+	var s querySliceSelector
+	var err error
+
+	if s.start, s.hasStart, err = p.parseInt(); err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if !p.consume(":") {
+		if !s.hasStart {
+			return nil, p.errorf("expected a name, index or slice")
+		}
+		return queryIndexSelector(s.start), nil
+	}
+
+	p.skipSpace()
+	if s.end, s.hasEnd, err = p.parseInt(); err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.consume(":") {
+		p.skipSpace()
+		if s.step, s.hasStep, err = p.parseInt(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// parseInt parses an integer, if there is one.
+func (p *queryParser) parseInt() (int, bool, error) {
+
+	start := p.pos
+	p.consume("-")
+	for p.pos < len(p.expression) && p.expression[p.pos] >= '0' && p.expression[p.pos] <= '9' {
+		p.pos++
+	}
+
+	if p.pos == start {
+		return 0, false, nil
+	}
+
+	i, err := strconv.Atoi(p.expression[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false, p.errorf("expected an integer")
+	}
+
+	return i, true, nil
+}
+
+// parseString parses a string in single or double quotes.  A backslash
+// makes the next character literal.
+func (p *queryParser) parseString() (string, error) {
+
+	quote := p.expression[p.pos]
+	p.pos++
+
+	var s []byte
+	for p.pos < len(p.expression) {
+		c := p.expression[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return string(s), nil
+		case c == '\\' && p.pos < len(p.expression):
+			s = append(s, p.expression[p.pos])
+			p.pos++
+		default:
+			s = append(s, c)
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+// parseOr parses expressions joined with ||.
+func (p *queryParser) parseOr() (queryExpr, error) {
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = queryOr{left, right}
+	}
+
+}
+
+// parseAnd parses expressions joined with &&.
+func (p *queryParser) parseAnd() (queryExpr, error) {
+
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = queryAnd{left, right}
+	}
+
+}
+
+// parseUnary parses a negation, an expression in parentheses, a comparison
+// or a path on its own.
+func (p *queryParser) parseUnary() (queryExpr, error) {
+
+	p.skipSpace()
+
+	if p.consume("!") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{expr}, nil
+	}
+
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+
+	var op string
+	for _, candidate := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	if op == "" {
+		path, ok := left.(*queryPath)
+		if !ok {
+			return nil, p.errorf("expected a comparison")
+		}
+		return queryExists{path}, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return queryCompare{left: left, op: op, right: right}, nil
+}
+
+// parseOperand parses a path, string, number, true, false or null.
+func (p *queryParser) parseOperand() (queryOperand, error) {
+
+	p.skipSpace()
+
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		steps, err := p.parseSteps()
+		if err != nil {
+			return nil, err
+		}
+		return &queryPath{fromRoot: c == '$', steps: steps}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return queryLiteral{s}, nil
+	case p.consume("true"):
+		return queryLiteral{true}, nil
+	case p.consume("false"):
+		return queryLiteral{false}, nil
+	case p.consume("null"):
+		return queryLiteral{nil}, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.expression) && strings.IndexByte("+-.0123456789eE", p.expression[p.pos]) != -1 {
+		p.pos++
+	}
+
+	f, err := strconv.ParseFloat(p.expression[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("expected a value")
+	}
+
+	return queryLiteral{f}, nil
+}
@@ -0,0 +1,172 @@
+package objects
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var queryTestMap = M(
+	"store", M("name", "stew"),
+	"orders", []interface{}{
+		M("id", 1, "status", "paid", "items", []interface{}{
+			M("sku", "A1", "qty", 1, "price", 2.5),
+			M("sku", "B2", "qty", 3, "price", float32(10)),
+		}),
+		M("id", int64(2), "status", "open", "items", []Map{
+			M("sku", "C3", "qty", json.Number("5"), "price", 1),
+		}),
+		M("id", uint8(3), "status", "open", "items", []interface{}{}, "note", "rush"),
+	},
+	"tags", []string{"a", "b", "c", "d"},
+)
+
+func assertQuery(t *testing.T, expression string, expected ...interface{}) {
+
+	results, err := queryTestMap.Query(expression)
+	if assert.NoError(t, err, expression) {
+		if len(expected) == 0 {
+			assert.Empty(t, results, expression)
+		} else {
+			assert.Equal(t, expected, results, expression)
+		}
+	}
+
+}
+
+func TestQuery_Paths(t *testing.T) {
+
+	assertQuery(t, "$", queryTestMap)
+	assertQuery(t, "$.store.name", "stew")
+	assertQuery(t, "$['store']['name']", "stew")
+	assertQuery(t, `$["store"].name`, "stew")
+	assertQuery(t, "$.store.missing")
+	assertQuery(t, "$.orders[0].id", 1)
+	assertQuery(t, "$.orders[-1].note", "rush")
+	assertQuery(t, "$.orders[5].id")
+	assertQuery(t, "$.orders[*].id", 1, int64(2), uint8(3))
+	assertQuery(t, "$.orders.*.status", "paid", "open", "open")
+	assertQuery(t, "$.store.*", "stew")
+	assertQuery(t, "$.orders[1].items[0].sku", "C3")
+	assertQuery(t, "$.orders[0]['id','status']", 1, "paid")
+
+}
+
+func TestQuery_Slices(t *testing.T) {
+
+	assertQuery(t, "$.tags[1:3]", "b", "c")
+	assertQuery(t, "$.tags[:2]", "a", "b")
+	assertQuery(t, "$.tags[2:]", "c", "d")
+	assertQuery(t, "$.tags[-2:]", "c", "d")
+	assertQuery(t, "$.tags[::2]", "a", "c")
+	assertQuery(t, "$.tags[::-1]", "d", "c", "b", "a")
+	assertQuery(t, "$.tags[0,3]", "a", "d")
+	assertQuery(t, "$.tags[0:100]", "a", "b", "c", "d")
+	assertQuery(t, "$.tags[::0]")
+
+}
+
+func TestQuery_RecursiveDescent(t *testing.T) {
+
+	assertQuery(t, "$..sku", "A1", "B2", "C3")
+	assertQuery(t, "$..items[0].sku", "A1", "C3")
+	assertQuery(t, "$..note", "rush")
+	assertQuery(t, "$.store..*", "stew")
+
+	results, err := queryTestMap.Query("$..*")
+	if assert.NoError(t, err) {
+		// every value inside the map
+		count := 0
+		queryTestMap.Walk(func(keypath string, value interface{}) error {
+			count++
+			return nil
+		})
+		assert.Equal(t, count, len(results))
+	}
+
+}
+
+func TestQuery_Filters(t *testing.T) {
+
+	assertQuery(t, "$.orders[*].items[?(@.qty > 2)].sku", "B2", "C3")
+	assertQuery(t, "$..items[?(@.qty >= 3 && @.price < 5)].sku", "C3")
+	assertQuery(t, "$..items[?(@.qty == 1 || @.sku == 'C3')].sku", "A1", "C3")
+	assertQuery(t, "$.orders[?(@.status == 'open')].id", int64(2), uint8(3))
+	assertQuery(t, "$.orders[?(@.status != 'open')].id", 1)
+	assertQuery(t, "$.orders[?(!(@.status == 'open'))].id", 1)
+	assertQuery(t, "$.orders[?(@.note)].id", uint8(3))
+	assertQuery(t, "$.orders[?(!@.note)].id", 1, int64(2))
+	assertQuery(t, `$.orders[?@.id==2].status`, "open")
+	assertQuery(t, "$.orders[?(@.id < $.orders[1].id)].id", 1)
+	assertQuery(t, "$.orders[?(@.status > 'open')].id", 1)
+	assertQuery(t, "$.tags[?(@ == 'b')]", "b")
+
+	// mixed numeric types compare by value
+	assertQuery(t, "$.orders[?(@.id == 3)].status", "open")
+	assertQuery(t, "$..items[?(@.price == 10)].sku", "B2")
+	assertQuery(t, "$..items[?(@.qty == 5.0)].sku", "C3")
+
+	// numbers are not strings, and missing values don't compare
+	assertQuery(t, "$.orders[?(@.id == '1')].id")
+	assertQuery(t, "$.orders[?(@.missing < 1)].id")
+	assertQuery(t, "$.orders[?(@.missing == null)].id")
+	assertQuery(t, "$.orders[?(@.missing != 1)].id", 1, int64(2), uint8(3))
+
+}
+
+func TestQuery_Errors(t *testing.T) {
+
+	for _, expression := range []string{
+		"",
+		"store.name",
+		"$.",
+		"$[",
+		"$['name",
+		"$[abc]",
+		"$.a[?(@.b >)]",
+		"$.a[?(@.b == 1]",
+		"$.a[?(1)]",
+		"$.a b",
+		"$[99999999999999999999]",
+	} {
+		_, err := CompileQuery(expression)
+		assert.Error(t, err, expression)
+		_, err = queryTestMap.Query(expression)
+		assert.Error(t, err, expression)
+	}
+
+	_, err := CompileQuery("$.a[?(@.b >)]")
+	assert.Equal(t, "Map: Query '$.a[?(@.b >)]' is invalid at position 11: expected a value.", err.Error())
+
+	assert.Panics(t, func() {
+		MustCompileQuery("nope")
+	})
+
+}
+
+func TestCompileQuery(t *testing.T) {
+
+	q, err := CompileQuery("$.orders[*].items[?(@.qty > 2)].sku")
+	if assert.NoError(t, err) {
+
+		assert.Equal(t, "$.orders[*].items[?(@.qty > 2)].sku", q.String())
+		assert.Equal(t, []interface{}{"B2", "C3"}, q.Query(queryTestMap))
+
+		// compiled queries are cached
+		again, _ := CompileQuery("$.orders[*].items[?(@.qty > 2)].sku")
+		assert.True(t, q == again)
+
+	}
+
+	first, ok := MustCompileQuery("$..sku").QueryFirst(queryTestMap)
+	assert.True(t, ok)
+	assert.Equal(t, "A1", first)
+
+	_, ok = MustCompileQuery("$..nope").QueryFirst(queryTestMap)
+	assert.False(t, ok)
+
+	// the same query works on other maps, including map[string]interface{} inside
+	other, _ := NewMapFromJSON(`{"orders":[{"items":[{"sku":"Z9","qty":9}]}]}`)
+	assert.Equal(t, []interface{}{"Z9"}, q.Query(other))
+
+}
@@ -0,0 +1,326 @@
+package objects
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// RedactedText replaces values masked with MaskFull.
+const RedactedText string = "[REDACTED]"
+
+// partialMaskText replaces the hidden part of values masked with MaskPartial.
+const partialMaskText string = "****"
+
+// Mask replaces a sensitive value with something safe to log.
+type Mask func(value interface{}) interface{}
+
+// MaskFull replaces the whole value with RedactedText.
+var MaskFull Mask = func(value interface{}) interface{} {
+	return RedactedText
+}
+
+// MaskPartial gets a Mask that keeps the last few characters of a value,
+// so that "4111111111111234" becomes "****1234".  Values are only partly
+// shown if they are at least twice as long as the part kept, and maps and
+// slices are always masked in full.
+func MaskPartial(visible int) Mask {
+	return func(value interface{}) interface{} {
+
+		if _, isMap := asMap(value); isMap {
+			return RedactedText
+		}
+		if _, isSlice := sliceLen(value); isSlice {
+			return RedactedText
+		}
+
+		s := fmt.Sprint(value)
+		length := utf8.RuneCountInString(s)
+		if visible <= 0 || length < visible*2 {
+			return partialMaskText
+		}
+
+		runes := []rune(s)
+		return partialMaskText + string(runes[length-visible:])
+	}
+}
+
+// MaskHash gets a Mask that replaces values with a short HMAC-SHA256 of them,
+// like "sha256:5e884898da280471", so that the same value can be spotted
+// across log lines without being shown.  Use a secret key, since short
+// values like passwords are easy to guess from a hash of them alone.
+func MaskHash(key []byte) Mask {
+	return func(value interface{}) interface{} {
+
+		s, isString := value.(string)
+		if !isString {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return RedactedText
+			}
+			s = string(encoded)
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(s))
+
+		return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+}
+
+// RedactRule says which values a Redactor masks, and how.
+type RedactRule struct {
+	// Pattern is either a keypath glob, like `**.password` or
+	// `users[*].token`, or a regular expression between slashes, like
+	// `/(?i)token|secret/`, that is matched against key names at any depth.
+	//
+	// In keypath globs `*` within a key matches any run of characters, `[*]`
+	// matches any index, and a `**` segment matches any number of keys and
+	// indexes, including none.
+	Pattern string
+	// Mask replaces the values matched.  If it is nil, MaskFull is used.
+	Mask Mask
+}
+
+// redactRule is a RedactRule ready to be matched.
+type redactRule struct {
+	glob    keypathGlob
+	keyName *regexp.Regexp
+	mask    Mask
+}
+
+// Redactor makes copies of maps with their sensitive values masked, so that
+// they can be logged.  A Redactor is safe to use from many goroutines at once.
+type Redactor struct {
+	rules []redactRule
+}
+
+// NewRedactor creates a Redactor with the rules.  When more than one rule
+// matches a value, the first one is used.
+//
+// For example:
+//
+//     redactor, err := objects.NewRedactor(
+//         objects.RedactRule{Pattern: "/(?i)password|secret/"},
+//         objects.RedactRule{Pattern: "**.card_number", Mask: objects.MaskPartial(4)},
+//     )
+//
+// An error is returned if a regular expression will not compile.
+func NewRedactor(rules ...RedactRule) (*Redactor, error) {
+
+	r := &Redactor{rules: make([]redactRule, len(rules))}
+
+	for i, rule := range rules {
+
+		r.rules[i].mask = rule.Mask
+		if r.rules[i].mask == nil {
+			r.rules[i].mask = MaskFull
+		}
+
+		if len(rule.Pattern) > 1 && strings.HasPrefix(rule.Pattern, "/") && strings.HasSuffix(rule.Pattern, "/") {
+			keyName, err := regexp.Compile(rule.Pattern[1 : len(rule.Pattern)-1])
+			if err != nil {
+				return nil, errors.New("Map: Redact pattern '" + rule.Pattern + "' is invalid: " + err.Error() + ".")
+			}
+			r.rules[i].keyName = keyName
+			continue
+		}
+
+		r.rules[i].glob = parseKeypathGlob(rule.Pattern)
+	}
+
+	return r, nil
+}
+
+// NewRedactorFromPatterns creates a Redactor that fully masks values matching
+// any of the patterns.  See RedactRule for how patterns are written.
+func NewRedactorFromPatterns(patterns ...string) (*Redactor, error) {
+
+	rules := make([]RedactRule, len(patterns))
+	for i, pattern := range patterns {
+		rules[i].Pattern = pattern
+	}
+
+	return NewRedactor(rules...)
+}
+
+// Redact gets a deep copy of the map with every value matched by the rules
+// masked.  Matching maps and slices are masked as a whole; slices that are
+// copied become []interface{}, and maps of other types, like
+// map[string]string, become Maps.  Structs are masked in full.
+func (r *Redactor) Redact(m Map) Map {
+
+	if m == nil {
+		return nil
+	}
+
+	return r.redactMap("", m)
+}
+
+// Wrap gets a Redacted holding the map, which is redacted only when it is
+// logged or printed.
+func (r *Redactor) Wrap(m Map) Redacted {
+	return Redacted{m: m, redactor: r}
+}
+
+// mask gets the mask for the value at the keypath, or nil if it should be
+// kept.  key is empty for slice items.
+func (r *Redactor) mask(keypath, key string, isKey bool) Mask {
+
+	for _, rule := range r.rules {
+		if rule.keyName != nil {
+			if isKey && rule.keyName.MatchString(key) {
+				return rule.mask
+			}
+			continue
+		}
+		if rule.glob.match(keypath) {
+			return rule.mask
+		}
+	}
+
+	return nil
+}
+
+// redactMap copies the map, redacting its values.
+func (r *Redactor) redactMap(keypath string, m Map) Map {
+
+	redacted := make(Map, len(m))
+	for k, v := range m {
+		childPath := childKeypath(keypath, k)
+		if mask := r.mask(childPath, k, true); mask != nil {
+			redacted[k] = mask(v)
+			continue
+		}
+		redacted[k] = r.redactValue(childPath, v)
+	}
+
+	return redacted
+}
+
+// redactValue copies the value, redacting anything inside it.
+func (r *Redactor) redactValue(keypath string, value interface{}) interface{} {
+
+	if m, ok := asMap(value); ok {
+		if _, isMSI := value.(map[string]interface{}); isMSI {
+			return r.redactMap(keypath, m).MSI()
+		}
+		return r.redactMap(keypath, m)
+	}
+
+	if length, ok := sliceLen(value); ok {
+		items := make([]interface{}, length)
+		for i := range items {
+			itemPath := indexKeypath(keypath, i)
+			if mask := r.mask(itemPath, "", false); mask != nil {
+				items[i] = mask(sliceItem(value, i))
+				continue
+			}
+			items[i] = r.redactValue(itemPath, sliceItem(value, i))
+		}
+		return items
+	}
+
+	return r.redactReflect(keypath, reflect.ValueOf(value))
+}
+
+// redactReflect copies values of other types, like map[string]string or
+// http.Header, redacting anything inside them.  Maps become Maps and slices
+// become []interface{}.  Structs cannot be matched by keypath, so they are
+// masked in full unless they are encoding.TextMarshalers, like time.Time.
+func (r *Redactor) redactReflect(keypath string, v reflect.Value) interface{} {
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Implements(textMarshalerType) {
+			return v.Interface()
+		}
+		return r.redactValue(keypath, v.Elem().Interface())
+	case reflect.Map:
+		redacted := make(Map, v.Len())
+		for _, key := range v.MapKeys() {
+			k := fmt.Sprint(key.Interface())
+			childPath := childKeypath(keypath, k)
+			if mask := r.mask(childPath, k, true); mask != nil {
+				redacted[k] = mask(v.MapIndex(key).Interface())
+				continue
+			}
+			redacted[k] = r.redactValue(childPath, v.MapIndex(key).Interface())
+		}
+		return redacted
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// bytes are a single value
+			return deepCopyValue(v.Interface())
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			itemPath := indexKeypath(keypath, i)
+			if mask := r.mask(itemPath, "", false); mask != nil {
+				items[i] = mask(v.Index(i).Interface())
+				continue
+			}
+			items[i] = r.redactValue(itemPath, v.Index(i).Interface())
+		}
+		return items
+	case reflect.Struct:
+		if v.Type().Implements(textMarshalerType) {
+			return v.Interface()
+		}
+		return RedactedText
+	}
+
+	return v.Interface()
+}
+
+// Redacted holds a map that is redacted whenever it is printed, encoded as
+// JSON or logged with log/slog, so that the redaction happens only if the
+// map is actually written out.
+//
+// For example:
+//
+//     logger.Info("request", "payload", redactor.Wrap(payload))
+type Redacted struct {
+	m        Map
+	redactor *Redactor
+}
+
+// Map gets the redacted copy of the map.
+func (r Redacted) Map() Map {
+	return r.redactor.Redact(r.m)
+}
+
+// String gets the redacted map as JSON.
+func (r Redacted) String() string {
+	j, err := r.Map().JSON()
+	if err != nil {
+		return RedactedText
+	}
+	return j
+}
+
+// GoString gets the redacted map as Go syntax, so that printing with %#v
+// does not show the original.
+func (r Redacted) GoString() string {
+	return fmt.Sprintf("%#v", r.Map())
+}
+
+// MarshalJSON encodes the redacted map as JSON.
+func (r Redacted) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Map())
+}
@@ -0,0 +1,29 @@
+//go:build go1.21
+// +build go1.21
+
+package objects
+
+import (
+	"log/slog"
+)
+
+// LogValue implements slog.LogValuer, so that the map is redacted before
+// it is logged.  Maps become groups, with their keys in order.
+func (r Redacted) LogValue() slog.Value {
+	return mapLogValue(r.Map())
+}
+
+// mapLogValue gets the map as a slog group.
+func mapLogValue(m Map) slog.Value {
+
+	attrs := make([]slog.Attr, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		if nested, ok := asMap(m[k]); ok {
+			attrs = append(attrs, slog.Attr{Key: k, Value: mapLogValue(nested)})
+			continue
+		}
+		attrs = append(attrs, slog.Any(k, m[k]))
+	}
+
+	return slog.GroupValue(attrs...)
+}
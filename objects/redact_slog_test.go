@@ -0,0 +1,35 @@
+//go:build go1.21
+// +build go1.21
+
+package objects
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestRedacted_LogValue(t *testing.T) {
+
+	redactor, _ := NewRedactorFromPatterns("**.password", "/(?i)token/")
+
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	logger.Info("request", "payload", redactor.Wrap(M(
+		"user", M("name", "mat", "password", "hunter2"),
+		"Token", "abc",
+		"ids", []int{1, 2},
+	)))
+
+	assert.Equal(t, "level=INFO msg=request payload.Token=[REDACTED] payload.ids=\"[1 2]\" payload.user.name=mat payload.user.password=[REDACTED]\n", buffer.String())
+
+}
@@ -0,0 +1,155 @@
+package objects
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var redactTestMap = M(
+	"user", M("name", "mat", "password", "hunter2", "card", M("number", "4111111111111234")),
+	"password", "top",
+	"Authorization", "Bearer abc",
+	"api_token", "t0k3n",
+	"sessions", []interface{}{M("id", 1, "secret", "s1"), M("id", 2, "secret", "s2")},
+	"tokens", []string{"a", "b"},
+	"count", 3,
+)
+
+func TestRedactor(t *testing.T) {
+
+	redactor, err := NewRedactor(
+		RedactRule{Pattern: "user.card.number", Mask: MaskPartial(4)},
+		RedactRule{Pattern: "*.password"},
+		RedactRule{Pattern: "/(?i)token|secret|authorization/"},
+	)
+
+	if assert.NoError(t, err) {
+
+		redacted := redactor.Redact(redactTestMap)
+
+		assert.Equal(t, M(
+			"user", M("name", "mat", "password", RedactedText, "card", M("number", "****1234")),
+			"password", "top",
+			"Authorization", RedactedText,
+			"api_token", RedactedText,
+			"sessions", []interface{}{M("id", 1, "secret", RedactedText), M("id", 2, "secret", RedactedText)},
+			"tokens", RedactedText,
+			"count", 3,
+		), redacted)
+
+		// the original is unchanged
+		assert.Equal(t, "hunter2", redactTestMap.Get("user.password"))
+		assert.Equal(t, "s1", redactTestMap.Get("sessions[0].secret"))
+
+		// the copy is deep
+		redacted.Set("user.name", "changed")
+		assert.Equal(t, "mat", redactTestMap.Get("user.name"))
+
+		assert.Nil(t, redactor.Redact(nil))
+
+	}
+
+}
+
+func TestRedactor_TypedContainers(t *testing.T) {
+
+	redactor, _ := NewRedactorFromPatterns("/(?i)authorization|password/", "users[*].email")
+
+	header := http.Header{"Authorization": {"Bearer xyz"}, "Accept": {"*/*"}}
+	created := time.Date(2013, 8, 1, 0, 0, 0, 0, time.UTC)
+	m := M(
+		"headers", header,
+		"env", map[string]string{"PASSWORD": "hunter2", "HOME": "/root"},
+		"users", []map[string]string{{"name": "mat", "email": "mat@example.com"}},
+		"ports", [2]int{80, 443},
+		"raw", []byte("data"),
+		"created", created,
+		"config", &struct{ Password string }{"hunter2"},
+	)
+
+	assert.Equal(t, M(
+		"headers", M("Authorization", RedactedText, "Accept", []interface{}{"*/*"}),
+		"env", M("PASSWORD", RedactedText, "HOME", "/root"),
+		"users", []interface{}{M("name", "mat", "email", RedactedText)},
+		"ports", []interface{}{80, 443},
+		"raw", []byte("data"),
+		"created", created,
+		"config", RedactedText,
+	), redactor.Redact(m))
+
+	// the copy does not share the original's maps
+	redactor.Redact(m).Get("headers").(Map).Get("Accept").([]interface{})[0] = "changed"
+	assert.Equal(t, "*/*", header.Get("Accept"))
+	assert.Equal(t, "Bearer xyz", header.Get("Authorization"))
+
+}
+
+func TestRedactor_Globs(t *testing.T) {
+
+	redactor, err := NewRedactorFromPatterns("**.password", "sessions[*].id", "tokens[1]")
+	if assert.NoError(t, err) {
+
+		redacted := redactor.Redact(redactTestMap)
+
+		assert.Equal(t, RedactedText, redacted.Get("password"))
+		assert.Equal(t, RedactedText, redacted.Get("user.password"))
+		assert.Equal(t, RedactedText, redacted.Get("sessions[1].id"))
+		assert.Equal(t, "s2", redacted.Get("sessions[1].secret"))
+		assert.Equal(t, []interface{}{"a", RedactedText}, redacted.Get("tokens"))
+		assert.Equal(t, "Bearer abc", redacted.Get("Authorization"))
+
+	}
+
+	_, err = NewRedactorFromPatterns("/(unclosed/")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Map: Redact pattern '/(unclosed/' is invalid: ")
+	}
+
+}
+
+func TestRedactor_Masks(t *testing.T) {
+
+	assert.Equal(t, RedactedText, MaskFull(M("a", 1)))
+
+	partial := MaskPartial(4)
+	assert.Equal(t, "****1234", partial("4111111111111234"))
+	assert.Equal(t, "****5678", partial(12345678))
+	assert.Equal(t, "****", partial("1234567"))
+	assert.Equal(t, "****ößü!", partial("secretößü!"))
+	assert.Equal(t, RedactedText, partial(M("a", "12345678")))
+	assert.Equal(t, RedactedText, partial([]interface{}{"12345678"}))
+	assert.Equal(t, "****", MaskPartial(0)("12345678"))
+
+	hash := MaskHash([]byte("key"))
+	hashed := hash("hunter2").(string)
+	assert.Equal(t, 23, len(hashed))
+	assert.Equal(t, "sha256:", hashed[:7])
+	assert.Equal(t, hashed, hash("hunter2"))
+	assert.NotEqual(t, hashed, hash("hunter3"))
+	assert.NotEqual(t, hashed, MaskHash([]byte("other"))("hunter2"))
+	assert.Equal(t, hash(M("b", 2, "a", 1)), hash(map[string]interface{}{"a": 1, "b": 2}))
+	assert.Equal(t, RedactedText, hash(func() {}))
+
+}
+
+func TestRedacted(t *testing.T) {
+
+	redactor, _ := NewRedactorFromPatterns("/password/")
+	wrapped := redactor.Wrap(M("user", "mat", "password", "hunter2"))
+
+	assert.Equal(t, M("user", "mat", "password", RedactedText), wrapped.Map())
+	assert.Equal(t, `{"password":"[REDACTED]","user":"mat"}`, wrapped.String())
+	assert.Equal(t, `{"password":"[REDACTED]","user":"mat"}`, fmt.Sprint(wrapped))
+	assert.Equal(t, `objects.Map{"password":"[REDACTED]", "user":"mat"}`, fmt.Sprintf("%#v", wrapped))
+	assert.NotContains(t, fmt.Sprintf("%#v", M("payload", wrapped)), "hunter2")
+
+	j, err := json.Marshal(M("payload", wrapped))
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"payload":{"password":"[REDACTED]","user":"mat"}}`, string(j))
+	}
+
+}
@@ -0,0 +1,851 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// SchemaTagName is the name of the struct tag that NewSchemaFromStruct
+	// reads constraints from.  Field names follow the same rules as Decode.
+	//
+	// For example:
+	//
+	//     type User struct {
+	//         Name  string   `json:"name" schema:"required,min=1,max=100"`
+	//         Email string   `json:"email" schema:"required,format=email"`
+	//         Role  string   `json:"role" schema:"enum=admin|user"`
+	//         Age   int      `json:"age" schema:"minimum=0"`
+	//         Slug  string   `json:"slug" schema:"max=50,pattern=^[a-z-]+$"`
+	//         Tags  []string `json:"tags" schema:"max=10"`
+	//     }
+	//
+	// Constraints are separated by commas, so a pattern must come last.  min
+	// and max become minLength, minItems or minimum (and so on) depending on
+	// the type of the field.
+	SchemaTagName string = "schema"
+)
+
+// maxSchemaRefs is the most $refs that may be followed without moving on
+// to a different value, so that schemas referring to themselves end.
+const maxSchemaRefs int = 32
+
+// schemaTypes are the names allowed in a schema's type.
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// schemaUUID matches a UUID in the format used by RFC 4122.
+var schemaUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// schemaHostname matches an RFC 1123 hostname.
+var schemaHostname = regexp.MustCompile(`^(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)(\.(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?))*$`)
+
+// SchemaViolation describes a single way in which a value breaks a schema.
+type SchemaViolation struct {
+	// Keypath is where the value is in the Map.
+	Keypath string
+	// Keyword is the schema keyword that was broken, for example "minLength".
+	Keyword string
+	// Message describes what is wrong.
+	Message string
+}
+
+// Error gets the error message.
+func (v *SchemaViolation) Error() string {
+	return fmt.Sprintf("'%s': %s", v.Keypath, v.Message)
+}
+
+// ValidationError is returned by Validate, and lists every way in which the
+// map breaks the schema.
+type ValidationError struct {
+	Violations []*SchemaViolation
+}
+
+// Error gets the error message.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Error()
+	}
+	return fmt.Sprintf("Map: Validation failed for %d value(s): %s", len(e.Violations), strings.Join(messages, "; "))
+}
+
+// Schema validates Maps against a JSON Schema.  Schemas are safe to use from
+// many goroutines at once.
+//
+// The supported keywords, from draft 2020-12, are:
+//
+//     type, enum, const, $ref (within the schema), $defs, anyOf
+//     properties, required, additionalProperties, minProperties, maxProperties
+//     items, minItems, maxItems
+//     minLength, maxLength, pattern, format
+//     minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//
+// The formats checked are date-time, date, time, email, hostname, ipv4,
+// ipv6, uri and uuid.  Other keywords and formats are ignored.
+type Schema struct {
+	root     Map
+	patterns map[string]*regexp.Regexp
+}
+
+// NewSchema creates a Schema from a JSON Schema held in a Map.
+//
+// For example:
+//
+//     schema, err := objects.NewSchema(objects.M(
+//         "type", "object",
+//         "required", []interface{}{"name"},
+//         "properties", objects.M("name", objects.M("type", "string")),
+//     ))
+//
+// An error is returned if the schema itself is invalid; for example if it has
+// a pattern that will not compile or a $ref to something that doesn't exist.
+func NewSchema(schema Map) (*Schema, error) {
+
+	s := &Schema{root: schema.DeepCopy(), patterns: make(map[string]*regexp.Regexp)}
+
+	if err := s.compile(nil, s.root, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Map gets a copy of the schema as a Map.
+func (s *Schema) Map() Map {
+	return s.root.DeepCopy()
+}
+
+// Validate checks the map against the schema, returning a *ValidationError
+// listing every violation, or nil if there are none.
+func (s *Schema) Validate(m Map) error {
+
+	v := &schemaValidator{schema: s}
+	v.validate("", m, s.root, 0)
+
+	if len(v.violations) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Violations: v.violations}
+}
+
+// Validate checks the map against the schema, returning a *ValidationError
+// listing every violation, or nil if there are none.
+func (d Map) Validate(schema *Schema) error {
+	return schema.Validate(d)
+}
+
+/*
+	Compiling
+	------------------------------------------------
+*/
+
+// schemaError makes an error about the schema at the location.
+func schemaError(tokens []string, message string) error {
+	return errors.New("Map: Schema is invalid at '" + formatPointer(tokens) + "': " + message + ".")
+}
+
+// compile checks the schema at the location, and compiles its patterns.
+// The schemas that $refs refer to are compiled too, wherever they are;
+// compiled holds the locations already done, so that cycles end.
+func (s *Schema) compile(tokens []string, schema interface{}, compiled map[string]bool) error {
+
+	location := formatPointer(tokens)
+	if compiled[location] {
+		return nil
+	}
+	compiled[location] = true
+
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+
+	m, ok := asMap(schema)
+	if !ok {
+		return schemaError(tokens, "a schema must be an object or a boolean")
+	}
+
+	at := func(keyword string) []string {
+		return append(tokens[:len(tokens):len(tokens)], keyword)
+	}
+
+	if t, exists := m["type"]; exists {
+		types, ok := schemaTypeNames(t)
+		if !ok {
+			return schemaError(at("type"), "type must be a string or an array of strings")
+		}
+		for _, name := range types {
+			if !schemaTypes[name] {
+				return schemaError(at("type"), "unknown type '"+name+"'")
+			}
+		}
+	}
+
+	if ref, exists := m["$ref"]; exists {
+		refString, ok := ref.(string)
+		if !ok {
+			return schemaError(at("$ref"), "$ref must be a string")
+		}
+		refTokens, target, err := s.resolve(refString)
+		if err != nil {
+			return schemaError(at("$ref"), err.Error())
+		}
+		if err := s.compile(refTokens, target, compiled); err != nil {
+			return err
+		}
+	}
+
+	if pattern, exists := m["pattern"]; exists {
+		patternString, ok := pattern.(string)
+		if !ok {
+			return schemaError(at("pattern"), "pattern must be a string")
+		}
+		pattern, err := regexp.Compile(patternString)
+		if err != nil {
+			return schemaError(at("pattern"), err.Error())
+		}
+		s.patterns[patternString] = pattern
+	}
+
+	if required, exists := m["required"]; exists {
+		length, ok := sliceLen(required)
+		if !ok {
+			return schemaError(at("required"), "required must be an array of strings")
+		}
+		for i := 0; i < length; i++ {
+			if _, ok := sliceItem(required, i).(string); !ok {
+				return schemaError(at("required"), "required must be an array of strings")
+			}
+		}
+	}
+
+	if enum, exists := m["enum"]; exists {
+		if _, ok := sliceLen(enum); !ok {
+			return schemaError(at("enum"), "enum must be an array")
+		}
+	}
+
+	for _, keyword := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+		"minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties"} {
+		if value, exists := m[keyword]; exists {
+			if _, ok := numberValue(value); !ok {
+				return schemaError(at(keyword), keyword+" must be a number")
+			}
+		}
+	}
+
+	for _, keyword := range []string{"items", "additionalProperties"} {
+		if subschema, exists := m[keyword]; exists {
+			if err := s.compile(at(keyword), subschema, compiled); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyword := range []string{"properties", "$defs", "definitions"} {
+		if subschemas, exists := m[keyword]; exists {
+			subschemaMap, ok := asMap(subschemas)
+			if !ok {
+				return schemaError(at(keyword), keyword+" must be an object")
+			}
+			for _, k := range sortedKeys(subschemaMap) {
+				if err := s.compile(append(at(keyword), k), subschemaMap[k], compiled); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if anyOf, exists := m["anyOf"]; exists {
+		length, ok := sliceLen(anyOf)
+		if !ok || length == 0 {
+			return schemaError(at("anyOf"), "anyOf must be an array of schemas")
+		}
+		for i := 0; i < length; i++ {
+			if err := s.compile(append(at("anyOf"), strconv.Itoa(i)), sliceItem(anyOf, i), compiled); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolve finds the schema a $ref refers to, and where it is.  Only
+// references within the schema, like "#" and "#/$defs/address", are
+// supported.
+func (s *Schema) resolve(ref string) ([]string, interface{}, error) {
+
+	if !strings.HasPrefix(ref, "#") {
+		return nil, nil, errors.New("only $refs within the schema are supported")
+	}
+
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := getPointer(s.root, tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, target, nil
+}
+
+// schemaTypeNames gets the type names from a schema's type.
+func schemaTypeNames(t interface{}) ([]string, bool) {
+
+	if name, ok := t.(string); ok {
+		return []string{name}, true
+	}
+
+	length, ok := sliceLen(t)
+	if !ok {
+		return nil, false
+	}
+
+	names := make([]string, length)
+	for i := range names {
+		if names[i], ok = sliceItem(t, i).(string); !ok {
+			return nil, false
+		}
+	}
+
+	return names, true
+}
+
+/*
+	Validating
+	------------------------------------------------
+*/
+
+// schemaValidator collects violations as a value is validated.
+type schemaValidator struct {
+	schema     *Schema
+	violations []*SchemaViolation
+}
+
+// fail records a violation.
+func (v *schemaValidator) fail(keypath, keyword, format string, args ...interface{}) {
+	v.violations = append(v.violations, &SchemaViolation{Keypath: keypath, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// schemaTypeOf gets the JSON Schema type name of the value.
+func schemaTypeOf(value interface{}) string {
+
+	if value == nil {
+		return "null"
+	}
+	if _, ok := value.(bool); ok {
+		return "boolean"
+	}
+	if _, ok := value.(string); ok {
+		return "string"
+	}
+	if _, ok := asMap(value); ok {
+		return "object"
+	}
+	if _, ok := sliceLen(value); ok {
+		return "array"
+	}
+	if f, ok := numberValue(value); ok {
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// validate checks the value at the keypath against the schema.
+func (v *schemaValidator) validate(keypath string, value interface{}, schema interface{}, refs int) {
+
+	if allowed, ok := schema.(bool); ok {
+		if !allowed {
+			v.fail(keypath, "false", "no value is allowed")
+		}
+		return
+	}
+
+	m, _ := asMap(schema)
+
+	if ref, exists := m["$ref"]; exists {
+		if refs >= maxSchemaRefs {
+			v.fail(keypath, "$ref", "too many $refs were followed")
+			return
+		}
+		_, target, _ := v.schema.resolve(ref.(string))
+		v.validate(keypath, value, target, refs+1)
+	}
+
+	if t, exists := m["type"]; exists {
+		types, _ := schemaTypeNames(t)
+		actual := schemaTypeOf(value)
+		matched := false
+		for _, name := range types {
+			if name == actual || (name == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(keypath, "type", "expected %s but found %s", strings.Join(types, " or "), actual)
+		}
+	}
+
+	if enum, exists := m["enum"]; exists {
+		found := false
+		length, _ := sliceLen(enum)
+		for i := 0; i < length && !found; i++ {
+			found = valuesEqual(value, sliceItem(enum, i))
+		}
+		if !found {
+			v.fail(keypath, "enum", "must be one of %v", enum)
+		}
+	}
+
+	if constant, exists := m["const"]; exists && !valuesEqual(value, constant) {
+		v.fail(keypath, "const", "must be %v", constant)
+	}
+
+	if anyOf, exists := m["anyOf"]; exists {
+		length, _ := sliceLen(anyOf)
+		matched := false
+		for i := 0; i < length && !matched; i++ {
+			sub := &schemaValidator{schema: v.schema}
+			sub.validate(keypath, value, sliceItem(anyOf, i), refs)
+			matched = len(sub.violations) == 0
+		}
+		if !matched {
+			v.fail(keypath, "anyOf", "does not match any of the allowed schemas")
+		}
+	}
+
+	if f, ok := numberValue(value); ok {
+		v.validateNumber(keypath, f, m)
+	}
+
+	if s, ok := value.(string); ok {
+		v.validateString(keypath, s, m)
+	}
+
+	if length, ok := sliceLen(value); ok {
+		v.validateArray(keypath, value, length, m)
+	}
+
+	if object, ok := asMap(value); ok {
+		v.validateObject(keypath, object, m)
+	}
+
+}
+
+// schemaNumber gets a numeric keyword from the schema.
+func schemaNumber(schema Map, keyword string) (float64, bool) {
+	value, exists := schema[keyword]
+	if !exists {
+		return 0, false
+	}
+	return numberValue(value)
+}
+
+// validateNumber checks the number keywords.
+func (v *schemaValidator) validateNumber(keypath string, f float64, schema Map) {
+
+	if min, ok := schemaNumber(schema, "minimum"); ok && f < min {
+		v.fail(keypath, "minimum", "must be at least %v", min)
+	}
+	if max, ok := schemaNumber(schema, "maximum"); ok && f > max {
+		v.fail(keypath, "maximum", "must be at most %v", max)
+	}
+	if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok && f <= min {
+		v.fail(keypath, "exclusiveMinimum", "must be more than %v", min)
+	}
+	if max, ok := schemaNumber(schema, "exclusiveMaximum"); ok && f >= max {
+		v.fail(keypath, "exclusiveMaximum", "must be less than %v", max)
+	}
+
+}
+
+// validateString checks the string keywords.
+func (v *schemaValidator) validateString(keypath string, s string, schema Map) {
+
+	length := float64(utf8.RuneCountInString(s))
+
+	if min, ok := schemaNumber(schema, "minLength"); ok && length < min {
+		v.fail(keypath, "minLength", "must be at least %v characters long", min)
+	}
+	if max, ok := schemaNumber(schema, "maxLength"); ok && length > max {
+		v.fail(keypath, "maxLength", "must be at most %v characters long", max)
+	}
+
+	if pattern, exists := schema["pattern"]; exists && !v.schema.patterns[pattern.(string)].MatchString(s) {
+		v.fail(keypath, "pattern", "must match the pattern %s", pattern)
+	}
+
+	if format, ok := schema["format"].(string); ok && !validFormat(format, s) {
+		v.fail(keypath, "format", "must be a valid %s", format)
+	}
+
+}
+
+// validFormat checks the string is in the format.  Unknown formats are
+// always valid.
+func validFormat(format, s string) bool {
+
+	var err error
+
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339Nano, s)
+	case "date":
+		_, err = time.Parse("2006-01-02", s)
+	case "time":
+		_, err = time.Parse("15:04:05.999999999Z07:00", s)
+	case "email":
+		var address *mail.Address
+		if address, err = mail.ParseAddress(s); err == nil && address.Address != s {
+			return false
+		}
+	case "hostname":
+		return len(s) <= 253 && schemaHostname.MatchString(s)
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		return net.ParseIP(s) != nil && strings.Contains(s, ":")
+	case "uri":
+		var u *url.URL
+		if u, err = url.Parse(s); err == nil && !u.IsAbs() {
+			return false
+		}
+	case "uuid":
+		return schemaUUID.MatchString(s)
+	}
+
+	return err == nil
+}
+
+// validateArray checks the array keywords.
+func (v *schemaValidator) validateArray(keypath string, value interface{}, length int, schema Map) {
+
+	if min, ok := schemaNumber(schema, "minItems"); ok && float64(length) < min {
+		v.fail(keypath, "minItems", "must have at least %v items", min)
+	}
+	if max, ok := schemaNumber(schema, "maxItems"); ok && float64(length) > max {
+		v.fail(keypath, "maxItems", "must have at most %v items", max)
+	}
+
+	if items, exists := schema["items"]; exists {
+		for i := 0; i < length; i++ {
+			v.validate(indexKeypath(keypath, i), sliceItem(value, i), items, 0)
+		}
+	}
+
+}
+
+// validateObject checks the object keywords.
+func (v *schemaValidator) validateObject(keypath string, object Map, schema Map) {
+
+	if min, ok := schemaNumber(schema, "minProperties"); ok && float64(len(object)) < min {
+		v.fail(keypath, "minProperties", "must have at least %v keys", min)
+	}
+	if max, ok := schemaNumber(schema, "maxProperties"); ok && float64(len(object)) > max {
+		v.fail(keypath, "maxProperties", "must have at most %v keys", max)
+	}
+
+	if required, exists := schema["required"]; exists {
+		length, _ := sliceLen(required)
+		for i := 0; i < length; i++ {
+			name := sliceItem(required, i).(string)
+			if _, exists := object[name]; !exists {
+				v.fail(childKeypath(keypath, name), "required", "is required")
+			}
+		}
+	}
+
+	properties, _ := asMap(schema["properties"])
+	additional, hasAdditional := schema["additionalProperties"]
+
+	for _, k := range sortedKeys(object) {
+		if property, exists := properties[k]; exists {
+			v.validate(childKeypath(keypath, k), object[k], property, 0)
+		} else if hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				v.fail(childKeypath(keypath, k), "additionalProperties", "is not allowed")
+				continue
+			}
+			v.validate(childKeypath(keypath, k), object[k], additional, 0)
+		}
+	}
+
+}
+
+/*
+	From structs
+	------------------------------------------------
+*/
+
+// NewSchemaFromStruct creates a Schema describing the struct (or pointer to
+// a struct) that v holds, so that maps can be checked before they are given
+// to Decode.
+//
+// Keys follow the same rules as Decode, and constraints are read from the
+// SchemaTagName struct tag.  Pointers may also be null.
+func NewSchemaFromStruct(v interface{}) (*Schema, error) {
+
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Map: NewSchemaFromStruct needs a struct, not %T.", v)
+	}
+
+	g := &schemaGenerator{root: t, defs: make(Map), inProgress: make(map[reflect.Type]bool), needed: make(map[reflect.Type]bool)}
+
+	schema, err := g.structSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+
+	return NewSchema(schema)
+}
+
+// schemaGenerator builds schemas for Go types.  Structs that contain
+// themselves are put in $defs, and referred to with $ref.
+type schemaGenerator struct {
+	root       reflect.Type
+	defs       Map
+	inProgress map[reflect.Type]bool
+	needed     map[reflect.Type]bool
+}
+
+// defRef gets the $ref for a struct type.
+func (g *schemaGenerator) defRef(t reflect.Type) Map {
+	if t == g.root {
+		return Map{"$ref": "#"}
+	}
+	return Map{"$ref": "#/$defs/" + t.Name()}
+}
+
+// structSchema builds the schema for a struct type.
+func (g *schemaGenerator) structSchema(t reflect.Type) (Map, error) {
+
+	if g.inProgress[t] {
+		g.needed[t] = true
+		return g.defRef(t), nil
+	}
+	if _, exists := g.defs[t.Name()]; exists && g.needed[t] {
+		return g.defRef(t), nil
+	}
+
+	g.inProgress[t] = true
+	defer delete(g.inProgress, t)
+
+	properties := make(Map)
+	var required []interface{}
+
+	for _, f := range bindFields(t) {
+
+		field := t.FieldByIndex(f.index)
+
+		fieldSchema, err := g.typeSchema(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("Map: Cannot make a schema for field %s.%s: %s", t.Name(), field.Name, err)
+		}
+
+		isRequired, err := applySchemaTag(fieldSchema, field.Type, field.Tag.Get(SchemaTagName))
+		if err != nil {
+			return nil, fmt.Errorf("Map: Cannot make a schema for field %s.%s: %s", t.Name(), field.Name, err)
+		}
+		if isRequired {
+			required = append(required, f.name)
+		}
+
+		properties[f.name] = fieldSchema
+	}
+
+	schema := Map{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	if g.needed[t] && t != g.root {
+		g.defs[t.Name()] = schema
+		return g.defRef(t), nil
+	}
+
+	return schema, nil
+}
+
+// typeSchema builds the schema for a Go type.
+func (g *schemaGenerator) typeSchema(t reflect.Type) (Map, error) {
+
+	switch {
+	case t == timeType:
+		return Map{"type": "string", "format": "date-time"}, nil
+	case t == durationType:
+		return Map{"type": []interface{}{"integer", "string"}}, nil
+	case t.Kind() != reflect.Ptr && t.Kind() != reflect.Struct && (t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)):
+		return Map{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Map{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Map{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Map{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return Map{"type": "number"}, nil
+	case reflect.String:
+		return Map{"type": "string"}, nil
+	case reflect.Interface:
+		return Map{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Map{"type": "string"}, nil
+		}
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Map{"type": "array", "items": items}, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return nil, errors.New("maps must have string or integer keys")
+		}
+		values, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Map{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	case reflect.Ptr:
+		elem, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := elem["type"].(string); ok {
+			elem["type"] = []interface{}{name, "null"}
+			return elem, nil
+		}
+		return Map{"anyOf": []interface{}{elem, Map{"type": "null"}}}, nil
+	}
+
+	return nil, errors.New(t.String() + " cannot be represented in a Map")
+}
+
+// applySchemaTag adds the constraints in the tag to the schema, and gets
+// whether the field is required.
+func applySchemaTag(schema Map, t reflect.Type, tag string) (bool, error) {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	required := false
+
+	for tag != "" {
+
+		var option string
+		if strings.HasPrefix(tag, "pattern=") {
+			option, tag = tag, ""
+		} else if comma := strings.Index(tag, ","); comma != -1 {
+			option, tag = tag[:comma], tag[comma+1:]
+		} else {
+			option, tag = tag, ""
+		}
+
+		name, value := option, ""
+		if equals := strings.Index(option, "="); equals != -1 {
+			name, value = option[:equals], option[equals+1:]
+		}
+
+		switch name {
+		case "":
+		case "required":
+			required = true
+		case "pattern", "format":
+			schema[name] = value
+		case "enum":
+			var values []interface{}
+			for _, item := range strings.Split(value, "|") {
+				parsed, err := parseSchemaTagValue(item, t)
+				if err != nil {
+					return false, err
+				}
+				values = append(values, parsed)
+			}
+			schema["enum"] = values
+		case "min", "max":
+			keyword := map[reflect.Kind]string{reflect.String: "Length", reflect.Slice: "Items", reflect.Array: "Items", reflect.Map: "Properties"}[t.Kind()]
+			if keyword == "" {
+				keyword = map[string]string{"min": "minimum", "max": "maximum"}[name]
+			} else {
+				keyword = name + keyword
+			}
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, errors.New("'" + option + "' must be a number")
+			}
+			schema[keyword] = n
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "minLength", "maxLength",
+			"minItems", "maxItems", "minProperties", "maxProperties":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, errors.New("'" + option + "' must be a number")
+			}
+			schema[name] = n
+		default:
+			return false, errors.New("unknown schema constraint '" + name + "'")
+		}
+
+	}
+
+	return required, nil
+}
+
+// parseSchemaTagValue parses an enum value in a struct tag for a field of
+// the type.
+func parseSchemaTagValue(value string, t reflect.Type) (interface{}, error) {
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	}
+
+	return value, nil
+}
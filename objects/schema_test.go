@@ -0,0 +1,314 @@
+package objects
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var schemaTestSchema = M(
+	"type", "object",
+	"required", []interface{}{"name", "email"},
+	"additionalProperties", false,
+	"properties", M(
+		"name", M("type", "string", "minLength", 2, "maxLength", 5),
+		"email", M("type", "string", "format", "email"),
+		"age", M("type", "integer", "minimum", 0, "exclusiveMaximum", 150),
+		"role", M("enum", []interface{}{"admin", "user"}),
+		"code", M("type", "string", "pattern", "^[A-Z]{3}$"),
+		"score", M("type", []interface{}{"number", "null"}),
+		"tags", M("type", "array", "maxItems", 2, "items", M("type", "string")),
+		"address", M("$ref", "#/$defs/address"),
+	),
+	"$defs", M(
+		"address", M("type", "object", "required", []interface{}{"city"},
+			"properties", M("city", M("type", "string"))),
+	),
+)
+
+// violationKeypaths gets the keypath and keyword of each violation in the error.
+func violationKeypaths(err error) []string {
+	var paths []string
+	if validationErr, ok := err.(*ValidationError); ok {
+		for _, violation := range validationErr.Violations {
+			paths = append(paths, violation.Keypath+" "+violation.Keyword)
+		}
+	}
+	return paths
+}
+
+func TestSchemaValidate(t *testing.T) {
+
+	schema, err := NewSchema(schemaTestSchema)
+	if assert.NoError(t, err) {
+
+		assert.NoError(t, schema.Validate(M("name", "Mat", "email", "mat@example.com")))
+		assert.NoError(t, M("name", "Mat", "email", "mat@example.com", "age", json.Number("42"),
+			"role", "admin", "code", "ABC", "score", nil, "tags", []string{"a"},
+			"address", map[string]interface{}{"city": "Boulder"}).Validate(schema))
+
+		err = schema.Validate(M(
+			"name", "M",
+			"age", 150.5,
+			"role", "owner",
+			"code", "abcd",
+			"score", "high",
+			"tags", []interface{}{"a", 1, "c"},
+			"address", M("zip", 80301),
+			"extra", true,
+		))
+
+		if assert.Error(t, err) {
+			assert.Equal(t, []string{
+				"email required",
+				"address.city required",
+				"age type",
+				"age exclusiveMaximum",
+				"code pattern",
+				"extra additionalProperties",
+				"name minLength",
+				"role enum",
+				"score type",
+				"tags maxItems",
+				"tags[1] type",
+			}, violationKeypaths(err))
+			assert.Contains(t, err.Error(), "Map: Validation failed for 11 value(s): 'email': is required; ")
+			assert.Contains(t, err.Error(), "'age': expected integer but found number")
+		}
+
+	}
+
+}
+
+func TestSchemaValidate_Formats(t *testing.T) {
+
+	valid := map[string][]string{
+		"date-time": {"2013-02-03T19:54:00Z", "2013-02-03T19:54:00.123+01:00"},
+		"date":      {"2013-02-03"},
+		"time":      {"19:54:00Z", "19:54:00.5-07:00"},
+		"email":     {"mat@example.com"},
+		"hostname":  {"example.com", "localhost"},
+		"ipv4":      {"192.168.0.1"},
+		"ipv6":      {"::1", "2001:db8::8a2e:370:7334"},
+		"uri":       {"https://example.com/path?q=1", "urn:isbn:0451450523"},
+		"uuid":      {"123e4567-e89b-12d3-a456-426614174000"},
+		"unknown":   {"anything"},
+	}
+	invalid := map[string][]string{
+		"date-time": {"2013-02-03", "yesterday"},
+		"date":      {"2013-02-30", "03/02/2013"},
+		"time":      {"19:54"},
+		"email":     {"Mat <mat@example.com>", "mat"},
+		"hostname":  {"-bad-.com", "a b"},
+		"ipv4":      {"256.0.0.1", "::1"},
+		"ipv6":      {"192.168.0.1", "nope"},
+		"uri":       {"/relative/path", "%%"},
+		"uuid":      {"123e4567e89b12d3a456426614174000"},
+	}
+
+	for format, values := range valid {
+		schema, _ := NewSchema(M("properties", M("v", M("format", format))))
+		for _, value := range values {
+			assert.NoError(t, schema.Validate(M("v", value)), format+" "+value)
+		}
+	}
+	for format, values := range invalid {
+		schema, _ := NewSchema(M("properties", M("v", M("format", format))))
+		for _, value := range values {
+			assert.Equal(t, []string{"v format"}, violationKeypaths(schema.Validate(M("v", value))), format+" "+value)
+		}
+	}
+
+	// formats only apply to strings
+	schema, _ := NewSchema(M("properties", M("v", M("format", "email"))))
+	assert.NoError(t, schema.Validate(M("v", 1)))
+
+}
+
+func TestSchemaValidate_Refs(t *testing.T) {
+
+	// a tree that refers to itself
+	schema, err := NewSchema(M(
+		"type", "object",
+		"required", []interface{}{"name"},
+		"properties", M(
+			"name", M("type", "string"),
+			"children", M("type", "array", "items", M("$ref", "#")),
+		),
+	))
+
+	if assert.NoError(t, err) {
+		assert.NoError(t, schema.Validate(M("name", "a", "children", []interface{}{
+			M("name", "b", "children", []interface{}{M("name", "c")}),
+		})))
+		assert.Equal(t, []string{"children[0].children[1].name required"}, violationKeypaths(schema.Validate(M("name", "a", "children", []interface{}{
+			M("name", "b", "children", []interface{}{M("name", "c"), M()}),
+		}))))
+	}
+
+	// refs that only refer to each other give up rather than looping
+	schema, err = NewSchema(M("$defs", M("a", M("$ref", "#/$defs/b"), "b", M("$ref", "#/$defs/a")), "$ref", "#/$defs/a"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{" $ref"}, violationKeypaths(schema.Validate(M())))
+	}
+
+	// refs to schemas outside the usual keywords are compiled too
+	schema, err = NewSchema(M("x-shared", M("pattern", "^a"), "properties", M("n", M("$ref", "#/x-shared"))))
+	if assert.NoError(t, err) {
+		assert.NoError(t, schema.Validate(M("n", "abc")))
+		assert.Equal(t, []string{"n pattern"}, violationKeypaths(schema.Validate(M("n", "b"))))
+	}
+	_, err = NewSchema(M("x-shared", M("pattern", "("), "properties", M("n", M("$ref", "#/x-shared"))))
+	assert.Error(t, err)
+
+	// boolean schemas
+	schema, _ = NewSchema(M("properties", M("yes", true, "no", false)))
+	assert.Equal(t, []string{"no false"}, violationKeypaths(schema.Validate(M("yes", 1, "no", 2))))
+
+}
+
+func TestNewSchema_Errors(t *testing.T) {
+
+	for _, schema := range []Map{
+		M("type", "text"),
+		M("type", 1),
+		M("pattern", "(unclosed"),
+		M("$ref", "#/$defs/missing"),
+		M("$ref", "http://example.com/schema.json"),
+		M("required", []interface{}{1}),
+		M("minLength", "two"),
+		M("enum", "a"),
+		M("properties", M("name", "string")),
+		M("items", M("properties", M("x", M("type", "nope")))),
+	} {
+		_, err := NewSchema(schema)
+		assert.Error(t, err, "%v", schema)
+	}
+
+	_, err := NewSchema(M("properties", M("name", M("pattern", "("))))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Map: Schema is invalid at '/properties/name/pattern': ")
+	}
+
+}
+
+type schemaTestAddress struct {
+	City string `json:"city" schema:"required,min=1"`
+}
+
+type schemaTestUser struct {
+	Name     string             `json:"name" schema:"required,min=2,max=5"`
+	Email    string             `json:"email" schema:"required,format=email"`
+	Role     string             `schema:"enum=admin|user"`
+	Age      uint8              `json:"age,omitempty" schema:"max=150"`
+	Code     string             `stew:"code" schema:"pattern=^[A-Z]{2,3}$"`
+	Tags     []string           `schema:"max=2"`
+	Born     time.Time          `json:"born"`
+	Address  *schemaTestAddress `json:"address"`
+	Scores   map[string]float64 `json:"scores"`
+	Extra    interface{}        `json:"extra"`
+	Private  string             `json:"-"`
+	internal string
+}
+
+type schemaTestNode struct {
+	Name     string            `json:"name" schema:"required"`
+	Children []*schemaTestNode `json:"children"`
+	Next     *schemaTestLink   `json:"next"`
+}
+
+type schemaTestLink struct {
+	Weight int             `json:"weight"`
+	Node   *schemaTestNode `json:"node"`
+	Back   *schemaTestLink `json:"back"`
+}
+
+func TestNewSchemaFromStruct(t *testing.T) {
+
+	schema, err := NewSchemaFromStruct(&schemaTestUser{})
+	if assert.NoError(t, err) {
+
+		properties := schema.Map().GetMap("properties")
+		assert.Equal(t, []interface{}{"name", "email"}, schema.Map()["required"])
+		assert.Equal(t, M("type", "string", "minLength", 2.0, "maxLength", 5.0), properties["name"])
+		assert.Equal(t, M("type", "string", "enum", []interface{}{"admin", "user"}), properties["Role"])
+		assert.Equal(t, M("type", "integer", "minimum", 0, "maximum", 150.0), properties["age"])
+		assert.Equal(t, M("type", "array", "items", M("type", "string"), "maxItems", 2.0), properties["Tags"])
+		assert.Equal(t, M("type", "string", "format", "date-time"), properties["born"])
+		assert.Equal(t, []interface{}{"object", "null"}, properties.Get("address.type"))
+		assert.Equal(t, M("type", "object", "additionalProperties", M("type", "number")), properties["scores"])
+		assert.Equal(t, M(), properties["extra"])
+		assert.Equal(t, "^[A-Z]{2,3}$", properties.Get("code.pattern"))
+		assert.Nil(t, properties["Private"])
+		assert.Nil(t, properties["internal"])
+
+		assert.NoError(t, schema.Validate(M("name", "Mat", "email", "mat@example.com", "Role", "user",
+			"age", 40, "code", "AB", "born", "2013-02-03T19:54:00Z", "address", nil, "scores", M("a", 1.5))))
+
+		assert.Equal(t, []string{"email required", "Role enum", "address.city required", "age maximum", "name maxLength"},
+			violationKeypaths(schema.Validate(M("name", "Mathew", "Role", "owner", "age", 151, "address", M()))))
+
+	}
+
+	// values that pass the schema decode
+	var user schemaTestUser
+	m := M("name", "Mat", "email", "mat@example.com", "age", 40, "address", M("city", "Boulder"))
+	if assert.NoError(t, m.Validate(schema)) && assert.NoError(t, m.Decode(&user)) {
+		assert.Equal(t, "Boulder", user.Address.City)
+	}
+
+}
+
+func TestNewSchemaFromStruct_Recursive(t *testing.T) {
+
+	schema, err := NewSchemaFromStruct(schemaTestNode{})
+	if assert.NoError(t, err) {
+
+		assert.Equal(t, M("anyOf", []interface{}{M("$ref", "#"), M("type", "null")}), schema.Map().Get("properties.children.items"))
+		assert.Equal(t, M("$ref", "#/$defs/schemaTestLink"), schema.Map().Get("properties.next.anyOf[0]"))
+		assert.NotNil(t, schema.Map().Get("$defs.schemaTestLink.properties.back"))
+
+		assert.NoError(t, schema.Validate(M("name", "a",
+			"children", []interface{}{M("name", "b"), nil},
+			"next", M("weight", 1, "node", M("name", "c"), "back", M("weight", 2)))))
+
+		assert.Equal(t, []string{"children[0] anyOf", "next anyOf"}, violationKeypaths(schema.Validate(M("name", "a",
+			"children", []interface{}{M()},
+			"next", M("weight", "heavy")))))
+
+	}
+
+}
+
+func TestNewSchemaFromStruct_Errors(t *testing.T) {
+
+	_, err := NewSchemaFromStruct("nope")
+	assert.Error(t, err)
+
+	_, err = NewSchemaFromStruct(nil)
+	assert.Error(t, err)
+
+	_, err = NewSchemaFromStruct(struct {
+		F func()
+	}{})
+	assert.Error(t, err)
+
+	_, err = NewSchemaFromStruct(struct {
+		N int `schema:"min=few"`
+	}{})
+	assert.Error(t, err)
+
+	_, err = NewSchemaFromStruct(struct {
+		N int `schema:"unique"`
+	}{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown schema constraint 'unique'")
+	}
+
+	_, err = NewSchemaFromStruct(struct {
+		S string `schema:"pattern=("`
+	}{})
+	assert.Error(t, err)
+
+}
@@ -0,0 +1,165 @@
+package objects
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// SealCipher is the authenticated encryption algorithm used by Seal.
+type SealCipher byte
+
+const (
+	// SealAESGCM encrypts with AES-GCM, using a 16, 24 or 32 byte key.
+	SealAESGCM SealCipher = 1
+	// SealXChaCha20Poly1305 encrypts with XChaCha20-Poly1305, using a 32 byte key.
+	SealXChaCha20Poly1305 SealCipher = 2
+)
+
+// sealVersion is the version of the sealed format written by Seal.
+const sealVersion byte = 1
+
+var (
+	// ErrSealedMalformed is returned when sealed data cannot be read.
+	ErrSealedMalformed = errors.New("Map: Sealed data is malformed.")
+	// ErrSealedAuthentication is returned when sealed data has been changed,
+	// was sealed with a key that is not in the KeyRing, or the additional data
+	// does not match.
+	ErrSealedAuthentication = errors.New("Map: Sealed data could not be authenticated.")
+)
+
+// SealOptions controls how Seal encrypts a Map.
+type SealOptions struct {
+	// Cipher is the encryption algorithm, SealAESGCM if not set.
+	Cipher SealCipher
+	// AdditionalData, if set, is authenticated but not stored, so the same
+	// data must be given to OpenSealed.  Use it to bind the sealed data to
+	// its context, like a user id or cookie name.
+	AdditionalData []byte
+}
+
+// sealAEAD makes the cipher.AEAD for the cipher and key.
+func sealAEAD(sealCipher SealCipher, key []byte) (cipher.AEAD, error) {
+
+	switch sealCipher {
+	case SealAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case SealXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+
+	return nil, errors.New("Map: Unknown seal cipher.")
+}
+
+// sealHeader makes the header that starts sealed data.
+func sealHeader(sealCipher SealCipher, keyID string) []byte {
+	header := []byte{sealVersion, byte(sealCipher), byte(len(keyID))}
+	return append(header, keyID...)
+}
+
+// Seal encrypts the map with the current key from the KeyRing, and returns it
+// as a URL-safe base64 string.  Use OpenSealed to get the Map back.
+//
+// Unlike SignedBase64 and SignedToken, the contents cannot be read by anyone
+// without the key.
+//
+// For example:
+//
+//     sealed, err := m.Seal(keys, objects.SealOptions{AdditionalData: []byte(userID)})
+func (d Map) Seal(keys *KeyRing, options SealOptions) (string, error) {
+
+	if options.Cipher == 0 {
+		options.Cipher = SealAESGCM
+	}
+
+	keyID, key, err := keys.currentKey()
+	if err != nil {
+		return "", err
+	}
+	if len(keyID) > 255 {
+		return "", errors.New("Map: KeyRing key ids must be less than 256 bytes to seal.")
+	}
+
+	aead, err := sealAEAD(options.Cipher, key)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := d.JSON()
+	if err != nil {
+		return "", err
+	}
+
+	header := sealHeader(options.Cipher, keyID)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := append(header, nonce...)
+	sealed = aead.Seal(sealed, nonce, []byte(payload), append(header[:len(header):len(header)], options.AdditionalData...))
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSealed decrypts data made by Seal using the matching key from the KeyRing,
+// and creates a new map from it.  The additionalData must be the same as was given
+// to Seal.
+//
+// The error is ErrSealedMalformed or ErrSealedAuthentication if the data cannot
+// be opened.
+func OpenSealed(data string, keys *KeyRing, additionalData []byte) (Map, error) {
+
+	sealed, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil || len(sealed) < 3 || sealed[0] != sealVersion {
+		return nil, ErrSealedMalformed
+	}
+
+	headerLen := 3 + int(sealed[2])
+	if len(sealed) < headerLen {
+		return nil, ErrSealedMalformed
+	}
+
+	header := sealed[:headerLen]
+	sealCipher := SealCipher(header[1])
+	if sealCipher != SealAESGCM && sealCipher != SealXChaCha20Poly1305 {
+		return nil, ErrSealedMalformed
+	}
+
+	key, ok := keys.Keys[string(header[3:])]
+	if !ok {
+		return nil, ErrSealedAuthentication
+	}
+
+	aead, err := sealAEAD(sealCipher, key)
+	if err != nil {
+		return nil, ErrSealedAuthentication
+	}
+
+	rest := sealed[headerLen:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrSealedMalformed
+	}
+
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	payload, err := aead.Open(nil, nonce, ciphertext, append(header[:len(header):len(header)], additionalData...))
+	if err != nil {
+		return nil, ErrSealedAuthentication
+	}
+
+	m, err := NewMapFromJSON(string(payload))
+	if err != nil {
+		return nil, ErrSealedMalformed
+	}
+
+	return m, nil
+}
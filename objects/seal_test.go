@@ -0,0 +1,118 @@
+package objects
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var sealTestKeys = &KeyRing{
+	Current: "new",
+	Keys: map[string][]byte{
+		"old": []byte("e1zJJGjCJfLAR1b4dDqg0PY33731D8gM"),
+		"new": []byte("Qv3Ygz9hW1Tq7uKcPdrNbX2LfE0sAmJo"),
+	},
+}
+
+func TestSeal(t *testing.T) {
+
+	m := M("name", "tyler", "secret", "s3cr3t")
+
+	for _, sealCipher := range []SealCipher{0, SealAESGCM, SealXChaCha20Poly1305} {
+
+		sealed, err := m.Seal(sealTestKeys, SealOptions{Cipher: sealCipher})
+		if assert.NoError(t, err) {
+
+			raw, _ := base64.RawURLEncoding.DecodeString(sealed)
+			assert.NotContains(t, string(raw), "s3cr3t")
+
+			opened, err := OpenSealed(sealed, sealTestKeys, nil)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "tyler", opened.Get("name"))
+				assert.Equal(t, "s3cr3t", opened.Get("secret"))
+			}
+
+		}
+
+	}
+
+	// every seal is different
+	first, _ := m.Seal(sealTestKeys, SealOptions{})
+	second, _ := m.Seal(sealTestKeys, SealOptions{})
+	assert.NotEqual(t, first, second)
+
+	_, err := m.Seal(sealTestKeys, SealOptions{Cipher: 9})
+	assert.Error(t, err)
+
+	_, err = m.Seal(&KeyRing{Current: "short", Keys: map[string][]byte{"short": []byte("short")}}, SealOptions{})
+	assert.Error(t, err)
+
+	_, err = m.Seal(&KeyRing{Current: "missing"}, SealOptions{})
+	assert.Error(t, err)
+
+}
+
+func TestOpenSealed_AdditionalData(t *testing.T) {
+
+	sealed, err := M("name", "tyler").Seal(sealTestKeys, SealOptions{AdditionalData: []byte("user:1")})
+	if assert.NoError(t, err) {
+
+		_, err = OpenSealed(sealed, sealTestKeys, []byte("user:1"))
+		assert.NoError(t, err)
+
+		_, err = OpenSealed(sealed, sealTestKeys, []byte("user:2"))
+		assert.Equal(t, ErrSealedAuthentication, err)
+
+		_, err = OpenSealed(sealed, sealTestKeys, nil)
+		assert.Equal(t, ErrSealedAuthentication, err)
+
+	}
+
+}
+
+func TestOpenSealed_Rotation(t *testing.T) {
+
+	sealed, err := M("name", "tyler").Seal(&KeyRing{Current: "old", Keys: sealTestKeys.Keys}, SealOptions{})
+	if assert.NoError(t, err) {
+
+		m, err := OpenSealed(sealed, sealTestKeys, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "tyler", m.Get("name"))
+		}
+
+		_, err = OpenSealed(sealed, &KeyRing{Current: "new", Keys: map[string][]byte{"new": sealTestKeys.Keys["new"]}}, nil)
+		assert.Equal(t, ErrSealedAuthentication, err)
+
+	}
+
+}
+
+func TestOpenSealed_Errors(t *testing.T) {
+
+	sealed, _ := M("name", "tyler").Seal(sealTestKeys, SealOptions{})
+	raw, _ := base64.RawURLEncoding.DecodeString(sealed)
+
+	// tampered ciphertext
+	tampered := append([]byte{}, raw...)
+	tampered[len(tampered)-1] ^= 1
+	_, err := OpenSealed(base64.RawURLEncoding.EncodeToString(tampered), sealTestKeys, nil)
+	assert.Equal(t, ErrSealedAuthentication, err)
+
+	// tampered key id, pointing at the other key
+	tampered = append([]byte{}, raw...)
+	copy(tampered[3:], "old")
+	_, err = OpenSealed(base64.RawURLEncoding.EncodeToString(tampered), sealTestKeys, nil)
+	assert.Equal(t, ErrSealedAuthentication, err)
+
+	// switched cipher
+	tampered = append([]byte{}, raw...)
+	tampered[1] = byte(SealXChaCha20Poly1305)
+	_, err = OpenSealed(base64.RawURLEncoding.EncodeToString(tampered), sealTestKeys, nil)
+	assert.Error(t, err)
+
+	for _, malformed := range []string{"", "!!!", base64.RawURLEncoding.EncodeToString([]byte{9, 1, 0}), base64.RawURLEncoding.EncodeToString(raw[:10]), base64.RawURLEncoding.EncodeToString([]byte{1, 7, 0, 1, 2})} {
+		_, err = OpenSealed(malformed, sealTestKeys, nil)
+		assert.Equal(t, ErrSealedMalformed, err, malformed)
+	}
+
+}
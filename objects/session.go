@@ -0,0 +1,555 @@
+package objects
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSessionCookieName is the name of the session cookie if
+	// SessionOptions.Name is not set.
+	DefaultSessionCookieName string = "session"
+	// DefaultSessionMaxCookies is the most cookies a session may be split
+	// across if SessionOptions.MaxCookies is not set.
+	DefaultSessionMaxCookies int = 10
+)
+
+// sessionCookieBytes is the most a single session cookie may hold, counting
+// its name and value.  Browsers accept at least 4096 bytes per cookie, less
+// room for the attributes.
+const sessionCookieBytes int = 4000
+
+// sessionChunkSeparator separates the number of cookies from the first part
+// of a session that is split across more than one.
+const sessionChunkSeparator string = ":"
+
+var (
+	// ErrSessionMalformed is returned when a session cookie cannot be read, or
+	// some of the cookies it was split across are missing.
+	ErrSessionMalformed = errors.New("Map: Session cookie is malformed.")
+	// ErrSessionExpired is returned when a session cookie is older than
+	// SessionOptions.MaxAge.
+	ErrSessionExpired = errors.New("Map: Session has expired.")
+)
+
+// SessionOptions controls how a SessionStore reads and writes sessions.
+type SessionOptions struct {
+	// Name is the name of the cookie; DefaultSessionCookieName if not set.
+	// Sessions too big for one cookie also use Name_1, Name_2 and so on.
+	Name string
+	// Keys signs, or encrypts, the session.  The current key is used to
+	// write sessions, and any key in the ring is accepted when they are read.
+	Keys *KeyRing
+	// Encrypt seals the session with Seal, so that the browser cannot read
+	// it.  Otherwise it is signed with SignedToken, which stops it being
+	// changed but not read.
+	Encrypt bool
+	// Cipher is the cipher used if Encrypt is set, SealAESGCM if not set.
+	Cipher SealCipher
+	// MaxAge is how long a session lasts after it was last saved.  If it is
+	// not set, the cookie lasts until the browser is closed and the session
+	// never expires.
+	MaxAge time.Duration
+	// MaxCookies is the most cookies a session may be split across, to stay
+	// below the browser's limit for the domain.  If it is not set,
+	// DefaultSessionMaxCookies is used.
+	MaxCookies int
+	// Path and Domain are the scope of the cookie; Path is "/" if not set.
+	Path   string
+	Domain string
+	// Secure only sends the cookie over HTTPS.
+	Secure bool
+	// SameSite is the cookie's SameSite attribute.
+	SameSite http.SameSite
+	// AllowScripts lets JavaScript read the cookie.  Session cookies are
+	// HttpOnly unless it is set.
+	AllowScripts bool
+	// OnSaveError is called by Middleware if the session could not be saved.
+	// The response carries on without the cookie.
+	OnSaveError func(r *http.Request, err error)
+}
+
+/*
+	Session
+	------------------------------------------------
+*/
+
+// Session holds the values for one visitor, kept in a cookie by a
+// SessionStore between requests.
+//
+// Values are set and got with keypaths, as with Map.  They are stored as
+// JSON, so when the session is next loaded numbers come back as float64 and
+// structs as Maps.
+//
+// The session remembers whether it has been changed, and it is only written
+// back to the cookie if it has.  A Session is safe to use from many
+// goroutines at once.
+type Session struct {
+	mutex   sync.Mutex
+	values  Map
+	flashes []interface{}
+	dirty   bool
+	isNew   bool
+	// cookies is the number of cookies the session was read from.
+	cookies int
+}
+
+// newSession creates an empty session.
+func newSession() *Session {
+	return &Session{values: make(Map), isNew: true}
+}
+
+// Get gets a copy of the value at the keypath, or nil if there isn't one.
+// See Map.Get for the keypaths supported.  Use Set to change values, so the
+// session knows it needs saving.
+func (s *Session) Get(keypath string) interface{} {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return deepCopyValue(s.values.Get(keypath))
+}
+
+// Has gets whether there is a value at the keypath.
+func (s *Session) Has(keypath string) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.values.Has(keypath)
+}
+
+// Set sets a copy of the value at the keypath, and returns the session.
+// Setting a value equal to the one already there does not change the
+// session.
+func (s *Session) Set(keypath string, value interface{}) *Session {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.values.Has(keypath) || !valuesEqual(s.values.Get(keypath), value) {
+		s.values.Set(keypath, deepCopyValue(value))
+		s.dirty = true
+	}
+
+	// chain
+	return s
+}
+
+// Delete removes the value at the keypath, and returns the session.
+func (s *Session) Delete(keypath string) *Session {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.values.Has(keypath) {
+		s.values.Delete(keypath)
+		s.dirty = true
+	}
+
+	// chain
+	return s
+}
+
+// Clear removes every value and flash from the session, so that saving it
+// deletes the cookie.  Use it when logging out.
+func (s *Session) Clear() *Session {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.values) > 0 || len(s.flashes) > 0 {
+		s.values = make(Map)
+		s.flashes = nil
+		s.dirty = true
+	}
+
+	// chain
+	return s
+}
+
+// Values gets a copy of all the values in the session.
+func (s *Session) Values() Map {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.values.DeepCopy()
+}
+
+// AddFlash adds a value that is kept only until it is read with Flashes,
+// like a message to show on the next page.
+//
+// For example:
+//
+//     session.AddFlash("Your changes were saved.")
+//     http.Redirect(w, r, "/settings", http.StatusSeeOther)
+func (s *Session) AddFlash(value interface{}) *Session {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.flashes = append(s.flashes, deepCopyValue(value))
+	s.dirty = true
+
+	// chain
+	return s
+}
+
+// Flashes gets the values added with AddFlash, in the order they were added,
+// and removes them from the session.
+func (s *Session) Flashes() []interface{} {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	flashes := s.flashes
+	if len(flashes) > 0 {
+		s.flashes = nil
+		s.dirty = true
+	}
+
+	return flashes
+}
+
+// IsNew gets whether the session was just created, rather than read from a
+// cookie.
+func (s *Session) IsNew() bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.isNew
+}
+
+// IsDirty gets whether the session has changed since it was loaded or last
+// saved.
+func (s *Session) IsDirty() bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.dirty
+}
+
+/*
+	SessionStore
+	------------------------------------------------
+*/
+
+// SessionStore reads sessions from, and writes them to, cookies.
+//
+// For example:
+//
+//     store, err := objects.NewSessionStore(objects.SessionOptions{
+//         Keys:   keys,
+//         MaxAge: 14 * 24 * time.Hour,
+//         Secure: true,
+//     })
+//     ...
+//     http.ListenAndServe(":8080", store.Middleware(mux))
+//
+// Handlers then use SessionFromRequest:
+//
+//     func Login(w http.ResponseWriter, r *http.Request) {
+//         session := objects.SessionFromRequest(r)
+//         session.Set("user.id", user.ID)
+//         session.AddFlash("Welcome back.")
+//         ...
+//     }
+type SessionStore struct {
+	options SessionOptions
+}
+
+// NewSessionStore creates a SessionStore, checking the KeyRing has a current
+// key to write sessions with.
+func NewSessionStore(options SessionOptions) (*SessionStore, error) {
+
+	if options.Keys == nil {
+		return nil, errors.New("Map: SessionOptions needs a KeyRing.")
+	}
+	if _, _, err := options.Keys.currentKey(); err != nil {
+		return nil, err
+	}
+
+	if options.Name == "" {
+		options.Name = DefaultSessionCookieName
+	}
+	if options.Path == "" {
+		options.Path = "/"
+	}
+	if options.MaxCookies <= 0 {
+		options.MaxCookies = DefaultSessionMaxCookies
+	}
+
+	return &SessionStore{options: options}, nil
+}
+
+// chunkName gets the name of the cookie holding part i of the session.
+func (s *SessionStore) chunkName(i int) string {
+	if i == 0 {
+		return s.options.Name
+	}
+	return s.options.Name + "_" + strconv.Itoa(i)
+}
+
+// Load reads the session from the request's cookies.  If there is no session
+// cookie, a new, empty session is returned.
+//
+// If the cookie is there but cannot be used, because it has been changed,
+// was written with a key no longer in the KeyRing or has expired, a new
+// session is returned along with the error.  That session is already dirty,
+// so saving it deletes the bad cookie.
+func (s *SessionStore) Load(r *http.Request) (*Session, error) {
+
+	session := newSession()
+
+	cookie, err := r.Cookie(s.options.Name)
+	if err != nil {
+		return session, nil
+	}
+
+	// how many cookies there were matters even if they can't be read, so
+	// that they can all be deleted
+	data, count := cookie.Value, 1
+	if i := strings.Index(data, sessionChunkSeparator); i != -1 {
+		if count, err = strconv.Atoi(data[:i]); err != nil || count < 2 || count > s.options.MaxCookies {
+			session.cookies = 1
+			session.dirty = true
+			return session, ErrSessionMalformed
+		}
+		data = data[i+1:]
+	}
+	session.cookies = count
+
+	var value strings.Builder
+	value.WriteString(data)
+	for i := 1; i < count; i++ {
+		chunk, err := r.Cookie(s.chunkName(i))
+		if err != nil {
+			session.dirty = true
+			return session, ErrSessionMalformed
+		}
+		value.WriteString(chunk.Value)
+	}
+
+	payload, err := s.decode(value.String())
+	if err != nil {
+		session.dirty = true
+		return session, err
+	}
+
+	if expires, ok := payload.Get("exp").(float64); ok && time.Now().Unix() >= int64(expires) {
+		session.dirty = true
+		return session, ErrSessionExpired
+	}
+
+	if values, ok := payload.Get("v").(map[string]interface{}); ok {
+		session.values = values
+	}
+	if flashes, ok := payload.Get("f").([]interface{}); ok {
+		session.flashes = flashes
+	}
+	session.isNew = false
+
+	return session, nil
+}
+
+// decode checks the signature of, or opens, the cookie value.
+func (s *SessionStore) decode(value string) (Map, error) {
+
+	if s.options.Encrypt {
+		return OpenSealed(value, s.options.Keys, []byte(s.options.Name))
+	}
+
+	return NewMapFromSignedToken(value, s.options.Keys)
+}
+
+// encode signs, or seals, the payload.
+func (s *SessionStore) encode(payload Map) (string, error) {
+
+	if s.options.Encrypt {
+		return payload.Seal(s.options.Keys, SealOptions{Cipher: s.options.Cipher, AdditionalData: []byte(s.options.Name)})
+	}
+
+	return payload.SignedToken(s.options.Keys, TokenOptions{})
+}
+
+// cookie makes a cookie with the options of the store.
+func (s *SessionStore) cookie(name, value string, maxAge int) *http.Cookie {
+
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     s.options.Path,
+		Domain:   s.options.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.options.Secure,
+		HttpOnly: !s.options.AllowScripts,
+		SameSite: s.options.SameSite,
+	}
+	if maxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+	}
+
+	return cookie
+}
+
+// Save writes the session to the response's cookies, if it has changed.  It
+// must be called before the response's headers are written; Middleware does
+// this for you.
+//
+// A session with no values or flashes deletes its cookies.  Sessions too big
+// for one cookie are split across more, up to SessionOptions.MaxCookies, and
+// any cookies left over from a bigger session are deleted.
+func (s *SessionStore) Save(w http.ResponseWriter, session *Session) error {
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if !session.dirty {
+		return nil
+	}
+
+	var chunks []string
+
+	if len(session.values) > 0 || len(session.flashes) > 0 {
+
+		payload := Map{"v": session.values}
+		if len(session.flashes) > 0 {
+			payload["f"] = session.flashes
+		}
+		if s.options.MaxAge > 0 {
+			payload["exp"] = time.Now().Add(s.options.MaxAge).Unix()
+		}
+
+		value, err := s.encode(payload)
+		if err != nil {
+			return err
+		}
+
+		// leave room for the chunk's name and the count
+		size := sessionCookieBytes - len(s.chunkName(s.options.MaxCookies)) - len(strconv.Itoa(s.options.MaxCookies)) - len(sessionChunkSeparator)
+		for len(value) > size {
+			chunks, value = append(chunks, value[:size]), value[size:]
+		}
+		chunks = append(chunks, value)
+
+		if len(chunks) > s.options.MaxCookies {
+			return fmt.Errorf("Map: Session needs %d cookies, more than the limit of %d.", len(chunks), s.options.MaxCookies)
+		}
+		if len(chunks) > 1 {
+			chunks[0] = strconv.Itoa(len(chunks)) + sessionChunkSeparator + chunks[0]
+		}
+
+	}
+
+	maxAge := int(s.options.MaxAge / time.Second)
+	for i, chunk := range chunks {
+		http.SetCookie(w, s.cookie(s.chunkName(i), chunk, maxAge))
+	}
+	for i := len(chunks); i < session.cookies; i++ {
+		http.SetCookie(w, s.cookie(s.chunkName(i), "", -1))
+	}
+
+	session.cookies = len(chunks)
+	session.dirty = false
+
+	return nil
+}
+
+/*
+	Middleware
+	------------------------------------------------
+*/
+
+// sessionContextKey is the key of the session in a request's context.
+type sessionContextKey struct{}
+
+// SessionFromContext gets the session put in the context by Middleware, or
+// nil if there isn't one.
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionContextKey{}).(*Session)
+	return session
+}
+
+// SessionFromRequest gets the session for a request handled by Middleware,
+// or nil if there isn't one.
+func SessionFromRequest(r *http.Request) *Session {
+	return SessionFromContext(r.Context())
+}
+
+// Middleware loads the session for each request and makes it available to
+// the handler with SessionFromRequest.  If the handler changes the session,
+// it is saved just before the response's headers are written.
+//
+// Sessions whose cookies cannot be used are replaced with new ones.
+func (s *SessionStore) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		session, _ := s.Load(r)
+		r = r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session))
+
+		writer := &sessionWriter{ResponseWriter: w, store: s, session: session, request: r}
+		next.ServeHTTP(writer, r)
+
+		// the handler may not have written anything at all
+		writer.save()
+
+	})
+}
+
+// sessionWriter saves the session before the headers are written.
+type sessionWriter struct {
+	http.ResponseWriter
+	store   *SessionStore
+	session *Session
+	request *http.Request
+	saved   bool
+}
+
+// save saves the session, if it hasn't been already.
+func (w *sessionWriter) save() {
+
+	if w.saved {
+		return
+	}
+	w.saved = true
+
+	if err := w.store.Save(w.ResponseWriter, w.session); err != nil && w.store.options.OnSaveError != nil {
+		w.store.options.OnSaveError(w.request, err)
+	}
+
+}
+
+// WriteHeader saves the session, then writes the headers.
+func (w *sessionWriter) WriteHeader(status int) {
+	w.save()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write saves the session, then writes the body.
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	w.save()
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush saves the session, then flushes the response if the original
+// ResponseWriter can, so that streaming handlers still work.
+func (w *sessionWriter) Flush() {
+	w.save()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap gets the original ResponseWriter, for http.ResponseController.
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
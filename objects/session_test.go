@@ -0,0 +1,324 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSessionStore(t *testing.T, options SessionOptions) *SessionStore {
+	options.Keys = &KeyRing{Current: "b", Keys: map[string][]byte{
+		"a": []byte("0123456789abcdef0123456789abcdef"),
+		"b": []byte("fedcba9876543210fedcba9876543210"),
+	}}
+	store, err := NewSessionStore(options)
+	assert.NoError(t, err)
+	return store
+}
+
+// sessionRequest makes a request carrying the cookies set on the response.
+func sessionRequest(w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge >= 0 {
+			r.AddCookie(cookie)
+		}
+	}
+	return r
+}
+
+func TestSession(t *testing.T) {
+
+	session := newSession()
+	assert.True(t, session.IsNew())
+	assert.False(t, session.IsDirty())
+
+	session.Set("user.id", 42)
+	assert.True(t, session.IsDirty())
+	assert.Equal(t, 42, session.Get("user.id"))
+	assert.True(t, session.Has("user"))
+
+	session.dirty = false
+	session.Set("user.id", 42.0)
+	session.Delete("nothing")
+	assert.False(t, session.IsDirty(), "unchanged")
+
+	// values are copied
+	session.Get("user").(Map).Set("id", 1)
+	assert.Equal(t, 42, session.Get("user.id"))
+
+	session.AddFlash("saved").AddFlash(M("level", "warn"))
+	assert.Equal(t, []interface{}{"saved", M("level", "warn")}, session.Flashes())
+	assert.Nil(t, session.Flashes())
+
+	session.Delete("user.id")
+	assert.Equal(t, M("user", M()), session.Values())
+
+	session.dirty = false
+	session.Clear()
+	assert.True(t, session.IsDirty())
+	assert.Equal(t, M(), session.Values())
+
+}
+
+func TestSessionStore_RoundTrip(t *testing.T) {
+
+	for _, options := range []SessionOptions{{}, {Encrypt: true}, {Encrypt: true, Cipher: SealXChaCha20Poly1305}} {
+
+		store := testSessionStore(t, options)
+
+		session, err := store.Load(httptest.NewRequest("GET", "/", nil))
+		if !assert.NoError(t, err) {
+			return
+		}
+		session.Set("user.name", "mat").AddFlash("hello")
+
+		w := httptest.NewRecorder()
+		if !assert.NoError(t, store.Save(w, session)) {
+			return
+		}
+		assert.False(t, session.IsDirty())
+
+		cookies := w.Result().Cookies()
+		if assert.Equal(t, 1, len(cookies)) {
+			assert.Equal(t, "session", cookies[0].Name)
+			assert.Equal(t, "/", cookies[0].Path)
+			assert.True(t, cookies[0].HttpOnly)
+			assert.Equal(t, options.Encrypt, !strings.Contains(cookies[0].Value, "."))
+		}
+
+		session, err = store.Load(sessionRequest(w))
+		if assert.NoError(t, err) {
+			assert.False(t, session.IsNew())
+			assert.Equal(t, "mat", session.Get("user.name"))
+			assert.Equal(t, []interface{}{"hello"}, session.Flashes())
+		}
+
+		// clean sessions aren't written
+		session, _ = store.Load(sessionRequest(w))
+		w = httptest.NewRecorder()
+		assert.NoError(t, store.Save(w, session))
+		assert.Equal(t, 0, len(w.Result().Cookies()))
+
+		// empty sessions delete the cookie
+		session.Clear()
+		w = httptest.NewRecorder()
+		assert.NoError(t, store.Save(w, session))
+		if cookies := w.Result().Cookies(); assert.Equal(t, 1, len(cookies)) {
+			assert.Equal(t, -1, cookies[0].MaxAge)
+		}
+
+	}
+
+}
+
+func TestSessionStore_Invalid(t *testing.T) {
+
+	store := testSessionStore(t, SessionOptions{Name: "sid"})
+
+	session, _ := store.Load(httptest.NewRequest("GET", "/", nil))
+	session.Set("admin", false)
+	w := httptest.NewRecorder()
+	store.Save(w, session)
+	value := w.Result().Cookies()[0].Value
+	tampered := value[:len(value)-2] + "AA"
+	if tampered == value {
+		tampered = value[:len(value)-2] + "BA"
+	}
+
+	for cookie, expected := range map[string]error{
+		tampered:     ErrTokenBadSignature,
+		"garbage":    ErrTokenMalformed,
+		"3:garbage":  ErrSessionMalformed,
+		"99:garbage": ErrSessionMalformed,
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "sid", Value: cookie})
+		session, err := store.Load(r)
+		assert.Equal(t, expected, err, cookie)
+		assert.True(t, session.IsNew())
+		assert.True(t, session.IsDirty())
+		assert.Nil(t, session.Get("admin"))
+
+		// saving the new session deletes the bad cookies
+		w := httptest.NewRecorder()
+		assert.NoError(t, store.Save(w, session))
+		if cookies := w.Result().Cookies(); assert.NotEqual(t, 0, len(cookies), cookie) {
+			assert.Equal(t, "sid", cookies[0].Name)
+			for _, deleted := range cookies {
+				assert.Equal(t, -1, deleted.MaxAge, deleted.Name)
+			}
+		}
+	}
+
+	// sessions from another store are rejected
+	other := testSessionStore(t, SessionOptions{Name: "sid", Encrypt: true})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sid", Value: value})
+	_, err := other.Load(r)
+	assert.Equal(t, ErrSealedMalformed, err)
+
+	_, err = NewSessionStore(SessionOptions{})
+	assert.Error(t, err)
+	_, err = NewSessionStore(SessionOptions{Keys: &KeyRing{Current: "missing"}})
+	assert.Error(t, err)
+
+}
+
+func TestSessionStore_MaxAge(t *testing.T) {
+
+	store := testSessionStore(t, SessionOptions{MaxAge: time.Hour, Secure: true, SameSite: http.SameSiteLaxMode})
+
+	session, _ := store.Load(httptest.NewRequest("GET", "/", nil))
+	session.Set("a", 1)
+	w := httptest.NewRecorder()
+	assert.NoError(t, store.Save(w, session))
+
+	cookie := w.Result().Cookies()[0]
+	assert.Equal(t, 3600, cookie.MaxAge)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), cookie.Expires, 5*time.Second)
+
+	_, err := store.Load(sessionRequest(w))
+	assert.NoError(t, err)
+
+	expired, _ := store.encode(M("v", M("a", 2), "exp", time.Now().Add(-time.Minute).Unix()))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: expired})
+	session, err = store.Load(r)
+	assert.Equal(t, ErrSessionExpired, err)
+	assert.Nil(t, session.Get("a"))
+
+}
+
+func TestSessionStore_Chunks(t *testing.T) {
+
+	store := testSessionStore(t, SessionOptions{Encrypt: true})
+
+	session, _ := store.Load(httptest.NewRequest("GET", "/", nil))
+	session.Set("big", strings.Repeat("x", 10000))
+	w := httptest.NewRecorder()
+	if !assert.NoError(t, store.Save(w, session)) {
+		return
+	}
+
+	cookies := w.Result().Cookies()
+	if assert.Equal(t, 4, len(cookies)) {
+		assert.Equal(t, []string{"session", "session_1", "session_2", "session_3"}, []string{cookies[0].Name, cookies[1].Name, cookies[2].Name, cookies[3].Name})
+		assert.True(t, strings.HasPrefix(cookies[0].Value, "4:"))
+		for _, cookie := range cookies {
+			assert.True(t, len(cookie.Name)+len(cookie.Value) <= 4000)
+		}
+	}
+
+	session, err := store.Load(sessionRequest(w))
+	if assert.NoError(t, err) {
+		assert.Equal(t, strings.Repeat("x", 10000), session.Get("big"))
+	}
+
+	// shrinking the session deletes the cookies no longer needed
+	session.Set("big", "small")
+	w = httptest.NewRecorder()
+	assert.NoError(t, store.Save(w, session))
+	cookies = w.Result().Cookies()
+	if assert.Equal(t, 4, len(cookies)) {
+		assert.Equal(t, "session", cookies[0].Name)
+		assert.False(t, strings.Contains(cookies[0].Value, ":"))
+		for _, cookie := range cookies[1:] {
+			assert.Equal(t, -1, cookie.MaxAge, cookie.Name)
+		}
+	}
+
+	// missing chunks
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: "2:abc"})
+	_, err = store.Load(r)
+	assert.Equal(t, ErrSessionMalformed, err)
+
+	// too big
+	store.options.MaxCookies = 2
+	session.Set("big", strings.Repeat("x", 10000))
+	err = store.Save(httptest.NewRecorder(), session)
+	if assert.Error(t, err) {
+		assert.Equal(t, "Map: Session needs 4 cookies, more than the limit of 2.", err.Error())
+	}
+
+}
+
+func TestSessionStore_Middleware(t *testing.T) {
+
+	var saveErr error
+	store := testSessionStore(t, SessionOptions{OnSaveError: func(r *http.Request, err error) {
+		saveErr = err
+	}})
+
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := SessionFromRequest(r)
+		switch r.URL.Path {
+		case "/login":
+			session.Set("user.id", 42).AddFlash("Welcome back.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		case "/silent":
+			session.Set("visited", true)
+		case "/stream":
+			session.Set("streamed", true)
+			w.(http.Flusher).Flush()
+			session.Set("too late", true)
+			io.WriteString(w, "data")
+		case "/broken":
+			session.Set("f", func() {})
+			io.WriteString(w, "ok")
+		default:
+			flashes := session.Flashes()
+			M("user", session.Get("user.id"), "flashes", flashes).WriteResponse(w, http.StatusOK)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, 1, len(w.Result().Cookies()))
+
+	r := sessionRequest(w)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "{\"flashes\":[\"Welcome back.\"],\"user\":42}\n", w.Body.String())
+	assert.Equal(t, 1, len(w.Result().Cookies()), "the flash was taken")
+
+	// with nothing changed, no cookie is written
+	r = sessionRequest(w)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "{\"flashes\":null,\"user\":42}\n", w.Body.String())
+	assert.Equal(t, 0, len(w.Result().Cookies()))
+
+	// handlers that write nothing still save
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/silent", nil))
+	assert.Equal(t, 1, len(w.Result().Cookies()))
+
+	// flushing saves the session first
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "data", w.Body.String())
+	if assert.Equal(t, 1, len(w.Result().Cookies())) {
+		session, _ := store.Load(sessionRequest(w))
+		assert.Equal(t, true, session.Get("streamed"))
+		assert.Nil(t, session.Get("too late"))
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/broken", nil))
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, 0, len(w.Result().Cookies()))
+	assert.Error(t, saveErr)
+
+	assert.Nil(t, SessionFromRequest(httptest.NewRequest("GET", "/", nil)))
+
+}
@@ -0,0 +1,94 @@
+package objects
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Scan implements sql.Scanner, so that a Map can be read straight from a
+// JSON (or JSONB, or TEXT) column:
+//
+//     var settings objects.Map
+//     err := db.QueryRow("SELECT settings FROM users WHERE id = $1", id).Scan(&settings)
+//
+// The column may be JSON as []byte or a string.  SQL NULL, and the JSON
+// null, become a nil Map.
+func (d *Map) Scan(src interface{}) error {
+
+	var data string
+
+	switch src.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		data = string(src.([]byte))
+	case string:
+		data = src.(string)
+	default:
+		return fmt.Errorf("Map: Cannot scan %T into a Map.", src)
+	}
+
+	m, err := NewMapFromJSON(data)
+	if err != nil {
+		return err
+	}
+
+	*d = m
+
+	return nil
+}
+
+// Value implements driver.Valuer, so that a Map can be written straight to a
+// JSON (or JSONB, or TEXT) column.  The map is written as a JSON string, and
+// a nil Map is written as SQL NULL.
+func (d Map) Value() (driver.Value, error) {
+
+	if d == nil {
+		return nil, nil
+	}
+
+	return d.JSON()
+}
+
+// NullMap is a Map that may be SQL NULL, like sql.NullString.  Valid is true
+// if the column was not NULL.
+//
+// Unlike Map, a NullMap with a nil Map and Valid set is written as an empty
+// JSON object.
+type NullMap struct {
+	Map   Map
+	Valid bool
+}
+
+// Scan implements sql.Scanner.
+func (n *NullMap) Scan(src interface{}) error {
+
+	if src == nil {
+		n.Map, n.Valid = nil, false
+		return nil
+	}
+
+	if err := n.Map.Scan(src); err != nil {
+		n.Map, n.Valid = nil, false
+		return err
+	}
+
+	n.Valid = true
+
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullMap) Value() (driver.Value, error) {
+
+	if !n.Valid {
+		return nil, nil
+	}
+
+	if n.Map == nil {
+		return "{}", nil
+	}
+
+	return n.Map.Value()
+}
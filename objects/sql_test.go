@@ -0,0 +1,256 @@
+package objects
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+	Fake driver
+	------------------------------------------------
+
+	A single table with one column.  "INSERT" statements add their argument
+	as a row, "SELECT" statements get every row, and "DELETE" removes them.
+*/
+
+type fakeSQLDriver struct {
+	mutex sync.Mutex
+	rows  []driver.Value
+}
+
+var fakeSQL = &fakeSQLDriver{}
+
+func init() {
+	sql.Register("stewfake", fakeSQL)
+}
+
+func (d *fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	return &fakeSQLConn{driver: d}, nil
+}
+
+type fakeSQLConn struct {
+	driver *fakeSQLDriver
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeSQLConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake: transactions are not supported")
+}
+
+type fakeSQLStmt struct {
+	conn  *fakeSQLConn
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error {
+	return nil
+}
+
+func (s *fakeSQLStmt) NumInput() int {
+	return strings.Count(s.query, "?")
+}
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+
+	d := s.conn.driver
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		d.rows = append(d.rows, args[0])
+	case strings.HasPrefix(s.query, "DELETE"):
+		d.rows = nil
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+
+	d := s.conn.driver
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return &fakeSQLRows{rows: append([]driver.Value{}, d.rows...)}, nil
+}
+
+type fakeSQLRows struct {
+	rows []driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string {
+	return []string{"data"}
+}
+
+func (r *fakeSQLRows) Close() error {
+	return nil
+}
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	dest[0], r.rows = r.rows[0], r.rows[1:]
+	return nil
+}
+
+/*
+	Tests
+	------------------------------------------------
+*/
+
+func TestMapScan(t *testing.T) {
+
+	var m Map
+
+	if assert.NoError(t, m.Scan([]byte(`{"name":"stew","tags":["a"]}`))) {
+		assert.Equal(t, "stew", m.Get("name"))
+		assert.Equal(t, "a", m.Get("tags[0]"))
+	}
+
+	if assert.NoError(t, m.Scan(`{"name":"mat"}`)) {
+		assert.Equal(t, Map{"name": "mat"}, m)
+	}
+
+	assert.NoError(t, m.Scan(nil))
+	assert.Nil(t, m)
+
+	m = M("a", 1)
+	assert.NoError(t, m.Scan("null"))
+	assert.Nil(t, m)
+
+	assert.Error(t, m.Scan(`{"name":`))
+	assert.Error(t, m.Scan(`[1, 2]`))
+
+	err := m.Scan(42)
+	if assert.Error(t, err) {
+		assert.Equal(t, "Map: Cannot scan int into a Map.", err.Error())
+	}
+
+}
+
+func TestMapValue(t *testing.T) {
+
+	value, err := M("name", "stew").Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"name":"stew"}`, value)
+	}
+
+	value, err = Map(nil).Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	_, err = M("f", func() {}).Value()
+	assert.Error(t, err)
+
+}
+
+func TestNullMap(t *testing.T) {
+
+	var n NullMap
+
+	if assert.NoError(t, n.Scan(`{"a":1}`)) {
+		assert.True(t, n.Valid)
+		assert.Equal(t, 1.0, n.Map.Get("a"))
+	}
+
+	assert.NoError(t, n.Scan(nil))
+	assert.False(t, n.Valid)
+	assert.Nil(t, n.Map)
+
+	assert.Error(t, n.Scan(1.5))
+	assert.False(t, n.Valid)
+
+	value, _ := NullMap{Map: M("a", 1), Valid: true}.Value()
+	assert.Equal(t, `{"a":1}`, value)
+
+	value, _ = NullMap{Map: nil, Valid: true}.Value()
+	assert.Equal(t, `{}`, value)
+
+	value, _ = NullMap{Map: M("a", 1)}.Value()
+	assert.Nil(t, value)
+
+}
+
+func TestMapSQL(t *testing.T) {
+
+	db, err := sql.Open("stewfake", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec("DELETE")
+	assert.NoError(t, err)
+
+	for _, value := range []interface{}{
+		M("name", "stew", "owner", M("name", "mat")),
+		Map(nil),
+		NullMap{Map: M("valid", true), Valid: true},
+		NullMap{},
+		[]byte(`{"raw":"bytes"}`),
+	} {
+		_, err := db.Exec("INSERT ?", value)
+		assert.NoError(t, err)
+	}
+
+	rows, err := db.Query("SELECT")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rows.Close()
+
+	var maps []Map
+	var nulls []NullMap
+	for rows.Next() {
+		var m Map
+		if assert.NoError(t, rows.Scan(&m)) {
+			maps = append(maps, m)
+		}
+	}
+	assert.NoError(t, rows.Err())
+
+	assert.Equal(t, []Map{
+		{"name": "stew", "owner": map[string]interface{}{"name": "mat"}},
+		nil,
+		{"valid": true},
+		nil,
+		{"raw": "bytes"},
+	}, maps)
+
+	rows, err = db.Query("SELECT")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n NullMap
+		if assert.NoError(t, rows.Scan(&n)) {
+			nulls = append(nulls, n)
+		}
+	}
+
+	if assert.Equal(t, 5, len(nulls)) {
+		assert.True(t, nulls[0].Valid)
+		assert.False(t, nulls[1].Valid)
+		assert.True(t, nulls[2].Valid)
+		assert.False(t, nulls[3].Valid)
+		assert.True(t, nulls[4].Valid)
+	}
+
+}
@@ -0,0 +1,248 @@
+package objects
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"unicode"
+)
+
+// Decoder reads a stream of Map objects from JSON input, without reading
+// the whole input into memory first.
+//
+// The input may be objects one after the other (like NDJSON, with one object
+// per line), or a single JSON array of objects.
+//
+// For example:
+//
+//     decoder := objects.NewDecoder(file)
+//     for {
+//         m, err := decoder.Decode()
+//         if err == io.EOF {
+//             break
+//         }
+//         ...
+//     }
+type Decoder struct {
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	options DecodeOptions
+	// useNumber is true if UseNumber has been called.
+	useNumber bool
+	// started is true once the start of the input has been read.
+	started bool
+	// inArray is true while reading the items of a top level array.
+	inArray bool
+}
+
+// NewDecoder creates a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, DecodeOptions{})
+}
+
+// NewDecoderWithOptions creates a new Decoder that reads from r, checking
+// each Map against the limits in the options before it is decoded.
+//
+// MaxBytes limits the whole input, not each Map, since a stream could
+// otherwise go on forever.
+func NewDecoderWithOptions(r io.Reader, options DecodeOptions) *Decoder {
+
+	if options.MaxBytes > 0 {
+		r = &limitedReader{reader: r, remaining: options.MaxBytes, max: options.MaxBytes}
+	}
+
+	reader := bufio.NewReader(r)
+
+	return &Decoder{reader: reader, decoder: json.NewDecoder(reader), options: options}
+}
+
+// limitedReader reads from another reader, but returns a *LimitError
+// once more than max bytes have been read.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int
+	max       int
+}
+
+// Read reads from the underlying reader.
+func (l *limitedReader) Read(p []byte) (int, error) {
+
+	if l.remaining < 0 {
+		return 0, &LimitError{Limit: LimitBytes, Max: l.max}
+	}
+
+	if len(p) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.reader.Read(p)
+	if n > l.remaining {
+		// give back what fits, so values before the limit can still be read
+		n, l.remaining = l.remaining, -1
+		return n, &LimitError{Limit: LimitBytes, Max: l.max}
+	}
+	l.remaining -= n
+
+	return n, err
+}
+
+// decodeError makes the error returned by Decode.
+func decodeError(err error) error {
+	if limitErr, ok := err.(*LimitError); ok {
+		return limitErr
+	}
+	return errors.New("Map: JSON decode failed with: " + err.Error())
+}
+
+// UseNumber makes the Decoder store numbers as json.Number instead of
+// float64, so large integers are not rounded.  The typed getters, like
+// GetInt64, read json.Number values exactly.
+func (d *Decoder) UseNumber() *Decoder {
+	d.decoder.UseNumber()
+	d.useNumber = true
+	return d
+}
+
+// start checks whether the input is a single array of objects.
+func (d *Decoder) start() error {
+
+	d.started = true
+
+	for {
+		r, _, err := d.reader.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		d.reader.UnreadRune()
+		if r != '[' {
+			return nil
+		}
+		break
+	}
+
+	if _, err := d.decoder.Token(); err != nil {
+		return err
+	}
+	d.inArray = true
+
+	return nil
+}
+
+// Decode reads the next Map from the input.  At the end of the input, the error
+// is io.EOF.
+func (d *Decoder) Decode() (Map, error) {
+
+	if !d.started {
+		if err := d.start(); err != nil {
+			return nil, decodeError(err)
+		}
+	}
+
+	if d.inArray && !d.decoder.More() {
+		// read the closing bracket, anything after it is an error
+		if _, err := d.decoder.Token(); err != nil {
+			return nil, decodeError(err)
+		}
+		d.inArray = false
+		if _, err := d.decoder.Token(); err != io.EOF {
+			return nil, errors.New("Map: JSON decode failed with: unexpected data after top level array")
+		}
+		return nil, io.EOF
+	}
+
+	if !d.options.checksStructure() {
+
+		var unmarshalled map[string]interface{}
+
+		if err := d.decoder.Decode(&unmarshalled); err != nil {
+			if err == io.EOF && !d.inArray {
+				return nil, io.EOF
+			}
+			return nil, decodeError(err)
+		}
+
+		return Map(unmarshalled), nil
+	}
+
+	var raw json.RawMessage
+
+	if err := d.decoder.Decode(&raw); err != nil {
+		if err == io.EOF && !d.inArray {
+			return nil, io.EOF
+		}
+		return nil, decodeError(err)
+	}
+
+	if err := d.options.checkJSON(raw); err != nil {
+		return nil, err
+	}
+
+	rawDecoder := json.NewDecoder(bytes.NewReader(raw))
+	if d.useNumber {
+		rawDecoder.UseNumber()
+	}
+
+	var unmarshalled map[string]interface{}
+
+	if err := rawDecoder.Decode(&unmarshalled); err != nil {
+		return nil, decodeError(err)
+	}
+
+	return Map(unmarshalled), nil
+}
+
+// JSONOptions controls how WriteJSON and Encoder write JSON.
+type JSONOptions struct {
+	// Pretty writes indented JSON, instead of compact JSON.
+	Pretty bool
+	// Indent is the indent used by Pretty, two spaces if not set.
+	Indent string
+}
+
+// Encoder writes a stream of Map objects as JSON, one after the other.  With
+// compact output, this is NDJSON.
+type Encoder struct {
+	encoder *json.Encoder
+}
+
+// NewEncoder creates a new Encoder that writes to w.
+func NewEncoder(w io.Writer, options JSONOptions) *Encoder {
+
+	encoder := json.NewEncoder(w)
+
+	if options.Pretty {
+		if options.Indent == "" {
+			options.Indent = "  "
+		}
+		encoder.SetIndent("", options.Indent)
+	}
+
+	return &Encoder{encoder: encoder}
+}
+
+// Encode writes the map, followed by a newline.
+func (e *Encoder) Encode(m Map) error {
+
+	if err := e.encoder.Encode(m); err != nil {
+		return errors.New("Map: JSON encode failed with: " + err.Error())
+	}
+
+	return nil
+}
+
+// WriteJSON writes the map to w as JSON, followed by a newline.
+//
+// For example:
+//
+//     err := m.WriteJSON(w, objects.JSONOptions{Pretty: true})
+func (d Map) WriteJSON(w io.Writer, options JSONOptions) error {
+	return NewEncoder(w, options).Encode(d)
+}
@@ -0,0 +1,161 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func decodeAll(decoder *Decoder) ([]Map, error) {
+
+	var maps []Map
+	for {
+		m, err := decoder.Decode()
+		if err == io.EOF {
+			return maps, nil
+		}
+		if err != nil {
+			return maps, err
+		}
+		maps = append(maps, m)
+	}
+
+}
+
+func TestDecoder_NDJSON(t *testing.T) {
+
+	input := "{\"name\":\"a\"}\n{\"name\":\"b\",\"n\":{\"x\":1}}\n\n{\"name\":\"c\"}\n"
+
+	maps, err := decodeAll(NewDecoder(strings.NewReader(input)))
+	if assert.NoError(t, err) && assert.Equal(t, 3, len(maps)) {
+		assert.Equal(t, "a", maps[0].Get("name"))
+		assert.Equal(t, float64(1), maps[1].Get("n.x"))
+		assert.Equal(t, "c", maps[2].Get("name"))
+	}
+
+	// objects don't need newlines between them
+	maps, err = decodeAll(NewDecoder(strings.NewReader(`{"a":1}{"a":2} {"a":3}`)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, len(maps))
+	}
+
+	maps, err = decodeAll(NewDecoder(strings.NewReader("  ")))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, len(maps))
+	}
+
+}
+
+func TestDecoder_Array(t *testing.T) {
+
+	input := ` [ {"name":"a"}, {"name":"b"} ] `
+
+	maps, err := decodeAll(NewDecoder(strings.NewReader(input)))
+	if assert.NoError(t, err) && assert.Equal(t, 2, len(maps)) {
+		assert.Equal(t, "a", maps[0].Get("name"))
+		assert.Equal(t, "b", maps[1].Get("name"))
+	}
+
+	maps, err = decodeAll(NewDecoder(strings.NewReader(`[]`)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, len(maps))
+	}
+
+	_, err = decodeAll(NewDecoder(strings.NewReader(`[{"a":1}`)))
+	assert.Error(t, err)
+
+	_, err = decodeAll(NewDecoder(strings.NewReader(`[{"a":1}] {"b":2}`)))
+	assert.Error(t, err)
+
+	_, err = decodeAll(NewDecoder(strings.NewReader(`[1, 2]`)))
+	assert.Error(t, err)
+
+}
+
+func TestDecoder_Errors(t *testing.T) {
+
+	decoder := NewDecoder(strings.NewReader("{\"a\":1}\n{\"a\":\n"))
+
+	m, err := decoder.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(1), m.Get("a"))
+	}
+
+	_, err = decoder.Decode()
+	if assert.Error(t, err) {
+		assert.NotEqual(t, io.EOF, err)
+	}
+
+	_, err = NewDecoder(strings.NewReader(`"string"`)).Decode()
+	assert.Error(t, err)
+
+}
+
+func TestDecoder_UseNumber(t *testing.T) {
+
+	input := `{"big":18446744073709551615,"id":9007199254740993,"price":2.5}`
+
+	m, err := NewDecoder(strings.NewReader(input)).UseNumber().Decode()
+	if assert.NoError(t, err) {
+
+		assert.Equal(t, json.Number("9007199254740993"), m.Get("id"))
+		assert.Equal(t, int64(9007199254740993), m.MustInt64("id"))
+		assert.Equal(t, uint64(18446744073709551615), m.MustUint64("big"))
+		assert.Equal(t, 2.5, m.MustFloat64("price"))
+
+		_, err := m.GetInt("price")
+		assert.Error(t, err)
+
+		jsonString, _ := m.JSON()
+		assert.Equal(t, input, jsonString)
+
+	}
+
+	// without UseNumber the id is rounded
+	m, _ = NewDecoder(strings.NewReader(input)).Decode()
+	assert.NotEqual(t, int64(9007199254740993), m.MustInt64("id"))
+
+}
+
+func TestMapWriteJSON(t *testing.T) {
+
+	m := M("name", "stew", "tags", []interface{}{"a"})
+
+	var buf bytes.Buffer
+	if assert.NoError(t, m.WriteJSON(&buf, JSONOptions{})) {
+		assert.Equal(t, "{\"name\":\"stew\",\"tags\":[\"a\"]}\n", buf.String())
+	}
+
+	buf.Reset()
+	if assert.NoError(t, m.WriteJSON(&buf, JSONOptions{Pretty: true})) {
+		assert.Equal(t, "{\n  \"name\": \"stew\",\n  \"tags\": [\n    \"a\"\n  ]\n}\n", buf.String())
+	}
+
+	buf.Reset()
+	if assert.NoError(t, M("a", 1).WriteJSON(&buf, JSONOptions{Pretty: true, Indent: "\t"})) {
+		assert.Equal(t, "{\n\t\"a\": 1\n}\n", buf.String())
+	}
+
+	assert.Error(t, M("f", func() {}).WriteJSON(&buf, JSONOptions{}))
+
+}
+
+func TestEncoder(t *testing.T) {
+
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf, JSONOptions{})
+
+	assert.NoError(t, encoder.Encode(M("n", 1)))
+	assert.NoError(t, encoder.Encode(M("n", 2)))
+	assert.Equal(t, "{\"n\":1}\n{\"n\":2}\n", buf.String())
+
+	// the output can be read back in
+	maps, err := decodeAll(NewDecoder(&buf))
+	if assert.NoError(t, err) && assert.Equal(t, 2, len(maps)) {
+		assert.Equal(t, float64(2), maps[1].Get("n"))
+	}
+
+}
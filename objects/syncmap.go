@@ -0,0 +1,159 @@
+package objects
+
+import (
+	"sync"
+)
+
+// SyncMap is a Map that is safe to use from many goroutines at once.  It has
+// the same keypath methods as Map, each of which holds a lock for as long as
+// it needs, so Set can create the maps and slices along a keypath without
+// racing with anything else.
+//
+// Maps and slices are copied on the way in and out, so that values held by
+// the SyncMap are never shared with the code using it.  Use Update or
+// CompareAndSwap to change values based on what is already there.
+//
+// The zero value is an empty SyncMap, ready to use.  SyncMaps must not be
+// copied after they are first used.
+type SyncMap struct {
+	mutex sync.RWMutex
+	data  Map
+}
+
+// NewSyncMap creates a SyncMap holding a copy of the map.
+func NewSyncMap(m Map) *SyncMap {
+	return &SyncMap{data: m.DeepCopy()}
+}
+
+// write runs the function with the write lock held, making sure there is a
+// map to write to.
+func (s *SyncMap) write(f func(data Map)) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		s.data = make(Map)
+	}
+
+	f(s.data)
+
+}
+
+// Get gets a copy of the value at the keypath, or nil if there isn't one.
+// See Map.Get for the keypaths supported.
+func (s *SyncMap) Get(keypath string) interface{} {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return deepCopyValue(s.data.Get(keypath))
+}
+
+// Has gets whether there is a value at the keypath.
+func (s *SyncMap) Has(keypath string) bool {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.data.Has(keypath)
+}
+
+// Set sets a copy of the value at the keypath, creating maps and slices
+// along the way as Map.Set does, and returns the SyncMap.
+func (s *SyncMap) Set(keypath string, value interface{}) *SyncMap {
+
+	value = deepCopyValue(value)
+	s.write(func(data Map) {
+		data.Set(keypath, value)
+	})
+
+	// chain
+	return s
+}
+
+// Delete removes the value at the keypath, and returns the SyncMap.
+func (s *SyncMap) Delete(keypath string) *SyncMap {
+
+	s.write(func(data Map) {
+		data.Delete(keypath)
+	})
+
+	// chain
+	return s
+}
+
+// Merge blends a copy of the specified map into this one, and returns the
+// SyncMap.  Keys that appear in both will be selected from the specified
+// map, as with Map.Merge.
+func (s *SyncMap) Merge(merge Map) *SyncMap {
+
+	merge = merge.DeepCopy()
+	s.write(func(data Map) {
+		data.MergeHere(merge)
+	})
+
+	// chain
+	return s
+}
+
+// Update sets the value at the keypath to the one returned by the updater,
+// which is given a copy of the value that is there now (or nil).  No other
+// changes can happen between the value being read and the new one being set,
+// so the updater must not use the SyncMap itself.
+//
+// For example, to count safely from many goroutines:
+//
+//     counts.Update("requests", func(old interface{}) interface{} {
+//         n, _ := old.(int)
+//         return n + 1
+//     })
+//
+// The new value is returned.
+func (s *SyncMap) Update(keypath string, updater func(old interface{}) interface{}) interface{} {
+
+	var updated interface{}
+	s.write(func(data Map) {
+		updated = updater(deepCopyValue(data.Get(keypath)))
+		data.Set(keypath, deepCopyValue(updated))
+	})
+
+	return updated
+}
+
+// CompareAndSwap sets the value at the keypath to new, but only if the value
+// there now is equal to old, and gets whether it did.  Values are compared
+// deeply and numbers by their value, so int 1 is equal to float64 1.  An old
+// value of nil matches a missing value.
+func (s *SyncMap) CompareAndSwap(keypath string, old, new interface{}) bool {
+
+	swapped := false
+	new = deepCopyValue(new)
+	s.write(func(data Map) {
+		if valuesEqual(data.Get(keypath), old) {
+			data.Set(keypath, new)
+			swapped = true
+		}
+	})
+
+	return swapped
+}
+
+// Snapshot gets a copy of the whole map as it is at one moment.  Later
+// changes to the SyncMap do not affect the snapshot, and the other way round.
+func (s *SyncMap) Snapshot() Map {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.data.DeepCopy()
+}
+
+// Len gets the number of keys at the top level of the map.
+func (s *SyncMap) Len() int {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.data)
+}
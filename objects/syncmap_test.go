@@ -0,0 +1,147 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestSyncMap(t *testing.T) {
+
+	original := M("name", M("first", "Mat"))
+	s := NewSyncMap(original)
+
+	assert.Equal(t, "Mat", s.Get("name.first"))
+	assert.True(t, s.Has("name.first"))
+	assert.False(t, s.Has("name.last"))
+
+	s.Set("name.last", "Ryer").Set("tags[1]", "b").Delete("name.first")
+	assert.Equal(t, M("name", M("last", "Ryer"), "tags", []interface{}{nil, "b"}), s.Snapshot())
+	assert.Equal(t, 2, s.Len())
+
+	// the original map was copied
+	assert.Equal(t, "Mat", original.Get("name.first"))
+
+	s.Merge(M("name", "Mat", "age", 30))
+	assert.Equal(t, M("name", "Mat", "age", 30, "tags", []interface{}{nil, "b"}), s.Snapshot())
+
+	// the zero value is ready to use
+	var zero SyncMap
+	assert.Nil(t, zero.Get("a"))
+	assert.Equal(t, M(), zero.Snapshot())
+	zero.Set("a.b", 1)
+	assert.Equal(t, 1, zero.Get("a.b"))
+
+}
+
+func TestSyncMap_Copies(t *testing.T) {
+
+	s := NewSyncMap(nil)
+
+	value := M("count", 1)
+	s.Set("value", value)
+	value.Set("count", 2)
+	assert.Equal(t, 1, s.Get("value.count"))
+
+	got := s.Get("value").(Map)
+	got.Set("count", 3)
+	assert.Equal(t, 1, s.Get("value.count"))
+
+	snapshot := s.Snapshot()
+	snapshot.Set("value.count", 4)
+	s.Set("value.other", true)
+	assert.Equal(t, 1, s.Get("value.count"))
+	assert.Nil(t, snapshot.Get("value.other"))
+
+}
+
+func TestSyncMap_Update(t *testing.T) {
+
+	s := NewSyncMap(nil)
+
+	assert.Equal(t, 1, s.Update("counts.a", func(old interface{}) interface{} {
+		assert.Nil(t, old)
+		return 1
+	}))
+
+	s.Update("counts.a", func(old interface{}) interface{} {
+		return old.(int) + 1
+	})
+	assert.Equal(t, 2, s.Get("counts.a"))
+
+}
+
+func TestSyncMap_CompareAndSwap(t *testing.T) {
+
+	s := NewSyncMap(M("state", "open", "n", 1))
+
+	assert.False(t, s.CompareAndSwap("state", "closed", "open"))
+	assert.True(t, s.CompareAndSwap("state", "open", "closed"))
+	assert.Equal(t, "closed", s.Get("state"))
+
+	// numbers compare by value
+	assert.True(t, s.CompareAndSwap("n", float64(1), 2))
+	assert.Equal(t, 2, s.Get("n"))
+
+	// nil matches a missing value
+	assert.True(t, s.CompareAndSwap("lock.owner", nil, "a"))
+	assert.False(t, s.CompareAndSwap("lock.owner", nil, "b"))
+	assert.Equal(t, "a", s.Get("lock.owner"))
+
+	// maps compare deeply
+	s.Set("m", M("x", []interface{}{1}))
+	assert.True(t, s.CompareAndSwap("m", map[string]interface{}{"x": []interface{}{1.0}}, "swapped"))
+
+}
+
+func TestSyncMap_Concurrent(t *testing.T) {
+
+	s := NewSyncMap(nil)
+
+	const workers, iterations = 8, 200
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+
+				// intermediate maps are created by many goroutines at once
+				s.Set(fmt.Sprintf("workers.w%d.items[%d]", w, i), i)
+
+				s.Update("counts.update", func(old interface{}) interface{} {
+					n, _ := old.(int)
+					return n + 1
+				})
+
+				for {
+					old := s.Get("counts.cas")
+					n, _ := old.(int)
+					if s.CompareAndSwap("counts.cas", old, n+1) {
+						break
+					}
+				}
+
+				s.Merge(M(fmt.Sprintf("merged%d", w), i))
+				s.Has("workers")
+				s.Snapshot()
+				s.Get("workers")
+
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	snapshot := s.Snapshot()
+	assert.Equal(t, workers*iterations, snapshot.Get("counts.update"))
+	assert.Equal(t, workers*iterations, snapshot.Get("counts.cas"))
+	for w := 0; w < workers; w++ {
+		items := snapshot.Get(fmt.Sprintf("workers.w%d.items", w)).([]interface{})
+		assert.Equal(t, iterations, len(items))
+		assert.Equal(t, iterations-1, items[iterations-1])
+		assert.Equal(t, iterations-1, snapshot.Get(fmt.Sprintf("merged%d", w)))
+	}
+
+}
@@ -0,0 +1,222 @@
+package objects
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"strings"
+	"time"
+)
+
+// TokenAlgorithm is the HMAC algorithm used to sign a token.
+type TokenAlgorithm string
+
+const (
+	// TokenHS256 signs tokens with HMAC-SHA256.
+	TokenHS256 TokenAlgorithm = "HS256"
+	// TokenHS512 signs tokens with HMAC-SHA512.
+	TokenHS512 TokenAlgorithm = "HS512"
+)
+
+// tokenVersion is the version of the token format written by SignedToken.
+const tokenVersion int = 1
+
+// tokenSeparator separates the parts of a token.
+const tokenSeparator string = "."
+
+var (
+	// ErrTokenMalformed is returned when a token cannot be read.
+	ErrTokenMalformed = errors.New("Map: Token is malformed.")
+	// ErrTokenBadSignature is returned when a token's signature does not match,
+	// or was made with a key that is not in the KeyRing.
+	ErrTokenBadSignature = errors.New("Map: Token signature does not match.")
+	// ErrTokenExpired is returned when a token's signature is good, but it
+	// has expired.
+	ErrTokenExpired = errors.New("Map: Token has expired.")
+)
+
+// KeyRing holds the secret keys used to sign or encrypt Maps, so that keys
+// can be rotated without breaking everything made with the older ones.
+//
+// For example:
+//
+//     keys := &objects.KeyRing{
+//         Current: "2013-08",
+//         Keys: map[string][]byte{
+//             "2013-07": oldKey,
+//             "2013-08": newKey,
+//         },
+//     }
+type KeyRing struct {
+	// Current is the id of the key used to make new tokens.
+	Current string
+	// Keys holds every key that is accepted, by id.
+	Keys map[string][]byte
+}
+
+// currentKey gets the id and value of the key used to make new tokens.
+func (k *KeyRing) currentKey() (string, []byte, error) {
+	key, ok := k.Keys[k.Current]
+	if !ok || len(key) == 0 {
+		return "", nil, errors.New("Map: KeyRing has no current key '" + k.Current + "'.")
+	}
+	return k.Current, key, nil
+}
+
+// TokenOptions controls how SignedToken makes tokens.
+type TokenOptions struct {
+	// Algorithm is the signing algorithm, TokenHS256 if not set.
+	Algorithm TokenAlgorithm
+	// TTL is how long the token is valid for.  Tokens never expire if
+	// it is not set.
+	TTL time.Duration
+}
+
+// tokenHeader is the first part of a token.
+type tokenHeader struct {
+	Version   int            `json:"v"`
+	Algorithm TokenAlgorithm `json:"alg"`
+	KeyID     string         `json:"kid"`
+	IssuedAt  int64          `json:"iat"`
+	ExpiresAt int64          `json:"exp,omitempty"`
+}
+
+// tokenHash gets the hash function for the algorithm.
+func tokenHash(algorithm TokenAlgorithm) (func() hash.Hash, bool) {
+	switch algorithm {
+	case TokenHS256:
+		return sha256.New, true
+	case TokenHS512:
+		return sha512.New, true
+	}
+	return nil, false
+}
+
+// signToken makes the signature for the signed part of a token.
+func signToken(newHash func() hash.Hash, key []byte, signed string) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// SignedToken converts the map into a URL-safe token, signed with the current
+// key from the KeyRing.
+//
+// Unlike SignedBase64, the token records when it was issued, when it expires and
+// the id of the key that signed it, so keys can be rotated.  Use
+// NewMapFromSignedToken to read it.
+//
+// Tokens are signed, not encrypted, so the map can still be read by anyone who
+// has the token.
+func (d Map) SignedToken(keys *KeyRing, options TokenOptions) (string, error) {
+
+	if options.Algorithm == "" {
+		options.Algorithm = TokenHS256
+	}
+
+	newHash, ok := tokenHash(options.Algorithm)
+	if !ok {
+		return "", errors.New("Map: Unknown token algorithm '" + string(options.Algorithm) + "'.")
+	}
+
+	keyID, key, err := keys.currentKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	header := tokenHeader{Version: tokenVersion, Algorithm: options.Algorithm, KeyID: keyID, IssuedAt: now.Unix()}
+	if options.TTL != 0 {
+		header.ExpiresAt = now.Add(options.TTL).Unix()
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	payloadJSON, err := d.JSON()
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + tokenSeparator + base64.RawURLEncoding.EncodeToString([]byte(payloadJSON))
+	sig := signToken(newHash, key, signed)
+
+	return signed + tokenSeparator + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// NewMapFromSignedToken creates a new map from a token made by SignedToken,
+// checking it was signed by one of the keys in the KeyRing and has not
+// expired.
+//
+// The error is ErrTokenMalformed, ErrTokenBadSignature or ErrTokenExpired if
+// the token is not acceptable.
+func NewMapFromSignedToken(token string, keys *KeyRing) (Map, error) {
+
+	header, payload, err := verifySignedToken(token, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.ExpiresAt != 0 && time.Now().Unix() >= header.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	m, err := NewMapFromJSON(string(payload))
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	return m, nil
+}
+
+// verifySignedToken splits the token and checks its signature, returning the
+// header and the decoded payload.
+func verifySignedToken(token string, keys *KeyRing) (*tokenHeader, []byte, error) {
+
+	parts := strings.Split(token, tokenSeparator)
+	if len(parts) != 3 {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	var header tokenHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Version != tokenVersion {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	newHash, ok := tokenHash(header.Algorithm)
+	if !ok {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	key, ok := keys.Keys[header.KeyID]
+	if !ok || len(key) == 0 {
+		return nil, nil, ErrTokenBadSignature
+	}
+
+	if !hmac.Equal(sig, signToken(newHash, key, parts[0]+tokenSeparator+parts[1])) {
+		return nil, nil, ErrTokenBadSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, ErrTokenMalformed
+	}
+
+	return &header, payload, nil
+}
@@ -0,0 +1,104 @@
+package objects
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+var tokenTestKeys = &KeyRing{
+	Current: "new",
+	Keys: map[string][]byte{
+		"old": []byte("e1zJJGjCJfLAR1b4dDqg0PY33731D8gM"),
+		"new": []byte("Qv3Ygz9hW1Tq7uKcPdrNbX2LfE0sAmJo"),
+	},
+}
+
+func TestSignedToken(t *testing.T) {
+
+	m := M("name", "tyler", "roles", []interface{}{"admin"})
+
+	for _, algorithm := range []TokenAlgorithm{"", TokenHS256, TokenHS512} {
+
+		token, err := m.SignedToken(tokenTestKeys, TokenOptions{Algorithm: algorithm, TTL: time.Hour})
+		if assert.NoError(t, err) {
+
+			assert.Equal(t, 3, len(strings.Split(token, ".")))
+			assert.False(t, strings.ContainsAny(token, "+/="), "token should be URL-safe")
+
+			decoded, err := NewMapFromSignedToken(token, tokenTestKeys)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "tyler", decoded.Get("name"))
+				assert.Equal(t, "admin", decoded.Get("roles[0]"))
+			}
+
+		}
+
+	}
+
+	_, err := m.SignedToken(tokenTestKeys, TokenOptions{Algorithm: "none"})
+	assert.Error(t, err)
+
+	_, err = m.SignedToken(&KeyRing{Current: "missing"}, TokenOptions{})
+	assert.Error(t, err)
+
+}
+
+func TestNewMapFromSignedToken_Rotation(t *testing.T) {
+
+	oldKeys := &KeyRing{Current: "old", Keys: tokenTestKeys.Keys}
+
+	token, err := M("name", "tyler").SignedToken(oldKeys, TokenOptions{})
+	if assert.NoError(t, err) {
+
+		// still accepted while the old key is in the ring
+		m, err := NewMapFromSignedToken(token, tokenTestKeys)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "tyler", m.Get("name"))
+		}
+
+		// but not once it has been retired
+		_, err = NewMapFromSignedToken(token, &KeyRing{Current: "new", Keys: map[string][]byte{"new": tokenTestKeys.Keys["new"]}})
+		assert.Equal(t, ErrTokenBadSignature, err)
+
+	}
+
+}
+
+func TestNewMapFromSignedToken_Expired(t *testing.T) {
+
+	token, err := M("name", "tyler").SignedToken(tokenTestKeys, TokenOptions{TTL: -time.Minute})
+	if assert.NoError(t, err) {
+		_, err = NewMapFromSignedToken(token, tokenTestKeys)
+		assert.Equal(t, ErrTokenExpired, err)
+	}
+
+}
+
+func TestNewMapFromSignedToken_Errors(t *testing.T) {
+
+	token, _ := M("name", "tyler").SignedToken(tokenTestKeys, TokenOptions{})
+	parts := strings.Split(token, ".")
+
+	// tampered payload
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(`{"name":"mat"}`))
+	_, err := NewMapFromSignedToken(parts[0]+"."+tampered+"."+parts[2], tokenTestKeys)
+	assert.Equal(t, ErrTokenBadSignature, err)
+
+	// tampered signature
+	_, err = NewMapFromSignedToken(parts[0]+"."+parts[1]+"."+parts[2][1:], tokenTestKeys)
+	assert.Equal(t, ErrTokenBadSignature, err)
+
+	// header pointing at an unknown algorithm
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"v":1,"alg":"none","kid":"new","iat":0}`))
+	_, err = NewMapFromSignedToken(header+"."+parts[1]+"."+parts[2], tokenTestKeys)
+	assert.Equal(t, ErrTokenMalformed, err)
+
+	for _, malformed := range []string{"", "a.b", "a.b.c.d", "!!!." + parts[1] + "." + parts[2], parts[0] + "." + parts[1] + ".!!!"} {
+		_, err = NewMapFromSignedToken(malformed, tokenTestKeys)
+		assert.Equal(t, ErrTokenMalformed, err, malformed)
+	}
+
+}
@@ -0,0 +1,481 @@
+package objects
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/stew/numbers"
+	stewstrings "github.com/stretchr/stew/strings"
+	"math"
+	"strconv"
+	"time"
+)
+
+// TypeError is returned by the typed getters when the value at the keypath
+// is missing or cannot be used as the expected type.
+type TypeError struct {
+	// Keypath is the keypath that was requested.
+	Keypath string
+	// Expected is the name of the type that was asked for.
+	Expected string
+	// Actual is the name of the type that was found.
+	Actual string
+	// Missing is true if there was no value at the keypath at all.
+	Missing bool
+}
+
+// Error gets the error message.
+func (e *TypeError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("Map: Expected %s at '%s' but nothing was found.", e.Expected, e.Keypath)
+	}
+	return fmt.Sprintf("Map: Expected %s at '%s' but found %s.", e.Expected, e.Keypath, e.Actual)
+}
+
+// typeError makes a TypeError describing the value found at the keypath.
+func typeError(keypath, expected string, value interface{}) *TypeError {
+	return &TypeError{Keypath: keypath, Expected: expected, Actual: fmt.Sprintf("%T", value)}
+}
+
+// lookup gets the value at the keypath, or a missing TypeError if there is none.
+func (d Map) lookup(keypath, expected string) (interface{}, error) {
+	value, ok := getPath(d, parseKeypath(keypath))
+	if !ok || value == nil {
+		return nil, &TypeError{Keypath: keypath, Expected: expected, Missing: true}
+	}
+	return value, nil
+}
+
+// toInt64 converts integer types and integer strings directly, and
+// everything else through toFloat64, refusing values that would lose
+// information.
+func toInt64(value interface{}) (int64, bool) {
+
+	switch value.(type) {
+	case int:
+		return int64(value.(int)), true
+	case int8:
+		return int64(value.(int8)), true
+	case int16:
+		return int64(value.(int16)), true
+	case int32:
+		return int64(value.(int32)), true
+	case int64:
+		return value.(int64), true
+	case uint:
+		return int64(value.(uint)), value.(uint) <= math.MaxInt64
+	case uint8:
+		return int64(value.(uint8)), true
+	case uint16:
+		return int64(value.(uint16)), true
+	case uint32:
+		return int64(value.(uint32)), true
+	case uint64:
+		return int64(value.(uint64)), value.(uint64) <= math.MaxInt64
+	case json.Number:
+		// large integers are parsed exactly, rather than through a float64
+		if i, err := value.(json.Number).Int64(); err == nil {
+			return i, true
+		}
+	case string:
+		if i, err := strconv.ParseInt(value.(string), 10, 64); err == nil {
+			return i, true
+		}
+	}
+
+	f, ok := toFloat64(value)
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+
+	return int64(f), true
+}
+
+// toFloat64 converts numbers and numeric strings to a float64.  Strings are
+// parsed as a float64, since numbers.FromInterface would parse them as a
+// float32.
+func toFloat64(value interface{}) (float64, bool) {
+
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	switch value.(type) {
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	default:
+		return 0, false
+	}
+
+	if s, ok := value.(string); ok {
+		switch stewstrings.Parse(s).(type) {
+		case string, bool, nil:
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+
+	n, err := numbers.FromInterface(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return n.Float64(), true
+}
+
+// GetInt gets an int from the map at the specified keypath.
+//
+// Any numeric type (or numeric string) will be converted, as long as it
+// is a whole number that fits, so JSON values like 29.0 work as expected.
+func (d Map) GetInt(keypath string) (int, error) {
+
+	value, err := d.lookup(keypath, "int")
+	if err != nil {
+		return 0, err
+	}
+
+	i, ok := toInt64(value)
+	if !ok || i < math.MinInt || i > math.MaxInt {
+		return 0, typeError(keypath, "int", value)
+	}
+
+	return int(i), nil
+}
+
+// GetInt64 gets an int64 from the map at the specified keypath.
+//
+// Conversion follows the same rules as GetInt.
+func (d Map) GetInt64(keypath string) (int64, error) {
+
+	value, err := d.lookup(keypath, "int64")
+	if err != nil {
+		return 0, err
+	}
+
+	i, ok := toInt64(value)
+	if !ok {
+		return 0, typeError(keypath, "int64", value)
+	}
+
+	return i, nil
+}
+
+// GetUint64 gets a uint64 from the map at the specified keypath.
+//
+// Conversion follows the same rules as GetInt, and negative numbers are
+// refused.
+func (d Map) GetUint64(keypath string) (uint64, error) {
+
+	value, err := d.lookup(keypath, "uint64")
+	if err != nil {
+		return 0, err
+	}
+
+	if u, ok := value.(uint64); ok {
+		return u, nil
+	}
+	if n, ok := value.(json.Number); ok {
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return u, nil
+		}
+	}
+
+	i, ok := toInt64(value)
+	if !ok || i < 0 {
+		return 0, typeError(keypath, "uint64", value)
+	}
+
+	return uint64(i), nil
+}
+
+// GetFloat64 gets a float64 from the map at the specified keypath.
+//
+// Any numeric type (or numeric string) will be converted.
+func (d Map) GetFloat64(keypath string) (float64, error) {
+
+	value, err := d.lookup(keypath, "float64")
+	if err != nil {
+		return 0, err
+	}
+
+	f, ok := toFloat64(value)
+	if !ok {
+		return 0, typeError(keypath, "float64", value)
+	}
+
+	return f, nil
+}
+
+// GetBool gets a bool from the map at the specified keypath.
+//
+// The strings "true" and "false" (in any case) are also accepted.
+func (d Map) GetBool(keypath string) (bool, error) {
+
+	value, err := d.lookup(keypath, "bool")
+	if err != nil {
+		return false, err
+	}
+
+	switch value.(type) {
+	case bool:
+		return value.(bool), nil
+	case string:
+		if b, ok := stewstrings.Parse(value.(string)).(bool); ok {
+			return b, nil
+		}
+	}
+
+	return false, typeError(keypath, "bool", value)
+}
+
+// GetStringOrError gets a string from the map at the specified keypath, or
+// returns a *TypeError if it is missing or of the wrong type.
+func (d Map) GetStringOrError(keypath string) (string, error) {
+
+	value, err := d.lookup(keypath, "string")
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", typeError(keypath, "string", value)
+	}
+
+	return s, nil
+}
+
+// GetMapOrError gets another Map from this one, or returns a *TypeError
+// if it is missing or of the wrong type.
+func (d Map) GetMapOrError(keypath string) (Map, error) {
+
+	value, err := d.lookup(keypath, "Map")
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := asMap(value)
+	if !ok {
+		return nil, typeError(keypath, "Map", value)
+	}
+
+	return m, nil
+}
+
+// GetTime gets a time.Time from the map at the specified keypath.
+//
+// Strings are parsed using the time.RFC3339Nano format, which is what
+// time.Time values are encoded as in JSON.
+func (d Map) GetTime(keypath string) (time.Time, error) {
+
+	value, err := d.lookup(keypath, "time.Time")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch value.(type) {
+	case time.Time:
+		return value.(time.Time), nil
+	case *time.Time:
+		if t := value.(*time.Time); t != nil {
+			return *t, nil
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, value.(string)); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, typeError(keypath, "time.Time", value)
+}
+
+// GetDuration gets a time.Duration from the map at the specified keypath.
+//
+// Strings are parsed with time.ParseDuration (e.g. "1h30m") and numbers are
+// taken to be nanoseconds, which is what time.Duration values are encoded as
+// in JSON.
+func (d Map) GetDuration(keypath string) (time.Duration, error) {
+
+	value, err := d.lookup(keypath, "time.Duration")
+	if err != nil {
+		return 0, err
+	}
+
+	switch value.(type) {
+	case time.Duration:
+		return value.(time.Duration), nil
+	case string:
+		if duration, err := time.ParseDuration(value.(string)); err == nil {
+			return duration, nil
+		}
+	}
+
+	if _, isString := value.(string); !isString {
+		if i, ok := toInt64(value); ok {
+			return time.Duration(i), nil
+		}
+	}
+
+	return 0, typeError(keypath, "time.Duration", value)
+}
+
+// GetStringSlice gets a []string from the map at the specified keypath.
+//
+// A []interface{} is accepted as long as every item is a string.
+func (d Map) GetStringSlice(keypath string) ([]string, error) {
+
+	value, err := d.lookup(keypath, "[]string")
+	if err != nil {
+		return nil, err
+	}
+
+	switch value.(type) {
+	case []string:
+		return value.([]string), nil
+	case []interface{}:
+		items := value.([]interface{})
+		strs := make([]string, len(items))
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, typeError(keypath, "[]string", value)
+			}
+			strs[i] = s
+		}
+		return strs, nil
+	}
+
+	return nil, typeError(keypath, "[]string", value)
+}
+
+// GetMapSlice gets a []Map from the map at the specified keypath.
+//
+// A []map[string]interface{} or []interface{} is accepted as long as every
+// item is a Map or map[string]interface{}.
+func (d Map) GetMapSlice(keypath string) ([]Map, error) {
+
+	value, err := d.lookup(keypath, "[]Map")
+	if err != nil {
+		return nil, err
+	}
+
+	switch value.(type) {
+	case []Map:
+		return value.([]Map), nil
+	case []map[string]interface{}:
+		items := value.([]map[string]interface{})
+		maps := make([]Map, len(items))
+		for i, item := range items {
+			maps[i] = Map(item)
+		}
+		return maps, nil
+	case []interface{}:
+		items := value.([]interface{})
+		maps := make([]Map, len(items))
+		for i, item := range items {
+			m, ok := asMap(item)
+			if !ok {
+				return nil, typeError(keypath, "[]Map", value)
+			}
+			maps[i] = m
+		}
+		return maps, nil
+	}
+
+	return nil, typeError(keypath, "[]Map", value)
+}
+
+/*
+	Must
+	------------------------------------------------
+*/
+
+// MustInt gets an int like GetInt, but panics with the *TypeError instead of
+// returning it.
+func (d Map) MustInt(keypath string) int {
+	i, err := d.GetInt(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+// MustInt64 gets an int64 like GetInt64, but panics with the *TypeError instead of
+// returning it.
+func (d Map) MustInt64(keypath string) int64 {
+	i, err := d.GetInt64(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+// MustUint64 gets a uint64 like GetUint64, but panics with the *TypeError instead of
+// returning it.
+func (d Map) MustUint64(keypath string) uint64 {
+	u, err := d.GetUint64(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// MustFloat64 gets a float64 like GetFloat64, but panics with the *TypeError instead of
+// returning it.
+func (d Map) MustFloat64(keypath string) float64 {
+	f, err := d.GetFloat64(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// MustBool gets a bool like GetBool, but panics with the *TypeError instead of
+// returning it.
+func (d Map) MustBool(keypath string) bool {
+	b, err := d.GetBool(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// MustTime gets a time.Time like GetTime, but panics with the *TypeError instead of
+// returning it.
+func (d Map) MustTime(keypath string) time.Time {
+	t, err := d.GetTime(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// MustDuration gets a time.Duration like GetDuration, but panics with the *TypeError
+// instead of returning it.
+func (d Map) MustDuration(keypath string) time.Duration {
+	duration, err := d.GetDuration(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return duration
+}
+
+// MustStringSlice gets a []string like GetStringSlice, but panics with the *TypeError
+// instead of returning it.
+func (d Map) MustStringSlice(keypath string) []string {
+	strs, err := d.GetStringSlice(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return strs
+}
+
+// MustMapSlice gets a []Map like GetMapSlice, but panics with the *TypeError
+// instead of returning it.
+func (d Map) MustMapSlice(keypath string) []Map {
+	maps, err := d.GetMapSlice(keypath)
+	if err != nil {
+		panic(err)
+	}
+	return maps
+}
@@ -0,0 +1,276 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTypeError(t *testing.T) {
+
+	err := &TypeError{Keypath: "user.age", Expected: "int", Actual: "string"}
+	assert.Equal(t, "Map: Expected int at 'user.age' but found string.", err.Error())
+
+	err = &TypeError{Keypath: "user.age", Expected: "int", Missing: true}
+	assert.Equal(t, "Map: Expected int at 'user.age' but nothing was found.", err.Error())
+
+}
+
+func TestGetInt(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"age":29,"big":1e30,"half":1.5,"name":"Mat","nums":[1,2],"text":"42"}`)
+	m.Set("int8", int8(8)).Set("uint64", uint64(64))
+
+	i, err := m.GetInt("age")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 29, i)
+	}
+
+	i, err = m.GetInt("nums[-1]")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, i)
+	}
+
+	i, err = m.GetInt("text")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 42, i)
+	}
+
+	i, err = m.GetInt("int8")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 8, i)
+	}
+
+	i, err = m.GetInt("uint64")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 64, i)
+	}
+
+	_, err = m.GetInt("name")
+	if assert.IsType(t, &TypeError{}, err) {
+		assert.Equal(t, &TypeError{Keypath: "name", Expected: "int", Actual: "string"}, err)
+	}
+
+	_, err = m.GetInt("half")
+	assert.Equal(t, &TypeError{Keypath: "half", Expected: "int", Actual: "float64"}, err)
+
+	_, err = m.GetInt("big")
+	assert.Equal(t, &TypeError{Keypath: "big", Expected: "int", Actual: "float64"}, err)
+
+	_, err = m.GetInt("nope")
+	assert.Equal(t, &TypeError{Keypath: "nope", Expected: "int", Missing: true}, err)
+
+}
+
+func TestGetInt64AndUint64(t *testing.T) {
+
+	m := M("big", int64(9007199254740993), "negative", -1, "float", float64(12), "text", "9007199254740993")
+
+	i, err := m.GetInt64("big")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(9007199254740993), i)
+	}
+
+	i, err = m.GetInt64("text")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(9007199254740993), i)
+	}
+
+	u, err := m.GetUint64("float")
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(12), u)
+	}
+
+	_, err = m.GetUint64("negative")
+	assert.Equal(t, &TypeError{Keypath: "negative", Expected: "uint64", Actual: "int"}, err)
+
+}
+
+func TestGetFloat64(t *testing.T) {
+
+	m := M("price", 9.99, "count", 3, "text", "1.5", "tenth", "0.1", "bool", true)
+
+	f, err := m.GetFloat64("price")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 9.99, f)
+	}
+
+	f, err = m.GetFloat64("count")
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(3), f)
+	}
+
+	f, err = m.GetFloat64("text")
+	if assert.NoError(t, err) {
+		assert.InDelta(t, 1.5, f, 0.0001)
+	}
+
+	f, err = m.GetFloat64("tenth")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0.1, f)
+	}
+
+	_, err = m.GetFloat64("bool")
+	assert.Equal(t, &TypeError{Keypath: "bool", Expected: "float64", Actual: "bool"}, err)
+
+}
+
+func TestGetBool(t *testing.T) {
+
+	m := M("active", true, "text", "FALSE", "number", 1)
+
+	b, err := m.GetBool("active")
+	if assert.NoError(t, err) {
+		assert.True(t, b)
+	}
+
+	b, err = m.GetBool("text")
+	if assert.NoError(t, err) {
+		assert.False(t, b)
+	}
+
+	_, err = m.GetBool("number")
+	assert.Equal(t, &TypeError{Keypath: "number", Expected: "bool", Actual: "int"}, err)
+
+}
+
+func TestGetStringOrError(t *testing.T) {
+
+	m := M("name", "Mat", "age", 29)
+
+	s, err := m.GetStringOrError("name")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Mat", s)
+	}
+
+	_, err = m.GetStringOrError("age")
+	assert.Equal(t, &TypeError{Keypath: "age", Expected: "string", Actual: "int"}, err)
+
+	assert.Panics(t, func() {
+		m.GetString("age")
+	})
+
+}
+
+func TestGetMapOrError(t *testing.T) {
+
+	m := M("native", map[string]interface{}{"name": "Mat"}, "name", "Mat")
+
+	child, err := m.GetMapOrError("native")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Mat", child.Get("name"))
+	}
+
+	_, err = m.GetMapOrError("name")
+	assert.Equal(t, &TypeError{Keypath: "name", Expected: "Map", Actual: "string"}, err)
+
+	assert.Equal(t, "Mat", m.GetMap("native").Get("name"))
+	assert.Panics(t, func() {
+		m.GetMap("name")
+	})
+
+}
+
+func TestGetTime(t *testing.T) {
+
+	now := time.Date(2013, 8, 12, 10, 30, 0, 0, time.UTC)
+	m := M("time", now, "text", "2013-08-12T10:30:00Z", "bad", "yesterday")
+
+	tm, err := m.GetTime("time")
+	if assert.NoError(t, err) {
+		assert.Equal(t, now, tm)
+	}
+
+	tm, err = m.GetTime("text")
+	if assert.NoError(t, err) {
+		assert.True(t, now.Equal(tm))
+	}
+
+	_, err = m.GetTime("bad")
+	assert.Equal(t, &TypeError{Keypath: "bad", Expected: "time.Time", Actual: "string"}, err)
+
+}
+
+func TestGetDuration(t *testing.T) {
+
+	m := M("duration", time.Second, "text", "1h30m", "nanos", float64(1000), "bad", "soon")
+
+	duration, err := m.GetDuration("duration")
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Second, duration)
+	}
+
+	duration, err = m.GetDuration("text")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 90*time.Minute, duration)
+	}
+
+	duration, err = m.GetDuration("nanos")
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Microsecond, duration)
+	}
+
+	_, err = m.GetDuration("bad")
+	assert.Equal(t, &TypeError{Keypath: "bad", Expected: "time.Duration", Actual: "string"}, err)
+
+}
+
+func TestGetStringSlice(t *testing.T) {
+
+	m := M("strings", []string{"a"}, "interfaces", []interface{}{"b", "c"}, "mixed", []interface{}{"d", 1})
+
+	strs, err := m.GetStringSlice("strings")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a"}, strs)
+	}
+
+	strs, err = m.GetStringSlice("interfaces")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"b", "c"}, strs)
+	}
+
+	_, err = m.GetStringSlice("mixed")
+	assert.Equal(t, &TypeError{Keypath: "mixed", Expected: "[]string", Actual: "[]interface {}"}, err)
+
+}
+
+func TestGetMapSlice(t *testing.T) {
+
+	m, _ := NewMapFromJSON(`{"users":[{"name":"Mat"},{"name":"Tyler"}],"mixed":[{"name":"Mat"},1]}`)
+
+	maps, err := m.GetMapSlice("users")
+	if assert.NoError(t, err) && assert.Len(t, maps, 2) {
+		assert.Equal(t, "Tyler", maps[1].Get("name"))
+	}
+
+	maps, err = M("maps", []map[string]interface{}{{"name": "Mat"}}).GetMapSlice("maps")
+	if assert.NoError(t, err) && assert.Len(t, maps, 1) {
+		assert.Equal(t, "Mat", maps[0].Get("name"))
+	}
+
+	_, err = m.GetMapSlice("mixed")
+	assert.Equal(t, &TypeError{Keypath: "mixed", Expected: "[]Map", Actual: "[]interface {}"}, err)
+
+}
+
+func TestMust(t *testing.T) {
+
+	m := M("age", 29, "name", "Mat")
+
+	assert.Equal(t, 29, m.MustInt("age"))
+	assert.Equal(t, int64(29), m.MustInt64("age"))
+	assert.Equal(t, uint64(29), m.MustUint64("age"))
+	assert.Equal(t, float64(29), m.MustFloat64("age"))
+	assert.Equal(t, time.Duration(29), m.MustDuration("age"))
+
+	assert.Panics(t, func() { m.MustInt("name") })
+	assert.Panics(t, func() { m.MustInt64("name") })
+	assert.Panics(t, func() { m.MustUint64("name") })
+	assert.Panics(t, func() { m.MustFloat64("name") })
+	assert.Panics(t, func() { m.MustBool("name") })
+	assert.Panics(t, func() { m.MustTime("name") })
+	assert.Panics(t, func() { m.MustDuration("name") })
+	assert.Panics(t, func() { m.MustStringSlice("name") })
+	assert.Panics(t, func() { m.MustMapSlice("name") })
+
+}
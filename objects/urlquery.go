@@ -0,0 +1,313 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	stewstrings "github.com/stretchr/stew/strings"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URLQueryStyle is the way nested objects and arrays are written in the keys
+// of a URL query.
+type URLQueryStyle int
+
+const (
+	// URLQueryDots writes nested keys as keypaths, like `user.address.city=x`
+	// and `ids[0]=1`.  When decoding, repeated keys (`ids=1&ids=2`) become arrays.
+	URLQueryDots URLQueryStyle = iota
+	// URLQueryBrackets writes nested keys in the Rails/PHP style, like
+	// `user[address][city]=x` and `ids[]=1&ids[]=2`.
+	URLQueryBrackets
+)
+
+const (
+	// DefaultURLQueryMaxDepth is the deepest nesting allowed when decoding
+	// URL queries if URLQueryOptions.Limits.MaxDepth is not set.
+	DefaultURLQueryMaxDepth int = 32
+	// DefaultURLQueryMaxKeys is the most values allowed when decoding URL
+	// queries if URLQueryOptions.Limits.MaxKeys is not set.
+	DefaultURLQueryMaxKeys int = 1000
+)
+
+// URLQueryOptions controls how Maps are encoded to and decoded from URL
+// queries.  The zero value uses the URLQueryDots style, leaves values as strings
+// and applies the default limits.
+type URLQueryOptions struct {
+	// Style is the way nested keys are written.
+	Style URLQueryStyle
+	// ParseValues turns values into native types using strings.Parse when
+	// decoding, and quotes strings that would otherwise change type when
+	// encoding.
+	ParseValues bool
+	// Limits are checked when decoding, as they are for other formats.
+	// MaxKeys counts values, and MaxDepth the segments in a key; since URL
+	// queries usually come from untrusted requests, DefaultURLQueryMaxKeys
+	// and DefaultURLQueryMaxDepth are used if they are not set.
+	// DisallowDuplicateKeys rejects keys that appear more than once, unless
+	// they end in empty brackets (`ids[]`), instead of making an array.
+	// Array indexes in keys are limited by MaxIndex.
+	Limits DecodeOptions
+}
+
+// limits gets the Limits, with the default MaxDepth and MaxKeys if they are
+// not set.
+func (o URLQueryOptions) limits() DecodeOptions {
+
+	limits := o.Limits
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultURLQueryMaxDepth
+	}
+	if limits.MaxKeys <= 0 {
+		limits.MaxKeys = DefaultURLQueryMaxKeys
+	}
+
+	return limits
+}
+
+// appendSegment stands for empty brackets (`ids[]`) in a parsed key.
+var appendSegment = pathSegment{index: -1, isIndex: true, key: "[]"}
+
+// NewMapFromURLQueryWithOptions generates a new map by parsing the specified
+// query using the options.
+//
+// For example:
+//
+//     m, err := objects.NewMapFromURLQueryWithOptions("user[name]=Mat&ids[]=1&ids[]=2",
+//         objects.URLQueryOptions{Style: objects.URLQueryBrackets, ParseValues: true})
+//     // m is Map{"user": Map{"name": "Mat"}, "ids": []interface{}{1, 2}}
+func NewMapFromURLQueryWithOptions(query string, options URLQueryOptions) (Map, error) {
+
+	if err := options.Limits.checkBytes(len(query)); err != nil {
+		return nil, err
+	}
+
+	vals, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMapFromURLValuesWithOptions(vals, options)
+}
+
+// NewMapFromURLValuesWithOptions generates a new map from the url.Values
+// using the options.
+//
+// Keys are applied in order, so where keys clash (`a=1&a[b]=2`) the later
+// one wins.  Keys with more than one value, and keys ending in empty
+// brackets, become arrays.  Numeric keys in brackets are taken to be array
+// indexes.
+//
+// A *LimitError is returned if the query goes beyond one of the limits in the
+// options, and a *DuplicateKeyError if DisallowDuplicateKeys is set and a key
+// is repeated.
+func NewMapFromURLValuesWithOptions(vals url.Values, options URLQueryOptions) (Map, error) {
+
+	limits := options.limits()
+
+	keys := make([]string, 0, len(vals))
+	count := 0
+	for k, v := range vals {
+		keys = append(keys, k)
+		count += len(v)
+		if err := limits.checkKeys(count); err != nil {
+			return nil, err
+		}
+		if err := limits.checkString(k); err != nil {
+			return nil, err
+		}
+		for _, value := range v {
+			if err := limits.checkString(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	m := make(Map)
+
+	for _, k := range keys {
+
+		var segs []pathSegment
+		if options.Style == URLQueryBrackets {
+			segs = parseBracketKey(k)
+		} else {
+			segs = parseKeypath(k)
+		}
+
+		if err := limits.checkDepth(len(segs)); err != nil {
+			return nil, err
+		}
+		for i, seg := range segs {
+			if seg == appendSegment && i < len(segs)-1 {
+				return nil, errors.New("Map: Empty brackets are only allowed at the end of the URL query key '" + k + "'.")
+			}
+			if seg.isIndex && seg != appendSegment {
+				if err := limits.checkIndex(seg.index); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if len(segs) == 0 || segs[0].isIndex {
+			return nil, errors.New("Map: URL query key '" + k + "' must start with a name.")
+		}
+
+		values := make([]interface{}, len(vals[k]))
+		for i, v := range vals[k] {
+			if options.ParseValues {
+				values[i] = stewstrings.Parse(v)
+			} else {
+				values[i] = v
+			}
+		}
+
+		last := len(segs) - 1
+		if limits.DisallowDuplicateKeys && len(values) > 1 && segs[last] != appendSegment {
+			return nil, &DuplicateKeyError{Keypath: k}
+		}
+
+		if segs[last] == appendSegment {
+			for _, value := range values {
+				existing, _ := getPath(m, segs[:last])
+				length, _ := sliceLen(existing)
+				segs[last] = pathSegment{index: length, isIndex: true}
+				m[segs[0].key] = setPath(m[segs[0].key], segs[1:], value)
+			}
+			continue
+		}
+
+		var value interface{} = values
+		if len(values) == 1 {
+			value = values[0]
+		}
+		m[segs[0].key] = setPath(m[segs[0].key], segs[1:], value)
+
+	}
+
+	return m, nil
+}
+
+// parseBracketKey breaks a key like `user[address][city]` into segments.
+// Keys with unbalanced brackets are taken literally.
+func parseBracketKey(key string) []pathSegment {
+
+	open := strings.Index(key, "[")
+	if open <= 0 {
+		return []pathSegment{{key: key}}
+	}
+
+	segs := []pathSegment{{key: key[:open]}}
+
+	for rest := key[open:]; rest != ""; {
+
+		if rest[0] != '[' {
+			return []pathSegment{{key: key}}
+		}
+
+		close := strings.Index(rest, "]")
+		if close == -1 {
+			return []pathSegment{{key: key}}
+		}
+
+		name := rest[1:close]
+		switch index, err := strconv.Atoi(name); {
+		case name == "":
+			segs = append(segs, appendSegment)
+		case err == nil && index >= 0 && strconv.Itoa(index) == name:
+			segs = append(segs, pathSegment{index: index, isIndex: true})
+		default:
+			segs = append(segs, pathSegment{key: name})
+		}
+
+		rest = rest[close+1:]
+	}
+
+	return segs
+}
+
+// URLValuesWithOptions gets the url.Values representing the map, with nested
+// objects and arrays written in the style given by the options.
+func (d Map) URLValuesWithOptions(options URLQueryOptions) url.Values {
+
+	vals := make(url.Values)
+	for _, k := range sortedKeys(d) {
+		if options.Style == URLQueryBrackets {
+			encodeURLValue(vals, k, d[k], &options)
+		} else {
+			encodeURLValue(vals, EscapeKey(k), d[k], &options)
+		}
+	}
+
+	return vals
+}
+
+// URLQueryWithOptions gets an encoded URL query representing the map, with
+// nested objects and arrays written in the style given by the options.
+func (d Map) URLQueryWithOptions(options URLQueryOptions) (string, error) {
+	return d.URLValuesWithOptions(options).Encode(), nil
+}
+
+// encodeURLValue adds the value, and anything nested inside it, to the values.
+func encodeURLValue(vals url.Values, key string, value interface{}, options *URLQueryOptions) {
+
+	if m, ok := asMap(value); ok {
+		for _, k := range sortedKeys(m) {
+			if options.Style == URLQueryBrackets {
+				encodeURLValue(vals, key+"["+k+"]", m[k], options)
+			} else {
+				encodeURLValue(vals, childKeypath(key, k), m[k], options)
+			}
+		}
+		return
+	}
+
+	if length, ok := sliceLen(value); ok {
+
+		nested := false
+		for i := 0; i < length; i++ {
+			item := sliceItem(value, i)
+			_, isMap := asMap(item)
+			_, isSlice := sliceLen(item)
+			nested = nested || isMap || isSlice || item == nil
+		}
+
+		for i := 0; i < length; i++ {
+			switch {
+			case options.Style == URLQueryBrackets && !nested:
+				vals.Add(key+"[]", formatURLValue(sliceItem(value, i), options))
+			case options.Style == URLQueryBrackets:
+				encodeURLValue(vals, key+"["+strconv.Itoa(i)+"]", sliceItem(value, i), options)
+			default:
+				encodeURLValue(vals, indexKeypath(key, i), sliceItem(value, i), options)
+			}
+		}
+		return
+	}
+
+	vals.Add(key, formatURLValue(value, options))
+}
+
+// formatURLValue writes a single value as a string.
+func formatURLValue(value interface{}, options *URLQueryOptions) string {
+
+	switch value.(type) {
+	case nil:
+		return ""
+	case string:
+		s := value.(string)
+		if options.ParseValues {
+			// make sure it comes back as the same string
+			if parsed, isString := stewstrings.Parse(s).(string); !isString || parsed != s {
+				return `"` + s + `"`
+			}
+		}
+		return s
+	case time.Time:
+		return value.(time.Time).Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,138 @@
+package objects
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var bracketOptions = URLQueryOptions{Style: URLQueryBrackets}
+
+func TestNewMapFromURLQuery_Nested(t *testing.T) {
+
+	m, err := NewMapFromURLQuery("name.first=Mat&tags=a&tags=b&users[1].name=Tyler&a%5C.b=1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Mat", m.Get("name.first"))
+		assert.Equal(t, []interface{}{"a", "b"}, m["tags"])
+		assert.Equal(t, "Tyler", m.Get("users[1].name"))
+		assert.Equal(t, "1", m["a.b"])
+	}
+
+}
+
+func TestNewMapFromURLQueryWithOptions_Brackets(t *testing.T) {
+
+	m, err := NewMapFromURLQueryWithOptions("user[address][city]=Boulder&user[name]=Mat&ids[]=1&ids[]=2&users[0][name]=Tyler&odd[=x&a.b=1", bracketOptions)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Map{
+			"user":  Map{"address": Map{"city": "Boulder"}, "name": "Mat"},
+			"ids":   []interface{}{"1", "2"},
+			"users": []interface{}{Map{"name": "Tyler"}},
+			"odd[":  "x",
+			"a.b":   "1",
+		}, m)
+	}
+
+	m, err = NewMapFromURLQueryWithOptions("ids[]=1&ids[]=two&flag=true&empty=&quoted=%2229%22", URLQueryOptions{Style: URLQueryBrackets, ParseValues: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{1, "two"}, m["ids"])
+		assert.Equal(t, true, m["flag"])
+		assert.Nil(t, m["empty"])
+		assert.Equal(t, "29", m["quoted"])
+	}
+
+	_, err = NewMapFromURLQueryWithOptions("ids[][name]=1", bracketOptions)
+	assert.Error(t, err)
+
+}
+
+func TestNewMapFromURLQueryWithOptions_Limits(t *testing.T) {
+
+	_, err := NewMapFromURLQueryWithOptions("a[b][c][d]=1", URLQueryOptions{Style: URLQueryBrackets, Limits: DecodeOptions{MaxDepth: 3}})
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: 3}, err)
+
+	_, err = NewMapFromURLQueryWithOptions("a=1&b=2&b=3", URLQueryOptions{Limits: DecodeOptions{MaxKeys: 2}})
+	assert.Equal(t, &LimitError{Limit: LimitKeys, Max: 2}, err)
+
+	_, err = NewMapFromURLQueryWithOptions("a[5]=1", URLQueryOptions{Style: URLQueryBrackets, Limits: DecodeOptions{MaxKeys: 5}})
+	assert.Equal(t, &LimitError{Limit: LimitIndex, Max: 4}, err)
+
+	_, err = NewMapFromURLQueryWithOptions("a[5]=1", URLQueryOptions{Style: URLQueryBrackets, Limits: DecodeOptions{MaxIndex: 4}})
+	assert.Equal(t, &LimitError{Limit: LimitIndex, Max: 4}, err)
+
+	// URL queries have default limits on depth and keys
+	_, err = NewMapFromURLQuery(strings.Repeat("a.", DefaultURLQueryMaxDepth) + "a=1")
+	assert.Equal(t, &LimitError{Limit: LimitDepth, Max: DefaultURLQueryMaxDepth}, err)
+
+	_, err = NewMapFromURLQuery(strings.Repeat("a=1&", DefaultURLQueryMaxKeys) + "a=1")
+	assert.Equal(t, &LimitError{Limit: LimitKeys, Max: DefaultURLQueryMaxKeys}, err)
+
+	// and indexes must fit within them
+	_, err = NewMapFromURLQuery("a[999999999]=1")
+	assert.Equal(t, &LimitError{Limit: LimitIndex, Max: DefaultURLQueryMaxKeys - 1}, err)
+
+	assert.Equal(t, "Map: Input exceeds the depth limit of 3.", (&LimitError{Limit: LimitDepth, Max: 3}).Error())
+
+}
+
+func TestURLValues_Nested(t *testing.T) {
+
+	m := Map{
+		"user":  Map{"name": "Mat", "address": map[string]interface{}{"city": "Boulder"}},
+		"ids":   []interface{}{1, 2},
+		"users": []Map{{"name": "Tyler"}},
+		"a.b":   true,
+	}
+
+	assert.Equal(t, url.Values{
+		"user.name":         {"Mat"},
+		"user.address.city": {"Boulder"},
+		"ids[0]":            {"1"},
+		"ids[1]":            {"2"},
+		"users[0].name":     {"Tyler"},
+		`a\.b`:              {"true"},
+	}, m.URLValues())
+
+	assert.Equal(t, url.Values{
+		"user[name]":          {"Mat"},
+		"user[address][city]": {"Boulder"},
+		"ids[]":               {"1", "2"},
+		"users[0][name]":      {"Tyler"},
+		"a.b":                 {"true"},
+	}, m.URLValuesWithOptions(bracketOptions))
+
+	query, err := M("ids", []string{"a", "b"}).URLQueryWithOptions(bracketOptions)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ids%5B%5D=a&ids%5B%5D=b", query)
+	}
+
+}
+
+func TestURLQuery_RoundTrip(t *testing.T) {
+
+	m := Map{
+		"user":   Map{"name": "Mat", "age": 29, "admin": false},
+		"ids":    []interface{}{1, 2},
+		"grid":   []interface{}{[]interface{}{1, 2}, []interface{}{3}},
+		"users":  []interface{}{Map{"name": "Tyler", "tags": []interface{}{"x"}}},
+		"number": "29",
+		"empty":  "",
+		"a.b":    "dotted",
+	}
+
+	for _, style := range []URLQueryStyle{URLQueryDots, URLQueryBrackets} {
+
+		options := URLQueryOptions{Style: style, ParseValues: true}
+
+		query, err := m.URLQueryWithOptions(options)
+		if assert.NoError(t, err) {
+			decoded, err := NewMapFromURLQueryWithOptions(query, options)
+			if assert.NoError(t, err) {
+				assert.Equal(t, m, decoded, query)
+			}
+		}
+
+	}
+
+}
@@ -0,0 +1,181 @@
+package objects
+
+import (
+	"errors"
+)
+
+// SkipChildren can be returned by a WalkFunc to stop Walk going inside the
+// map or slice it was just given.  Walk carries on with the next value.
+//
+// It has no effect when walking in WalkPostOrder, since the children have
+// already been visited.
+var SkipChildren = errors.New("Map: Skip children.")
+
+// WalkFunc is called by Walk for each value in the map.  The keypath can be
+// given to Get to find the same value, and array items have index paths,
+// like `users[0].name`.
+//
+// If it returns an error, other than SkipChildren, the walk stops and Walk
+// returns the error.
+type WalkFunc func(keypath string, value interface{}) error
+
+// WalkOrder is the order Walk visits values in.
+type WalkOrder int
+
+const (
+	// WalkPreOrder visits maps and slices before the values inside them.
+	WalkPreOrder WalkOrder = iota
+	// WalkPostOrder visits maps and slices after the values inside them.
+	WalkPostOrder
+)
+
+// WalkOptions controls how WalkWithOptions visits values.
+type WalkOptions struct {
+	// Order is the order values are visited in, WalkPreOrder by default.
+	Order WalkOrder
+	// LeavesOnly only visits values that are not maps or slices.
+	LeavesOnly bool
+}
+
+// Walk calls walkFunc for every value in the map, however deeply nested,
+// including the maps and slices themselves.  The map itself is not visited.
+//
+// Keys are visited in sorted order, and slice items in order.
+//
+// For example:
+//
+//     m.Walk(func(keypath string, value interface{}) error {
+//         fmt.Println(keypath, value)
+//         return nil
+//     })
+func (d Map) Walk(walkFunc WalkFunc) error {
+	return d.WalkWithOptions(walkFunc, WalkOptions{})
+}
+
+// WalkWithOptions calls walkFunc for every value in the map, like Walk, using
+// the options.
+func (d Map) WalkWithOptions(walkFunc WalkFunc, options WalkOptions) error {
+	return walkChildren("", d, walkFunc, &options)
+}
+
+// walkChildren walks the values inside a map or slice.
+func walkChildren(keypath string, value interface{}, walkFunc WalkFunc, options *WalkOptions) error {
+
+	if m, ok := asMap(value); ok {
+		for _, k := range sortedKeys(m) {
+			if err := walkValue(childKeypath(keypath, k), m[k], walkFunc, options); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if length, ok := sliceLen(value); ok {
+		for i := 0; i < length; i++ {
+			if err := walkValue(indexKeypath(keypath, i), sliceItem(value, i), walkFunc, options); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkValue visits the value, and any values inside it.
+func walkValue(keypath string, value interface{}, walkFunc WalkFunc, options *WalkOptions) error {
+
+	_, isMap := asMap(value)
+	_, isSlice := sliceLen(value)
+	isContainer := isMap || isSlice
+	visit := !isContainer || !options.LeavesOnly
+
+	if visit && options.Order == WalkPreOrder {
+		err := walkFunc(keypath, value)
+		if err == SkipChildren {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if isContainer {
+		if err := walkChildren(keypath, value, walkFunc, options); err != nil {
+			return err
+		}
+	}
+
+	if visit && options.Order == WalkPostOrder {
+		if err := walkFunc(keypath, value); err != SkipChildren {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TransformDeep builds a new map giving the transformer a chance to change
+// the keys and values as it goes, like Transform, but for every key in the
+// map however deeply nested, including inside slices.
+//
+// The transformer is given the keypath of the value in this map, as well as
+// its key.  Maps and slices it returns are then transformed themselves.
+// Nested maps in the new map are always Map objects.
+//
+// For example, to make every key lower case:
+//
+//     lower := m.TransformDeep(func(keypath, key string, value interface{}) (string, interface{}) {
+//         return strings.ToLower(key), value
+//     })
+func (d Map) TransformDeep(transformer func(keypath, key string, value interface{}) (string, interface{})) Map {
+	return transformMap("", d, transformer)
+}
+
+// transformMap builds the transformed copy of a map.
+func transformMap(keypath string, m Map, transformer func(keypath, key string, value interface{}) (string, interface{})) Map {
+
+	transformed := make(Map, len(m))
+
+	for k, v := range m {
+		childPath := childKeypath(keypath, k)
+		modifiedKey, modifiedVal := transformer(childPath, k, v)
+		transformed[modifiedKey] = transformValue(childPath, modifiedVal, transformer)
+	}
+
+	return transformed
+}
+
+// transformValue transforms the maps inside the value.  Other values are
+// returned as they are.
+func transformValue(keypath string, value interface{}, transformer func(keypath, key string, value interface{}) (string, interface{})) interface{} {
+
+	if m, ok := asMap(value); ok {
+		if m == nil {
+			return value
+		}
+		return transformMap(keypath, m, transformer)
+	}
+
+	switch value.(type) {
+	case []interface{}:
+		items := make([]interface{}, len(value.([]interface{})))
+		for i, item := range value.([]interface{}) {
+			items[i] = transformValue(indexKeypath(keypath, i), item, transformer)
+		}
+		return items
+	case []Map:
+		items := make([]Map, len(value.([]Map)))
+		for i, item := range value.([]Map) {
+			items[i] = transformMap(indexKeypath(keypath, i), item, transformer)
+		}
+		return items
+	case []map[string]interface{}:
+		items := make([]Map, len(value.([]map[string]interface{})))
+		for i, item := range value.([]map[string]interface{}) {
+			items[i] = transformMap(indexKeypath(keypath, i), item, transformer)
+		}
+		return items
+	}
+
+	return value
+}
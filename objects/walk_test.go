@@ -0,0 +1,169 @@
+package objects
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var walkTestMap = M("name", "stew",
+	"owner", M("first", "Mat", "last", "Ryer"),
+	"tags", []interface{}{"a", M("b", 1)},
+	"files", M("readme.md", true))
+
+func TestMapWalk(t *testing.T) {
+
+	var keypaths []string
+	err := walkTestMap.Walk(func(keypath string, value interface{}) error {
+		keypaths = append(keypaths, keypath)
+		assert.Equal(t, walkTestMap.Get(keypath), value)
+		return nil
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"files", `files.readme\.md`,
+			"name",
+			"owner", "owner.first", "owner.last",
+			"tags", "tags[0]", "tags[1]", "tags[1].b",
+		}, keypaths)
+	}
+
+}
+
+func TestMapWalk_PostOrder(t *testing.T) {
+
+	var keypaths []string
+	err := M("a", M("b", []interface{}{1})).WalkWithOptions(func(keypath string, value interface{}) error {
+		keypaths = append(keypaths, keypath)
+		return nil
+	}, WalkOptions{Order: WalkPostOrder})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a.b[0]", "a.b", "a"}, keypaths)
+	}
+
+}
+
+func TestMapWalk_LeavesOnly(t *testing.T) {
+
+	var keypaths []string
+	err := walkTestMap.WalkWithOptions(func(keypath string, value interface{}) error {
+		keypaths = append(keypaths, keypath)
+		return nil
+	}, WalkOptions{LeavesOnly: true})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{`files.readme\.md`, "name", "owner.first", "owner.last", "tags[0]", "tags[1].b"}, keypaths)
+	}
+
+}
+
+func TestMapWalk_SkipChildren(t *testing.T) {
+
+	var keypaths []string
+	err := walkTestMap.Walk(func(keypath string, value interface{}) error {
+		keypaths = append(keypaths, keypath)
+		if keypath == "owner" || keypath == "tags" {
+			return SkipChildren
+		}
+		return nil
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"files", `files.readme\.md`, "name", "owner", "tags"}, keypaths)
+	}
+
+	// no effect in post order
+	count := 0
+	err = walkTestMap.WalkWithOptions(func(keypath string, value interface{}) error {
+		count++
+		return SkipChildren
+	}, WalkOptions{Order: WalkPostOrder})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 10, count)
+	}
+
+}
+
+func TestMapWalk_Error(t *testing.T) {
+
+	stop := errors.New("stop")
+	var keypaths []string
+
+	err := walkTestMap.Walk(func(keypath string, value interface{}) error {
+		keypaths = append(keypaths, keypath)
+		if keypath == "owner.first" {
+			return stop
+		}
+		return nil
+	})
+
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []string{"files", `files.readme\.md`, "name", "owner", "owner.first"}, keypaths)
+
+}
+
+func TestMapWalk_TypedSlices(t *testing.T) {
+
+	m := M("maps", []Map{M("a", 1)}, "msis", []map[string]interface{}{{"b": 2}}, "strings", []string{"x"})
+
+	var keypaths []string
+	m.WalkWithOptions(func(keypath string, value interface{}) error {
+		keypaths = append(keypaths, keypath)
+		return nil
+	}, WalkOptions{LeavesOnly: true})
+
+	assert.Equal(t, []string{"maps[0].a", "msis[0].b", "strings[0]"}, keypaths)
+
+}
+
+func TestMapTransformDeep(t *testing.T) {
+
+	m := M("Name", "stew",
+		"Owner", M("First", "Mat"),
+		"Tags", []interface{}{"A", M("B", 1)},
+		"Maps", []map[string]interface{}{{"C": 2}})
+
+	var keypaths []string
+	transformed := m.TransformDeep(func(keypath, key string, value interface{}) (string, interface{}) {
+		keypaths = append(keypaths, keypath)
+		return strings.ToLower(key), value
+	})
+
+	assert.Equal(t, M("name", "stew",
+		"owner", M("first", "Mat"),
+		"tags", []interface{}{"A", M("b", 1)},
+		"maps", []Map{M("c", 2)}), transformed)
+
+	assert.Contains(t, keypaths, "Owner.First")
+	assert.Contains(t, keypaths, "Tags[1].B")
+	assert.Contains(t, keypaths, "Maps[0].C")
+	assert.Equal(t, 7, len(keypaths))
+
+	// the original is unchanged
+	assert.Equal(t, "Mat", m.Get("Owner.First"))
+	assert.Equal(t, 1, m.Get("Tags[1].B"))
+
+}
+
+func TestMapTransformDeep_ReturnedValues(t *testing.T) {
+
+	m := M("secret", "s3cr3t", "user", M("password", "hunter2", "name", "Mat"))
+
+	redacted := m.TransformDeep(func(keypath, key string, value interface{}) (string, interface{}) {
+		switch keypath {
+		case "secret", "user.password":
+			return key, "***"
+		case "user":
+			// returned maps are transformed too
+			return key, M("password", "x", "name", value.(Map).Get("name"))
+		}
+		return key, value
+	})
+
+	assert.Equal(t, M("secret", "***", "user", M("password", "***", "name", "Mat")), redacted)
+
+}